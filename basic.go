@@ -1,6 +1,9 @@
 package tmx
 
-import "fmt"
+import (
+	"encoding/json"
+	"fmt"
+)
 
 // Point describes a location in 2D space.
 type Point struct {
@@ -81,6 +84,34 @@ func (r Rect) BottomRight() Point {
 	return Point{X: r.X + r.Width, Y: r.Y + r.Height}
 }
 
+// Contains tests whether the given point is within the rectangle.
+func (r Rect) Contains(p Point) bool {
+	return p.X >= r.X && p.X < r.X+r.Width && p.Y >= r.Y && p.Y < r.Y+r.Height
+}
+
+// Intersect returns the area where two rectangles overlap, or an empty rectangle when they do
+// not overlap.
+func (r Rect) Intersect(other Rect) Rect {
+	x0, y0 := max(r.X, other.X), max(r.Y, other.Y)
+	x1, y1 := min(r.Right(), other.Right()), min(r.Bottom(), other.Bottom())
+	if x1 <= x0 || y1 <= y0 {
+		return Rect{}
+	}
+	return Rect{Point: Point{X: x0, Y: y0}, Size: Size{Width: x1 - x0, Height: y1 - y0}}
+}
+
+// Canon returns the rectangle with the same area, but with a non-negative width and height, such
+// as one given from a selection dragged up or to the left.
+func (r Rect) Canon() Rect {
+	if r.Width < 0 {
+		r.X, r.Width = r.X+r.Width, -r.Width
+	}
+	if r.Height < 0 {
+		r.Y, r.Height = r.Y+r.Height, -r.Height
+	}
+	return r
+}
+
 // String implements the Stringer interface.
 func (r Rect) String() string {
 	return fmt.Sprintf("<%d, %d, %d, %d>", r.X, r.Y, r.Width, r.Height)
@@ -99,4 +130,53 @@ func (v Vec2) String() string {
 	return fmt.Sprintf("<%f, %f>", v.X, v.Y)
 }
 
+// MarshalJSON implements the json.Marshaler interface.
+func (v Vec2) MarshalJSON() ([]byte, error) {
+	return json.Marshal(map[string]any{"x": jsonFloat(v.X), "y": jsonFloat(v.Y)})
+}
+
+// RectF describes a location and size in 2D space with floating-point components.
+type RectF struct {
+	// Location is the top-left corner of the rectangle.
+	Location Vec2
+	// Size is the dimensions of the rectangle.
+	Size Vec2
+}
+
+// Left returns the left edge of the rectangle.
+func (r RectF) Left() float32 {
+	return r.Location.X
+}
+
+// Right returns the right edge of the rectangle.
+func (r RectF) Right() float32 {
+	return r.Location.X + r.Size.X
+}
+
+// Top returns the top edge of the rectangle.
+func (r RectF) Top() float32 {
+	return r.Location.Y
+}
+
+// Bottom returns the bottom edge of the rectangle.
+func (r RectF) Bottom() float32 {
+	return r.Location.Y + r.Size.Y
+}
+
+// Contains tests whether the given point is within the rectangle, including its edges.
+func (r RectF) Contains(v Vec2) bool {
+	return v.X >= r.Left() && v.X <= r.Right() && v.Y >= r.Top() && v.Y <= r.Bottom()
+}
+
+// Intersects tests whether two rectangles overlap, including touching edges.
+func (r RectF) Intersects(other RectF) bool {
+	return r.Left() <= other.Right() && other.Left() <= r.Right() &&
+		r.Top() <= other.Bottom() && other.Top() <= r.Bottom()
+}
+
+// String implements the Stringer interface.
+func (r RectF) String() string {
+	return fmt.Sprintf("<%f, %f, %f, %f>", r.Location.X, r.Location.Y, r.Size.X, r.Size.Y)
+}
+
 // vim: ts=4
@@ -0,0 +1,99 @@
+package tmx
+
+import (
+	"strings"
+	"testing"
+)
+
+// canonicalXML is an infinite map with values that are written differently in canonical mode,
+// with chunks and unknown attributes out of order.
+var canonicalXML = `<?xml version="1.0" encoding="UTF-8"?>
+<map version="1.10" orientation="orthogonal" width="2" height="2" tilewidth="16" tileheight="16" infinite="1" nextlayerid="3" nextobjectid="2" zextra="1" aextra="2">
+ <properties>
+  <property name="small" type="float" value="0.0000001"/>
+  <property name="large" type="float" value="100000000000000000000000"/>
+ </properties>
+ <layer id="1" name="ground" width="2" height="2" offsetx="-0" offsety="0.000001">
+  <data encoding="csv">
+   <chunk x="16" y="0" width="16" height="16">` + canonicalChunk + `</chunk>
+   <chunk x="0" y="16" width="16" height="16">` + canonicalChunk + `</chunk>
+   <chunk x="0" y="0" width="16" height="16">` + canonicalChunk + `</chunk>
+  </data>
+ </layer>
+ <objectgroup id="2" name="objects">
+  <object id="1" x="0.0000015" y="-0" width="1e22" height="2.5" rotation="-0"/>
+ </objectgroup>
+</map>
+`
+
+// canonicalChunk is the contents of a 16x16 chunk with a single tile.
+var canonicalChunk = "1" + strings.Repeat(",0", 16*16-1)
+
+// setCanonical changes Canonical for the duration of a test.
+func setCanonical(t *testing.T, value bool) {
+	prev := Canonical
+	Canonical = value
+	t.Cleanup(func() { Canonical = prev })
+}
+
+func TestCanonical(t *testing.T) {
+	setCanonical(t, true)
+	setPreserveUnknown(t, true)
+
+	tests := []struct {
+		format Format
+		want   []string
+	}{
+		{FormatXML, []string{`aextra="2" zextra="1"`, `<property name="large" type="float" value="100000000000000000000000">`,
+			`<property name="small" type="float" value="0.0000001">`, `offsetx="0" offsety="0.000001"`,
+			`x="0.0000015" y="0" width="10000000000000000000000" height="2.5"`}},
+		{FormatJSON, []string{`"value":100000000000000000000000`, `"value":0.0000001`, `"offsetx":0,`,
+			`"offsety":0.000001`, `"x":0.0000015`, `"width":10000000000000000000000`}},
+	}
+
+	for _, test := range tests {
+		// Written from the original document, then read and written back again
+		m := decodeMap(t, canonicalXML, FormatXML)
+		first := encodeString(t, test.format, m)
+		again := encodeString(t, test.format, decodeMap(t, first, test.format))
+		if first != again {
+			t.Errorf("%v: output changed when written again:\n%s\n%s", test.format, first, again)
+		}
+
+		search := first
+		if test.format == FormatJSON {
+			search = compactJSON(strings.ReplaceAll(first, `": `, `":`))
+		}
+		for _, want := range test.want {
+			if !strings.Contains(search, want) {
+				t.Errorf("%v: does not contain %s:\n%s", test.format, want, first)
+			}
+		}
+		if strings.Contains(search, "e+") || strings.Contains(search, "e-") || strings.Contains(search, "-0") {
+			t.Errorf("%v: float written in non-canonical form:\n%s", test.format, first)
+		}
+
+		// Chunks are written by row, then column
+		if test.format == FormatXML {
+			a := strings.Index(first, `<chunk x="0" y="0"`)
+			b := strings.Index(first, `<chunk x="16" y="0"`)
+			c := strings.Index(first, `<chunk x="0" y="16"`)
+			if a < 0 || !(a < b && b < c) {
+				t.Errorf("chunks are out of order:\n%s", first)
+			}
+		}
+	}
+}
+
+func TestNotCanonical(t *testing.T) {
+	setCanonical(t, false)
+
+	// Without canonical mode, values are written in the shortest form by the JSON encoder
+	m := decodeMap(t, canonicalXML, FormatXML)
+	doc := compactJSON(strings.ReplaceAll(encodeString(t, FormatJSON, m), `": `, `":`))
+	if !strings.Contains(doc, `"width":1e+22`) {
+		t.Errorf("width was not written with an exponent:\n%s", doc)
+	}
+}
+
+// vim: ts=4
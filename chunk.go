@@ -4,14 +4,27 @@ import (
 	"bytes"
 	"encoding/json"
 	"errors"
+	"slices"
 )
 
+// Chunk contains the tiles of a rectangular area of a tile layer, such as the chunks that store
+// the tiles of infinite maps, or a region copied from a layer.
 type Chunk struct {
+	// Rect is the location and size of the chunk in tile units.
 	Rect
-	Tiles    []TileID
+	// Tiles contains the global tile IDs of the chunk, row by row.
+	Tiles []TileID
+	// Extra contains unrecognized data that was retained while reading, see PreserveUnknown.
+	Extra    Extra
 	tileData []byte
 }
 
+// Encode encodes the tiles of the chunk using the given encoding and compression. The level
+// is the compression level to apply, where -1 uses the default of the compression algorithm.
+func (c *Chunk) Encode(encoding Encoding, compression Compression, level int) ([]byte, error) {
+	return EncodeTiles(c.Tiles, c.Width, encoding, compression, level)
+}
+
 // UnmarshalJSON implements the json.Unmarshaler interface.
 func (c *Chunk) UnmarshalJSON(data []byte) error {
 	d := json.NewDecoder(bytes.NewReader(data))
@@ -30,20 +43,35 @@ func (c *Chunk) UnmarshalJSON(data []byte) error {
 		}
 
 		name := token.(string)
-		if token, err = d.Token(); err != nil {
-			return err
-		}
-
 		switch name {
 		case "x":
-			c.X = int(token.(float64))
+			if value, err := jsonProp[float64](d); err != nil {
+				return err
+			} else {
+				c.X = int(value)
+			}
 		case "y":
-			c.Y = int(token.(float64))
+			if value, err := jsonProp[float64](d); err != nil {
+				return err
+			} else {
+				c.Y = int(value)
+			}
 		case "width":
-			c.Width = int(token.(float64))
+			if value, err := jsonProp[float64](d); err != nil {
+				return err
+			} else {
+				c.Width = int(value)
+			}
 		case "height":
-			c.Height = int(token.(float64))
+			if value, err := jsonProp[float64](d); err != nil {
+				return err
+			} else {
+				c.Height = int(value)
+			}
 		case "data":
+			if token, err = d.Token(); err != nil {
+				return err
+			}
 			if token == json.Delim('[') {
 				// An array of tile IDs
 				c.Tiles = make([]TileID, 0, c.Width*c.Height)
@@ -61,12 +89,131 @@ func (c *Chunk) UnmarshalJSON(data []byte) error {
 				c.tileData = trimPayload([]byte(token.(string)))
 			}
 		default:
-			logProp(name, "chunk")
-			jsonSkip(d)
+			if err := c.Extra.prop(d, name, "chunk"); err != nil {
+				return err
+			}
 		}
 	}
 
 	return nil
 }
 
+// Clone creates a deep copy of the Chunk.
+func (c *Chunk) Clone() *Chunk {
+	return &Chunk{Rect: c.Rect, Tiles: slices.Clone(c.Tiles), Extra: c.Extra.clone()}
+}
+
+// chunkIndex maps the origin of each chunk of a layer to its index, so that the chunk containing
+// a position is found without searching every chunk. It is only used when all chunks have the
+// same size and are aligned to it, as they are when written by Tiled.
+type chunkIndex struct {
+	origins map[Point]int
+	size    Size
+	aligned bool
+	// first and count identify the slice of chunks the index was built from, so that it is
+	// rebuilt when the chunks are replaced.
+	first *Chunk
+	count int
+}
+
+// find returns the index of the chunk containing a position, or -1 when there is none.
+func (idx *chunkIndex) find(chunks []Chunk, p Point) int {
+	if !idx.current(chunks) {
+		idx.build(chunks)
+	}
+	if idx.aligned {
+		origin := idx.origin(p)
+		i, ok := idx.origins[origin]
+		if !ok {
+			return -1
+		}
+		if chunks[i].Point == origin && chunks[i].Size == idx.size {
+			return i
+		}
+		// The chunks were changed in place
+		idx.build(chunks)
+		return idx.find(chunks, p)
+	}
+	for i := range chunks {
+		if chunks[i].Contains(p) {
+			return i
+		}
+	}
+	return -1
+}
+
+// added updates the index after a chunk was appended to the chunks it was built from.
+func (idx *chunkIndex) added(chunks []Chunk) {
+	n := len(chunks) - 1
+	chunk := &chunks[n]
+	if n == 0 || !idx.aligned || chunk.Size != idx.size || idx.origin(chunk.Point) != chunk.Point {
+		idx.build(chunks)
+		return
+	}
+	if _, ok := idx.origins[chunk.Point]; ok {
+		idx.build(chunks)
+		return
+	}
+	idx.origins[chunk.Point] = n
+	idx.first, idx.count = &chunks[0], len(chunks)
+}
+
+// current tests whether the index was built from the given chunks.
+func (idx *chunkIndex) current(chunks []Chunk) bool {
+	if len(chunks) == 0 {
+		return idx.count == 0 && idx.first == nil
+	}
+	return idx.count == len(chunks) && idx.first == &chunks[0]
+}
+
+// build rebuilds the index from the given chunks.
+func (idx *chunkIndex) build(chunks []Chunk) {
+	*idx = chunkIndex{count: len(chunks)}
+	if len(chunks) == 0 {
+		return
+	}
+
+	idx.first = &chunks[0]
+	idx.size = chunks[0].Size
+	if idx.size.Width <= 0 || idx.size.Height <= 0 {
+		return
+	}
+	idx.origins = make(map[Point]int, len(chunks))
+	for i := range chunks {
+		chunk := &chunks[i]
+		if _, ok := idx.origins[chunk.Point]; ok || chunk.Size != idx.size || idx.origin(chunk.Point) != chunk.Point {
+			idx.origins = nil
+			return
+		}
+		idx.origins[chunk.Point] = i
+	}
+	idx.aligned = true
+}
+
+// overlappingChunks returns the indices of two chunks that overlap each other, or -1 when none
+// do.
+func overlappingChunks(chunks []Chunk) (int, int) {
+	var idx chunkIndex
+	if idx.build(chunks); idx.aligned {
+		// Chunks of the same size with distinct aligned origins cannot overlap
+		return -1, -1
+	}
+	for i := range chunks {
+		for j := i + 1; j < len(chunks); j++ {
+			if chunks[i].Intersect(chunks[j].Rect).Area() > 0 {
+				return i, j
+			}
+		}
+	}
+	return -1, -1
+}
+
+// origin returns the origin of the aligned chunk that would contain a position.
+func (idx *chunkIndex) origin(p Point) Point {
+	return Point{
+		X: floorDiv(p.X, idx.size.Width) * idx.size.Width,
+		Y: floorDiv(p.Y, idx.size.Height) * idx.size.Height,
+	}
+}
+
 // vim: ts=4
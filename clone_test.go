@@ -0,0 +1,204 @@
+package tmx
+
+import (
+	"encoding/xml"
+	"path/filepath"
+	"testing"
+)
+
+// addProperty adds a property to a collection, creating it when it is nil.
+func addProperty(props *Properties, name string) {
+	if *props == nil {
+		*props = make(Properties)
+	}
+	(*props)[name] = Property{Name: name, Type: TypeInt, Value: 1}
+}
+
+// mutateMap changes every value of a map that a deep copy must not share with the original.
+func mutateMap(m *Map) {
+	m.Properties["title"] = Property{Name: "title", Type: TypeString, Value: "changed"}
+	addProperty(&m.Properties, "added")
+
+	ts := m.Tilesets[0].Tileset
+	ts.Name = "changed"
+	ts.Image.Source = "changed.png"
+	ts.Tiles[3].Animation[0].ID = 7
+	ts.Tiles[0].Class = "changed"
+
+	walkLayers(m, func(layer Layer) {
+		layer.base().Name += "-changed"
+		addProperty(&layer.base().Properties, "added")
+		switch value := layer.(type) {
+		case *TileLayer:
+			value.Tiles[0] = 99
+		case *ObjectLayer:
+			for i := range value.Objects {
+				obj := &value.Objects[i]
+				obj.Name += "-changed"
+				addProperty(&obj.Properties, "added")
+				for j := range obj.Points {
+					obj.Points[j].X += 100
+				}
+				if obj.Text != nil {
+					obj.Text.Value = "changed"
+				}
+			}
+		case *ImageLayer:
+			value.Image.Source = "changed.png"
+		case *GroupLayer:
+			value.AddLayer(NewTileLayer("added", m.Size))
+		}
+	})
+	m.AddLayer(NewObjectLayer("added"))
+}
+
+func TestCloneMap(t *testing.T) {
+	m := decodeMap(t, testMapXML, FormatXML)
+	want := encodeString(t, FormatXML, m)
+
+	dup := m.Clone()
+	if doc := encodeString(t, FormatXML, dup); doc != want {
+		t.Fatalf("copy is written differently:\n%s\n%s", want, doc)
+	}
+
+	// The layers of the copy are linked to it, and not to the original
+	walkLayers(dup, func(layer Layer) {
+		if layer.Map() != dup {
+			t.Errorf("%s: map is %p, want %p", layerName(layer), layer.Map(), dup)
+		}
+	})
+	checkLinks(t, dup, layerNames(m)[0], "group", "sky")
+	if dup.Tilesets[0].Map != dup || dup.Tilesets[0].Tileset == m.Tilesets[0].Tileset {
+		t.Errorf("tileset of the copy is shared")
+	}
+	if tile := &dup.Tilesets[0].Tiles[3]; tile.Tileset != dup.Tilesets[0].Tileset {
+		t.Errorf("tile of the copy refers to the original tileset")
+	}
+
+	// Changes to the copy do not affect the original, or the other way around
+	mutateMap(dup)
+	if doc := encodeString(t, FormatXML, m); doc != want {
+		t.Errorf("original was changed by the copy:\n%s\n%s", want, doc)
+	}
+	dup = m.Clone()
+	copied := encodeString(t, FormatXML, dup)
+	mutateMap(m)
+	if doc := encodeString(t, FormatXML, dup); doc != copied {
+		t.Errorf("copy was changed by the original:\n%s\n%s", copied, doc)
+	}
+}
+
+func TestCloneShared(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, externalFiles)
+	m, err := ReadMap(filepath.Join(dir, "level.tmx"), FormatUnknown, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Cached tilesets are shared, but the map tilesets are still copies
+	shared := m.CloneShared()
+	if shared.Tilesets[0] == m.Tilesets[0] || shared.Tilesets[0].Tileset != m.Tilesets[0].Tileset {
+		t.Errorf("cached tileset was not shared")
+	}
+	if dup := m.Clone(); dup.Tilesets[0].Tileset == m.Tilesets[0].Tileset {
+		t.Errorf("cached tileset was shared")
+	}
+
+	// Embedded tilesets are never shared
+	m = decodeMap(t, testMapXML, FormatXML)
+	if shared := m.CloneShared(); shared.Tilesets[0].Tileset == m.Tilesets[0].Tileset {
+		t.Errorf("embedded tileset was shared")
+	}
+}
+
+func TestCloneLayers(t *testing.T) {
+	m := decodeMap(t, testMapXML, FormatXML)
+	for _, layer := range []Layer{m.TileLayers[0], m.GroupLayers[0], m.GroupLayers[0].ObjectLayers[0], m.ImageLayers[0]} {
+		dup := cloneLayer(layer)
+		name := layerName(layer)
+		if dup == layer || layerName(dup) != name || dup.Map() != nil || dup.Container() != nil ||
+			dup.Next() != nil || dup.Prev() != nil {
+			t.Errorf("%s: copy is within a container", name)
+		}
+		addProperty(&dup.base().Properties, "added")
+		if _, ok := layer.base().Properties["added"]; ok {
+			t.Errorf("%s: properties are shared", name)
+		}
+	}
+
+	// Children of groups are copied and linked to the copy
+	group := m.GroupLayers[0].Clone()
+	child := group.Head()
+	if child == nil || child == m.GroupLayers[0].Head() || child.Container() != Container(group) {
+		t.Fatalf("child of group was not copied")
+	}
+	checkLinks(t, group, "objects")
+
+	layer := m.TileLayers[0].Clone()
+	layer.Tiles[0] = 99
+	if m.TileLayers[0].Tiles[0] == 99 {
+		t.Errorf("tiles are shared")
+	}
+}
+
+func TestCloneObject(t *testing.T) {
+	m, _ := readTemplateMap(t, templateFiles)
+	obj := &m.ObjectLayers[0].Objects[1]
+	obj.Points = []Vec2{{1, 2}}
+	obj.Text = &Text{Value: "text"}
+
+	obj.Extra.Attrs = []xml.Attr{{Name: xml.Name{Local: "extra"}, Value: "1"}}
+
+	dup := obj.Clone()
+	if dup.Template != obj.Template || dup.Name != obj.Name || dup.ID != obj.ID {
+		t.Errorf("copy is %+v", dup)
+	}
+	if dup.layer != nil {
+		t.Errorf("copy is within a layer")
+	}
+	dup.Points[0].X = 10
+	dup.Text.Value = "changed"
+	dup.Properties["loot"] = Property{Name: "loot", Type: TypeString, Value: "changed"}
+	dup.Extra.Attrs[0].Value = "changed"
+	if obj.Points[0].X != 1 || obj.Text.Value != "text" || obj.Properties["loot"].Value != "sword" ||
+		obj.Extra.Attrs[0].Value != "1" {
+		t.Errorf("original was changed: %+v", obj)
+	}
+
+	// Objects of a copied layer are within the copy
+	layer := m.ObjectLayers[0].Clone()
+	for i := range layer.Objects {
+		if layer.Objects[i].layer != layer {
+			t.Errorf("object %d of the copy is within %p, want %p", layer.Objects[i].ID, layer.Objects[i].layer, layer)
+		}
+	}
+	layer.Objects[0].Name = "changed"
+	if m.ObjectLayers[0].Objects[0].Name == "changed" {
+		t.Errorf("objects are shared")
+	}
+}
+
+func TestCloneTileset(t *testing.T) {
+	m := decodeMap(t, testMapXML, FormatXML)
+	ts := m.Tilesets[0].Tileset
+	want := encodeString(t, FormatXML, ts)
+
+	dup := ts.Clone()
+	if doc := encodeString(t, FormatXML, dup); doc != want {
+		t.Fatalf("copy is written differently:\n%s\n%s", want, doc)
+	}
+	for i := range dup.Tiles {
+		if dup.Tiles[i].Tileset != dup {
+			t.Errorf("tile %d refers to the original tileset", i)
+		}
+	}
+	dup.Image.Width = 1
+	dup.Tiles[3].Animation[1].ID = 0
+	dup.Tiles[1].Properties = Properties{"added": {Name: "added", Type: TypeInt, Value: 1}}
+	if doc := encodeString(t, FormatXML, ts); doc != want {
+		t.Errorf("original was changed by the copy:\n%s\n%s", want, doc)
+	}
+}
+
+// vim: ts=4
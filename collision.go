@@ -17,10 +17,68 @@ type Collision struct {
 	DrawOrder DrawOrder
 	// Objects is a collection of shaped objects defining the collision.
 	Objects []Object
+	// Extra contains unrecognized data that was retained while reading, see PreserveUnknown.
+	Extra Extra
 	// cache is a resource cache that maintains references to shared objects.
 	cache *Cache
 }
 
+// MarshalXML implements the xml.Marshaler interface.
+func (c *Collision) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return c.encodeXML(nil, e, start)
+}
+
+// encodeXML implements the xmlEncoding interface.
+func (c *Collision) encodeXML(enc *encoder, e *xml.Encoder, start xml.StartElement) error {
+	start = xml.StartElement{Name: xml.Name{Local: "objectgroup"}}
+	if c.DrawOrder == DrawIndex {
+		start.Attr = append(start.Attr, xmlStr("draworder", c.DrawOrder.String()))
+	}
+	if c.ID != 0 {
+		start.Attr = append(start.Attr, xmlInt("id", c.ID))
+	}
+	start.Attr = c.Extra.xmlAttrs(start.Attr)
+
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := c.Extra.marshalXML(e); err != nil {
+		return err
+	}
+	for i := range c.Objects {
+		if err := e.EncodeElement(enc.xml(&c.Objects[i]), start); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (c *Collision) MarshalJSON() ([]byte, error) {
+	return c.encodeJSON(nil)
+}
+
+// encodeJSON implements the jsonEncoding interface.
+func (c *Collision) encodeJSON(enc *encoder) ([]byte, error) {
+	attrs := map[string]any{
+		"draworder": c.DrawOrder,
+		"objects":   jsonObjects(enc, c.Objects),
+	}
+	if c.ID != 0 {
+		attrs["id"] = c.ID
+	}
+	c.Extra.jsonAttrs(attrs)
+	jsonDefaults(attrs, map[string]any{
+		"name":    "",
+		"opacity": 1,
+		"type":    LayerObject,
+		"visible": true,
+		"x":       0,
+		"y":       0,
+	})
+	return json.Marshal(attrs)
+}
+
 // UnmarshalXML implements the xml.Unmarshaler interface.
 func (c *Collision) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	for _, attr := range start.Attr {
@@ -39,7 +97,7 @@ func (c *Collision) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 				c.DrawOrder = DrawTopDown
 			}
 		default:
-			logAttr(attr.Name.Local, start.Name.Local)
+			c.Extra.attr(attr, start.Name.Local)
 		}
 	}
 
@@ -51,7 +109,9 @@ func (c *Collision) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 
 		if child, ok := token.(xml.StartElement); ok {
 			if child.Name.Local != "object" {
-				logElem(child.Name.Local, start.Name.Local)
+				if err := c.Extra.elem(d, child, start.Name.Local); err != nil {
+					return err
+				}
 			} else {
 				var object Object
 				if err := object.UnmarshalXML(d, child); err != nil {
@@ -119,14 +179,28 @@ func (c *Collision) UnmarshalJSON(data []byte) error {
 				return err
 			}
 		case "name", "opacity", "x", "y", "type", "visible":
-			jsonSkip(d)
+			if err := c.Extra.skip(d, name); err != nil {
+				return err
+			}
 		default:
-			logProp(name, "objectgroup")
-			jsonSkip(d)
+			if err := c.Extra.prop(d, name, "objectgroup"); err != nil {
+				return err
+			}
 		}
 	}
 
 	return nil
 }
 
+// Clone creates a deep copy of the Collision.
+func (c *Collision) Clone() *Collision {
+	dup := *c
+	dup.Objects = make([]Object, len(c.Objects))
+	for i := range c.Objects {
+		dup.Objects[i] = *c.Objects[i].Clone()
+	}
+	dup.Extra = c.Extra.clone()
+	return &dup
+}
+
 // vim: ts=4
@@ -34,6 +34,15 @@ func (c Color) String() string {
 	return fmt.Sprintf("#%02x%02x%02x%02x", c.A(), c.R(), c.G(), c.B())
 }
 
+// hex returns the color in the form written by the Tiled editor, which is "#RRGGBB" when the
+// color is fully opaque, otherwise "#AARRGGBB".
+func (c Color) hex() string {
+	if c.A() == 0xFF {
+		return fmt.Sprintf("#%02x%02x%02x", c.R(), c.G(), c.B())
+	}
+	return c.String()
+}
+
 // NewRGB creates a new fully opaque color from the specified values.
 func NewRGB(r, g, b uint8) Color {
 	return 0xFF000000 | (Color(b) << 16) | (Color(g) << 8) | Color(r)
@@ -2,10 +2,16 @@ package tmx
 
 import (
 	"encoding/json"
+	"encoding/xml"
 	"fmt"
 	"log"
+	"strconv"
 )
 
+// formatVersion is the version of the TMX format that is written when a document does not
+// specify its own.
+const formatVersion = "1.10"
+
 // ErrFormat is an error type used for format-related errors.
 type ErrFormat struct {
 	// Message describes the cause of the error.
@@ -117,4 +123,141 @@ func jsonSkip(d *json.Decoder) error {
 	return nil
 }
 
+// Canonical is a global configuration that determines whether documents are written in a
+// canonical form, where writing the same content always produces byte-identical output. This
+// is useful for generated files that are kept under version control.
+//
+// Properties are always written in alphabetical order by name, and attributes in a fixed order.
+// When set, the following are additionally applied:
+//
+//   - Floating-point values are written in fixed-point notation in both formats, with negative
+//     zero written as zero
+//   - Retained unknown attributes (see PreserveUnknown) are written in alphabetical order
+//   - Chunks of infinite maps are written ordered by row, then column
+var Canonical bool
+
+// xmlStr creates an XML attribute with the given name and string value.
+func xmlStr(name, value string) xml.Attr {
+	return xml.Attr{Name: xml.Name{Local: name}, Value: value}
+}
+
+// xmlInt creates an XML attribute with the given name and integer value.
+func xmlInt(name string, value int) xml.Attr {
+	return xmlStr(name, strconv.Itoa(value))
+}
+
+// xmlFloat creates an XML attribute with the given name and float value, formatted with the
+// minimum number of digits required to represent it.
+func xmlFloat(name string, value float64) xml.Attr {
+	return xmlStr(name, formatFloat(value))
+}
+
+// xmlFloat32 creates an XML attribute with the given name and 32-bit float value, formatted
+// with the minimum number of digits required to represent it.
+func xmlFloat32(name string, value float32) xml.Attr {
+	return xmlStr(name, formatFloat(value))
+}
+
+// formatFloat formats a float value in fixed-point notation, with the minimum number of digits
+// required to represent it. Negative zero is written as zero in canonical mode.
+func formatFloat[T float32 | float64](value T) string {
+	bits := 64
+	if _, ok := any(value).(float32); ok {
+		bits = 32
+	}
+	if Canonical && value == 0 {
+		value = 0
+	}
+	return strconv.FormatFloat(float64(value), 'f', -1, bits)
+}
+
+// jsonFloat returns a float value as it is written in the JSON format. In canonical mode, it is
+// formatted the same as it is in the XML format.
+func jsonFloat[T float32 | float64](value T) any {
+	if Canonical {
+		return json.Number(formatFloat(value))
+	}
+	return value
+}
+
+// xmlBool creates an XML attribute with the given name and boolean value. Booleans are written
+// as "1" and "0", as the Tiled editor expects.
+func xmlBool(name string, value bool) xml.Attr {
+	if value {
+		return xmlStr(name, "1")
+	}
+	return xmlStr(name, "0")
+}
+
+// xmlID creates an XML attribute with the given name and tile ID value.
+func xmlID(name string, id TileID) xml.Attr {
+	text, _ := id.MarshalText()
+	return xmlStr(name, string(text))
+}
+
+// xmlEncoding is implemented by values that are marshaled to XML within the document of an
+// encoder.
+type xmlEncoding interface {
+	encodeXML(enc *encoder, e *xml.Encoder, start xml.StartElement) error
+}
+
+// jsonEncoding is implemented by values that are marshaled to JSON within the document of an
+// encoder.
+type jsonEncoding interface {
+	encodeJSON(enc *encoder) ([]byte, error)
+}
+
+// xmlValue pairs a value with the encoder of the document it is written within, so that it can
+// be passed to an xml.Encoder.
+type xmlValue struct {
+	enc   *encoder
+	value xmlEncoding
+}
+
+// MarshalXML implements the xml.Marshaler interface.
+func (v xmlValue) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return v.value.encodeXML(v.enc, e, start)
+}
+
+// jsonValue pairs a value with the encoder of the document it is written within, so that it can
+// be passed to json.Marshal.
+type jsonValue struct {
+	enc   *encoder
+	value jsonEncoding
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (v jsonValue) MarshalJSON() ([]byte, error) {
+	return v.value.encodeJSON(v.enc)
+}
+
+// xml returns a value that writes the given value as XML within the document of the encoder.
+func (enc *encoder) xml(value xmlEncoding) xmlValue {
+	return xmlValue{enc: enc, value: value}
+}
+
+// json returns a value that writes the given value as JSON within the document of the encoder.
+func (enc *encoder) json(value jsonEncoding) jsonValue {
+	return jsonValue{enc: enc, value: value}
+}
+
+// jsonValues returns values that write each of the given values as JSON within the document of
+// the encoder, which is written as an empty array rather than null when there are none.
+func jsonValues[T jsonEncoding](enc *encoder, values []T) []jsonValue {
+	result := make([]jsonValue, len(values))
+	for i, value := range values {
+		result[i] = enc.json(value)
+	}
+	return result
+}
+
+// xmlEmpty writes an element with the given name and attributes, and no content.
+func xmlEmpty(e *xml.Encoder, name string, attrs ...xml.Attr) error {
+	start := xml.StartElement{Name: xml.Name{Local: name}, Attr: attrs}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
+
 // vim: ts=4
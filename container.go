@@ -1,5 +1,18 @@
 package tmx
 
+import (
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrNotChild is returned when a layer is expected to be a direct child of a container, but
+	// it is not.
+	ErrNotChild = errors.New("layer is not a child of the container")
+	// ErrCyclicLayer is returned when a group layer would be placed within itself.
+	ErrCyclicLayer = errors.New("group layer cannot be placed within itself")
+)
+
 // Container describes a type that implements a doubly linked-list of map layers.
 type Container interface {
 	// Head returns the first layer in a doubly linked-list of layers, or nil when the
@@ -10,8 +23,23 @@ type Container interface {
 	Tail() Layer
 	// Len returns the total number of layers within the container.
 	Len() int
-	// AddLayer appends a new layer to the container.
-	AddLayer(layer Layer)
+	// AddLayer appends a new layer to the container. When the layer is already within a
+	// container, it is moved. Returns ErrCyclicLayer without any effect when the layer is a
+	// group that contains the container.
+	AddLayer(layer Layer) error
+	// InsertBefore inserts a layer into the container before the mark layer, which must be a
+	// child of the container. When the layer is already within a container, it is moved.
+	InsertBefore(layer, mark Layer) error
+	// InsertAfter inserts a layer into the container after the mark layer, which must be a
+	// child of the container. When the layer is already within a container, it is moved.
+	InsertAfter(layer, mark Layer) error
+	// RemoveLayer removes a layer that is a child of the container.
+	RemoveLayer(layer Layer) error
+	// MoveLayer moves a layer that is a child of the container to the given index within it,
+	// where 0 is the head.
+	MoveLayer(layer Layer, index int) error
+
+	layers() *container
 }
 
 // container is a concrete implementation of the Container interface to be used as a composite
@@ -53,8 +81,60 @@ func (c *container) Len() int {
 	return len(c.TileLayers) + len(c.ImageLayers) + len(c.ObjectLayers) + len(c.GroupLayers)
 }
 
-// AddLayer appends a new layer to the container.
-func (c *container) AddLayer(layer Layer) {
+// layers implements the Container interface.
+func (c *container) layers() *container {
+	return c
+}
+
+// link inserts a layer into the linked-list before the mark layer, or appends it when the mark
+// is nil.
+func (c *container) link(layer, mark Layer) {
+	prev := c.tail
+	if mark != nil {
+		prev = mark.Prev()
+	}
+
+	layer.setPrev(prev)
+	layer.setNext(mark)
+	if prev != nil {
+		prev.setNext(layer)
+	} else {
+		c.head = layer
+	}
+	if mark != nil {
+		mark.setPrev(layer)
+	} else {
+		c.tail = layer
+	}
+
+	if mark == nil {
+		c.append(layer)
+	} else {
+		c.index()
+	}
+}
+
+// unlink removes a layer from the linked-list.
+func (c *container) unlink(layer Layer) {
+	prev, next := layer.Prev(), layer.Next()
+	if prev != nil {
+		prev.setNext(next)
+	} else {
+		c.head = next
+	}
+	if next != nil {
+		next.setPrev(prev)
+	} else {
+		c.tail = prev
+	}
+
+	layer.setPrev(nil)
+	layer.setNext(nil)
+	c.index()
+}
+
+// append adds a layer to the slice of its type.
+func (c *container) append(layer Layer) {
 	switch v := layer.(type) {
 	case *TileLayer:
 		c.TileLayers = append(c.TileLayers, v)
@@ -65,17 +145,109 @@ func (c *container) AddLayer(layer Layer) {
 	case *GroupLayer:
 		c.GroupLayers = append(c.GroupLayers, v)
 	}
+}
 
-	if c.head == nil {
-		c.head = layer
+// index rebuilds the slices of each layer type to match the order of the linked-list.
+func (c *container) index() {
+	c.TileLayers = nil
+	c.ImageLayers = nil
+	c.ObjectLayers = nil
+	c.GroupLayers = nil
+	for layer := c.head; layer != nil; layer = layer.Next() {
+		c.append(layer)
 	}
+}
+
+// insertLayer inserts a layer into the owner container before the mark layer, or appends it
+// when the mark is nil. The layer is first removed from any container it is currently within.
+func insertLayer(owner Container, layer, mark Layer) error {
+	if mark != nil && mark.Container() != owner {
+		return ErrNotChild
+	}
+	if layer == mark {
+		return nil
+	}
+
+	// Prevent a group from being placed within itself or its descendants
+	if group, ok := layer.(*GroupLayer); ok {
+		for c := owner; c != nil; {
+			if c == Container(group) {
+				return ErrCyclicLayer
+			}
+			parent, ok := c.(Layer)
+			if !ok {
+				break
+			}
+			c = parent.Container()
+		}
+	}
+
+	prev := layer.Map()
+	if current := layer.Container(); current != nil {
+		current.layers().unlink(layer)
+	}
+
+	owner.layers().link(layer, mark)
+	layer.setContainer(owner)
+
+	tilemap := containerMap(owner)
+	layer.setParent(tilemap)
+	if tilemap != nil {
+		tilemap.claimLayerIDs(layer, prev != nil && prev != tilemap)
+	}
+	return nil
+}
+
+// removeLayer removes a layer that is a child of the owner container.
+func removeLayer(owner Container, layer Layer) error {
+	if layer == nil || layer.Container() != owner {
+		return ErrNotChild
+	}
+	owner.layers().unlink(layer)
+	layer.setContainer(nil)
+	layer.setParent(nil)
+	return nil
+}
+
+// moveLayer moves a layer that is a child of the owner container to the given index.
+func moveLayer(owner Container, layer Layer, index int) error {
+	if layer == nil || layer.Container() != owner {
+		return ErrNotChild
+	}
+	if index < 0 || index >= owner.Len() {
+		return fmt.Errorf("layer index %d out of range", index)
+	}
+
+	c := owner.layers()
+	c.unlink(layer)
+	mark := c.head
+	for i := 0; i < index && mark != nil; i++ {
+		mark = mark.Next()
+	}
+	c.link(layer, mark)
+	return nil
+}
+
+// walkLayers calls fn for each layer within the container in order, descending into groups.
+func walkLayers(owner Container, fn func(layer Layer)) {
+	for layer := owner.Head(); layer != nil; layer = layer.Next() {
+		fn(layer)
+		if group, ok := layer.(*GroupLayer); ok {
+			walkLayers(group, fn)
+		}
+	}
+}
 
-	if c.tail != nil {
-		c.tail.setNext(layer)
-		layer.setPrev(c.tail)
+// containerMap returns the map that a container belongs to, if any.
+func containerMap(owner Container) *Map {
+	switch value := owner.(type) {
+	case *Map:
+		return value
+	case Layer:
+		return value.Map()
+	default:
+		return nil
 	}
-	c.tail = layer
-	c.head.setContainer(c)
 }
 
 // vim: ts=4
@@ -0,0 +1,224 @@
+package tmx
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+// testTile returns a new tile layer with the given name.
+func testTile(name string) *TileLayer {
+	return &TileLayer{baseLayer: baseLayer{Name: name}}
+}
+
+// testGroup returns a new group layer with the given name, containing the given layers.
+func testGroup(name string, layers ...Layer) *GroupLayer {
+	group := &GroupLayer{baseLayer: baseLayer{Name: name}}
+	for _, layer := range layers {
+		group.AddLayer(layer)
+	}
+	return group
+}
+
+// testLayers returns a new map containing the given layers.
+func testLayers(layers ...Layer) *Map {
+	m := &Map{NextLayerId: 1}
+	for _, layer := range layers {
+		m.AddLayer(layer)
+	}
+	return m
+}
+
+// checkLinks reports when the direct children of a container are not the named layers, linked in
+// both directions, with the container as their parent.
+func checkLinks(t *testing.T, owner Container, names ...string) {
+	t.Helper()
+
+	var forward, backward []string
+	var prev Layer
+	for layer := owner.Head(); layer != nil; layer = layer.Next() {
+		forward = append(forward, layerName(layer))
+		if layer.Prev() != prev {
+			t.Errorf("%s: previous layer is %v", layerName(layer), layer.Prev())
+		}
+		if layer.Container() != owner {
+			t.Errorf("%s: container is %v", layerName(layer), layer.Container())
+		}
+		if layer.Map() != containerMap(owner) {
+			t.Errorf("%s: map is %v", layerName(layer), layer.Map())
+		}
+		prev = layer
+	}
+	if owner.Tail() != prev {
+		t.Errorf("tail is %v, want %v", owner.Tail(), prev)
+	}
+	for layer := owner.Tail(); layer != nil; layer = layer.Prev() {
+		backward = append([]string{layerName(layer)}, backward...)
+	}
+	if !slices.Equal(forward, names) || !slices.Equal(backward, names) || owner.Len() != len(names) {
+		t.Errorf("layers are %v (reversed %v), with length %d, want %v", forward, backward, owner.Len(), names)
+	}
+}
+
+func TestInsertLayer(t *testing.T) {
+	a, b, c := testTile("a"), testTile("b"), testTile("c")
+	m := testLayers(a, b, c)
+	checkLinks(t, m, "a", "b", "c")
+
+	tests := []struct {
+		name  string
+		apply func() error
+		want  []string
+	}{
+		{"before head", func() error { return m.InsertBefore(testTile("d"), a) }, []string{"d", "a", "b", "c"}},
+		{"before", func() error { return m.InsertBefore(testTile("e"), c) }, []string{"d", "a", "b", "e", "c"}},
+		{"after tail", func() error { return m.InsertAfter(testTile("f"), c) }, []string{"d", "a", "b", "e", "c", "f"}},
+		{"after", func() error { return m.InsertAfter(testTile("g"), a) }, []string{"d", "a", "g", "b", "e", "c", "f"}},
+		{"move before", func() error { return m.InsertBefore(c, a) }, []string{"d", "c", "a", "g", "b", "e", "f"}},
+		{"move after", func() error { return m.InsertAfter(a, m.Tail()) }, []string{"d", "c", "g", "b", "e", "f", "a"}},
+		{"before itself", func() error { return m.InsertBefore(b, b) }, []string{"d", "c", "g", "b", "e", "f", "a"}},
+		{"remove head", func() error { return m.RemoveLayer(m.Head()) }, []string{"c", "g", "b", "e", "f", "a"}},
+		{"remove tail", func() error { return m.RemoveLayer(a) }, []string{"c", "g", "b", "e", "f"}},
+		{"remove", func() error { return m.RemoveLayer(b) }, []string{"c", "g", "e", "f"}},
+		{"move to head", func() error { return m.MoveLayer(m.Tail(), 0) }, []string{"f", "c", "g", "e"}},
+		{"move to tail", func() error { return m.MoveLayer(c, 3) }, []string{"f", "g", "e", "c"}},
+		{"move", func() error { return m.MoveLayer(m.Head(), 2) }, []string{"g", "e", "f", "c"}},
+	}
+	for _, test := range tests {
+		if err := test.apply(); err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		checkLinks(t, m, test.want...)
+	}
+
+	// Removed layers are no longer linked to the map
+	if a.Map() != nil || a.Container() != nil || a.Next() != nil || a.Prev() != nil {
+		t.Errorf("removed layer is still linked")
+	}
+	if len(m.TileLayers) != 4 || m.TileLayers[0].Name != "g" || m.TileLayers[3].Name != "c" {
+		t.Errorf("tile layers are not in order of the list: %v", m.TileLayers)
+	}
+}
+
+func TestInsertLayerErrors(t *testing.T) {
+	a, b, child := testTile("a"), testTile("b"), testTile("child")
+	group := testGroup("group", child)
+	m := testLayers(a, b, group)
+	other := testTile("other")
+
+	tests := []struct {
+		name  string
+		apply func() error
+		want  error
+	}{
+		{"before nil", func() error { return m.InsertBefore(other, nil) }, ErrNotChild},
+		{"after nil", func() error { return m.InsertAfter(other, nil) }, ErrNotChild},
+		{"before detached", func() error { return m.InsertBefore(other, testTile("x")) }, ErrNotChild},
+		{"before nested", func() error { return m.InsertBefore(other, child) }, ErrNotChild},
+		{"after nested", func() error { return m.InsertAfter(other, child) }, ErrNotChild},
+		{"group before map layer", func() error { return group.InsertBefore(other, a) }, ErrNotChild},
+		{"remove detached", func() error { return m.RemoveLayer(other) }, ErrNotChild},
+		{"remove nested", func() error { return m.RemoveLayer(child) }, ErrNotChild},
+		{"remove nil", func() error { return m.RemoveLayer(nil) }, ErrNotChild},
+		{"move nested", func() error { return m.MoveLayer(child, 0) }, ErrNotChild},
+		{"group within itself", func() error { return group.InsertBefore(group, child) }, ErrCyclicLayer},
+		{"group after itself", func() error { return group.InsertAfter(group, child) }, ErrCyclicLayer},
+	}
+	for _, test := range tests {
+		if err := test.apply(); !errors.Is(err, test.want) {
+			t.Errorf("%s: error is %v, want %v", test.name, err, test.want)
+		}
+	}
+	for _, index := range []int{-1, 3} {
+		if err := m.MoveLayer(a, index); err == nil {
+			t.Errorf("expected an error moving to index %d", index)
+		}
+	}
+
+	// Nothing was changed by the failed operations
+	checkLinks(t, m, "a", "b", "group")
+	checkLinks(t, group, "child")
+	if other.Container() != nil {
+		t.Errorf("layer was inserted")
+	}
+}
+
+func TestInsertCyclicLayer(t *testing.T) {
+	leaf := testTile("leaf")
+	inner := testGroup("inner", leaf)
+	outer := testGroup("outer", inner)
+	m := testLayers(outer)
+
+	// A group cannot be placed within itself or any of its descendants
+	if err := inner.InsertBefore(outer, leaf); !errors.Is(err, ErrCyclicLayer) {
+		t.Errorf("error is %v, want %v", err, ErrCyclicLayer)
+	}
+	for _, owner := range []*GroupLayer{outer, inner} {
+		if err := owner.AddLayer(outer); !errors.Is(err, ErrCyclicLayer) {
+			t.Errorf("adding to %s: error is %v, want %v", owner.Name, err, ErrCyclicLayer)
+		}
+	}
+	checkLinks(t, m, "outer")
+	checkLinks(t, outer, "inner")
+	checkLinks(t, inner, "leaf")
+
+	// Groups can be moved into a sibling group
+	sibling := testGroup("sibling")
+	m.AddLayer(sibling)
+	if err := sibling.InsertBefore(inner, nil); !errors.Is(err, ErrNotChild) {
+		t.Errorf("error is %v, want %v", err, ErrNotChild)
+	}
+	if err := sibling.AddLayer(inner); err != nil {
+		t.Fatal(err)
+	}
+	checkLinks(t, outer)
+	checkLinks(t, sibling, "inner")
+	if leaf.Map() != m || leaf.Container() != Container(inner) {
+		t.Errorf("nested layer is not within the map")
+	}
+}
+
+func TestLayerIDs(t *testing.T) {
+	a := testTile("a")
+	a.ID = 5
+	m := testLayers(a, testTile("b"))
+	if a.ID != 5 || m.TileLayers[1].ID != 6 || m.NextLayerId != 7 {
+		t.Fatalf("IDs are %d and %d, with next %d", a.ID, m.TileLayers[1].ID, m.NextLayerId)
+	}
+
+	// Layers moved from another map claim new IDs, along with the layers within them
+	child := testTile("child")
+	group := testGroup("group", child)
+	other := testLayers(group)
+	if group.ID != 1 || child.ID != 2 || other.NextLayerId != 3 {
+		t.Fatalf("IDs are %d and %d, with next %d", group.ID, child.ID, other.NextLayerId)
+	}
+	m.InsertBefore(group, a)
+	if group.ID != 7 || child.ID != 8 || m.NextLayerId != 9 {
+		t.Errorf("IDs are %d and %d, with next %d", group.ID, child.ID, m.NextLayerId)
+	}
+	if other.Len() != 0 || other.Head() != nil || other.Tail() != nil {
+		t.Errorf("layer was not removed from its previous map")
+	}
+	if child.Map() != m {
+		t.Errorf("nested layer is not within the map")
+	}
+	checkLinks(t, m, "group", "a", "b")
+
+	// Layers moved within the same map keep their IDs
+	m.AddLayer(child)
+	if child.ID != 8 || m.NextLayerId != 9 {
+		t.Errorf("ID is %d, with next %d", child.ID, m.NextLayerId)
+	}
+	checkLinks(t, m, "group", "a", "b", "child")
+	checkLinks(t, group)
+
+	// Removed layers keep their ID when added back
+	m.RemoveLayer(a)
+	m.AddLayer(a)
+	if a.ID != 5 || m.NextLayerId != 9 {
+		t.Errorf("ID is %d, with next %d", a.ID, m.NextLayerId)
+	}
+}
+
+// vim: ts=4
@@ -0,0 +1,205 @@
+package tmx
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrOverwriteSource is returned when a converted file would be written over a file it was
+// converted from.
+var ErrOverwriteSource = errors.New("converted file would overwrite a source file")
+
+// Convert reads the map at the source path and writes it to the destination path using the
+// specified format. When the format is FormatUnknown, it will be detected based on the file
+// extension of the destination path.
+//
+// Every external tileset and template referenced by the map is converted as well, and written
+// to the same location relative to the destination map as the original is to the source map,
+// with its extension changed to match the format (e.g. ".tsx" to ".tsj", ".tx" to ".tj").
+// References to these files are rewritten in the output as relative paths. Files outside the
+// directory of the source map are written to the directory of the destination map instead, and
+// no file is written over any of the files being converted.
+func Convert(srcPath, dstPath string, format Format) error {
+	if format == FormatUnknown {
+		format = DetectExt(dstPath)
+	}
+	if format != FormatXML && format != FormatJSON {
+		return errInvalidEnum("Format", fmt.Sprintf("Format(%d)", format))
+	}
+
+	tilemap, err := ReadMap(srcPath, FormatUnknown, NewCache())
+	if err != nil {
+		return err
+	}
+
+	dst, err := filepath.Abs(dstPath)
+	if err != nil {
+		return err
+	}
+
+	if dst == tilemap.Source {
+		return ErrOverwriteSource
+	}
+
+	c := converter{
+		format:  format,
+		srcDir:  filepath.Dir(tilemap.Source),
+		dstDir:  filepath.Dir(dst),
+		targets: map[string]string{tilemap.Source: dst, dst: dst},
+	}
+
+	for _, tileset := range tilemap.Tilesets {
+		if err := c.tileset(tileset); err != nil {
+			return err
+		}
+	}
+	if err := c.layers(tilemap.Head()); err != nil {
+		return err
+	}
+
+	if err := c.write(dst, tilemap, tilemap.Source); err != nil {
+		return err
+	}
+	tilemap.Source = dst
+	return nil
+}
+
+// converter maintains the state of a Convert operation.
+type converter struct {
+	// format is the format being converted to.
+	format Format
+	// srcDir is the directory of the source map.
+	srcDir string
+	// dstDir is the directory of the destination map.
+	dstDir string
+	// targets maps the paths of files that have been converted to their destination paths.
+	targets map[string]string
+}
+
+// target returns the destination path for a referenced file, and whether it still requires
+// being converted.
+func (c *converter) target(source string, ext [2]string) (string, bool, error) {
+	if target, ok := c.targets[source]; ok {
+		return target, false, nil
+	}
+
+	rel, err := filepath.Rel(c.srcDir, source)
+	if err != nil || !filepath.IsLocal(rel) {
+		// Place files outside of the source directory beside the destination map
+		rel = filepath.Base(source)
+	}
+	rel = strings.TrimSuffix(rel, filepath.Ext(rel))
+	newExt := ext[0]
+	if c.format == FormatJSON {
+		newExt = ext[1]
+	}
+
+	target := filepath.Join(c.dstDir, rel+newExt)
+	for n := 2; ; n++ {
+		if target == source {
+			return "", false, ErrOverwriteSource
+		}
+		if existing, ok := c.targets[target]; !ok {
+			break
+		} else if existing != target {
+			// Another file being converted is at the path
+			return "", false, ErrOverwriteSource
+		}
+		target = filepath.Join(c.dstDir, fmt.Sprintf("%s_%d%s", rel, n, newExt))
+	}
+	c.targets[source] = target
+	c.targets[target] = target
+	return target, true, nil
+}
+
+// write writes a converted file, where src is the path of the file it was converted from.
+func (c *converter) write(path string, obj any, src string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return writeFile(path, c.format, obj, src)
+}
+
+// tileset converts an external tileset, along with any templates used by its tiles. Embedded
+// tilesets remain embedded.
+func (c *converter) tileset(tileset *MapTileset) error {
+	if tileset == nil || tileset.Tileset == nil {
+		return nil
+	}
+
+	for i := range tileset.Tiles {
+		if collision := tileset.Tiles[i].Collision; collision != nil {
+			for j := range collision.Objects {
+				if err := c.object(&collision.Objects[j]); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	if tileset.Source == "" || tileset.Embedded {
+		return nil
+	}
+	target, convert, err := c.target(tileset.Source, [2]string{".tsx", ".tsj"})
+	if err != nil {
+		return err
+	}
+	if convert {
+		if err := c.write(target, tileset.Tileset, tileset.Source); err != nil {
+			return err
+		}
+	}
+	tileset.Source = target
+	return nil
+}
+
+// template converts a template, along with its tileset.
+func (c *converter) template(template *Template) error {
+	if err := c.tileset(template.Tileset); err != nil {
+		return err
+	}
+
+	target, convert, err := c.target(template.Source, [2]string{".tx", ".tj"})
+	if err != nil {
+		return err
+	}
+	if convert {
+		if err := c.write(target, template, template.Source); err != nil {
+			return err
+		}
+	}
+	template.Source = target
+	return nil
+}
+
+// object converts the template used by an object, if any.
+func (c *converter) object(obj *Object) error {
+	if obj.Template == nil {
+		return nil
+	}
+	return c.template(obj.Template)
+}
+
+// layers converts the templates used by objects in the given layer, and all that follow it.
+func (c *converter) layers(head Layer) error {
+	for layer := head; layer != nil; layer = layer.Next() {
+		switch value := layer.(type) {
+		case *ObjectLayer:
+			for i := range value.Objects {
+				if err := c.object(&value.Objects[i]); err != nil {
+					return err
+				}
+			}
+		case *GroupLayer:
+			if err := c.layers(value.Head()); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// vim: ts=4
@@ -0,0 +1,183 @@
+package tmx
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeFiles creates files within a directory from their relative paths and contents.
+func writeFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+// readFile returns the contents of a file as a string.
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+// externalFiles is a map that uses an external tileset and a template, which uses the same
+// tileset, in directories beneath the map.
+var externalFiles = map[string]string{
+	"level.tmx": `<?xml version="1.0" encoding="UTF-8"?>
+<map version="1.10" orientation="orthogonal" width="2" height="2" tilewidth="16" tileheight="16" nextlayerid="3" nextobjectid="2">
+ <tileset firstgid="1" source="tiles/terrain.tsx"/>
+ <layer id="1" name="ground" width="2" height="2">
+  <data encoding="csv">1,2,3,4</data>
+ </layer>
+ <objectgroup id="2" name="objects">
+  <object id="1" template="templates/chest.tx" x="16" y="16"/>
+ </objectgroup>
+</map>
+`,
+	"tiles/terrain.tsx": `<?xml version="1.0" encoding="UTF-8"?>
+<tileset version="1.10" name="terrain" tilewidth="16" tileheight="16" tilecount="4" columns="2">
+ <image source="terrain.png" width="32" height="32"/>
+</tileset>
+`,
+	"templates/chest.tx": `<?xml version="1.0" encoding="UTF-8"?>
+<template>
+ <tileset firstgid="1" source="../tiles/terrain.tsx"/>
+ <object name="chest" gid="3" width="16" height="16"/>
+</template>
+`,
+}
+
+func TestConvert(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, externalFiles)
+
+	dst := filepath.Join(dir, "out", "level.tmj")
+	if err := Convert(filepath.Join(dir, "level.tmx"), dst, FormatUnknown); err != nil {
+		t.Fatal(err)
+	}
+
+	// Referenced files are converted to the same location relative to the new map
+	files := map[string][]string{
+		"out/level.tmj":          {`"source": "tiles/terrain.tsj"`, `"template": "templates/chest.tj"`},
+		"out/tiles/terrain.tsj":  {`"name": "terrain"`, `"image": "../../tiles/terrain.png"`},
+		"out/templates/chest.tj": {`"source": "../tiles/terrain.tsj"`, `"name": "chest"`},
+	}
+	for name, contents := range files {
+		doc := readFile(t, filepath.Join(dir, filepath.FromSlash(name)))
+		for _, content := range contents {
+			if !strings.Contains(doc, content) {
+				t.Errorf("%s does not contain %s:\n%s", name, content, doc)
+			}
+		}
+	}
+
+	// The converted map can be read, along with the files it refers to
+	m, err := ReadMap(dst, FormatUnknown, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Tilesets) != 1 || m.Tilesets[0].Name != "terrain" {
+		t.Errorf("converted map has tilesets %v", m.Tilesets)
+	}
+	obj := &m.ObjectLayers[0].Objects[0]
+	if obj.Template == nil || obj.Template.Object.Name != "chest" || obj.Template.Tileset == nil ||
+		obj.Template.Tileset.Name != "terrain" {
+		t.Errorf("converted template is %+v", obj.Template)
+	}
+}
+
+// outsideFiles is a map that uses tilesets and a template outside of its directory, two of which
+// have the same name.
+var outsideFiles = map[string]string{
+	"maps/level.tmx": `<?xml version="1.0" encoding="UTF-8"?>
+<map version="1.10" orientation="orthogonal" width="2" height="2" tilewidth="16" tileheight="16" nextlayerid="2" nextobjectid="2">
+ <tileset firstgid="1" source="../shared/terrain.tsx"/>
+ <tileset firstgid="5" source="../other/terrain.tsx"/>
+ <objectgroup id="1" name="objects">
+  <object id="1" template="../shared/chest.tx" x="16" y="16"/>
+ </objectgroup>
+</map>
+`,
+	"shared/terrain.tsx": `<?xml version="1.0" encoding="UTF-8"?>
+<tileset version="1.10" name="terrain" tilewidth="16" tileheight="16" tilecount="4" columns="2">
+ <image source="terrain.png" width="32" height="32"/>
+</tileset>
+`,
+	"other/terrain.tsx": `<?xml version="1.0" encoding="UTF-8"?>
+<tileset version="1.10" name="other" tilewidth="16" tileheight="16" tilecount="4" columns="2">
+ <image source="other.png" width="32" height="32"/>
+</tileset>
+`,
+	"shared/chest.tx": `<?xml version="1.0" encoding="UTF-8"?>
+<template>
+ <tileset firstgid="1" source="terrain.tsx"/>
+ <object name="chest" gid="3" width="16" height="16"/>
+</template>
+`,
+}
+
+func TestConvertOutside(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, outsideFiles)
+
+	dst := filepath.Join(dir, "out", "level.tmj")
+	if err := Convert(filepath.Join(dir, "maps", "level.tmx"), dst, FormatUnknown); err != nil {
+		t.Fatal(err)
+	}
+
+	// Files outside of the directory of the map are placed beside the new map
+	files := map[string][]string{
+		"out/level.tmj":     {`"source": "terrain.tsj"`, `"source": "terrain_2.tsj"`, `"template": "chest.tj"`},
+		"out/terrain.tsj":   {`"name": "terrain"`, `"image": "../shared/terrain.png"`},
+		"out/terrain_2.tsj": {`"name": "other"`, `"image": "../other/other.png"`},
+		"out/chest.tj":      {`"source": "terrain.tsj"`, `"name": "chest"`},
+	}
+	for name, contents := range files {
+		doc := readFile(t, filepath.Join(dir, filepath.FromSlash(name)))
+		for _, content := range contents {
+			if !strings.Contains(doc, content) {
+				t.Errorf("%s does not contain %s:\n%s", name, content, doc)
+			}
+		}
+	}
+	for _, name := range []string{"shared/terrain.tsj", "shared/chest.tj", "terrain.tsj"} {
+		if _, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name))); err == nil {
+			t.Errorf("%s was written outside of the destination", name)
+		}
+	}
+}
+
+func TestConvertOverwrite(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, externalFiles)
+	src := filepath.Join(dir, "level.tmx")
+
+	// Writing the map over itself, or its tileset over itself when in the same directory
+	for _, dst := range []string{"level.tmx", "copy.tmx"} {
+		err := Convert(src, filepath.Join(dir, dst), FormatUnknown)
+		if !errors.Is(err, ErrOverwriteSource) {
+			t.Errorf("%s: error is %v, want %v", dst, err, ErrOverwriteSource)
+		}
+	}
+	for name, content := range externalFiles {
+		if doc := readFile(t, filepath.Join(dir, filepath.FromSlash(name))); doc != content {
+			t.Errorf("%s was overwritten:\n%s", name, doc)
+		}
+	}
+}
+
+// vim: ts=4
@@ -0,0 +1,219 @@
+package tmx
+
+import "math"
+
+// TileToPixel returns the position of the top-left corner of the bounding box of a tile in pixel
+// units, for the orientation of the map. The center of the tile is found by adding half of the
+// TileSize to the result.
+//
+// The position is the same as where the Tiled editor renders the tile, where for isometric maps,
+// the origin is at the top-left corner of the bounding box of the entire map.
+func (m *Map) TileToPixel(p Point) Vec2 {
+	tw, th := m.TileSize.Width, m.TileSize.Height
+	switch m.Orientation {
+	case Isometric:
+		originX := m.Size.Height * tw / 2
+		return Vec2{
+			X: float32((p.X-p.Y)*tw/2+originX) - float32(tw)/2,
+			Y: float32((p.X + p.Y) * th / 2),
+		}
+	case Staggered, Hexagonal:
+		hp := m.hexParams()
+		if hp.staggerX {
+			y := p.Y * (hp.tileHeight + hp.sideLengthY)
+			if hp.doStagger(p.X) {
+				y += hp.rowHeight
+			}
+			return Vec2{X: float32(p.X * hp.columnWidth), Y: float32(y)}
+		}
+		x := p.X * (hp.tileWidth + hp.sideLengthX)
+		if hp.doStagger(p.Y) {
+			x += hp.columnWidth
+		}
+		return Vec2{X: float32(x), Y: float32(p.Y * hp.rowHeight)}
+	default:
+		return Vec2{X: float32(p.X * tw), Y: float32(p.Y * th)}
+	}
+}
+
+// PixelToTile returns the position of the tile in tile units that contains a position in pixel
+// units, for the orientation of the map. This is the inverse of TileToPixel, and is suitable for
+// picking the tile beneath a cursor.
+//
+// The result is not restricted to the bounds of the map.
+func (m *Map) PixelToTile(v Vec2) Point {
+	x, y := float64(v.X), float64(v.Y)
+	tw, th := float64(m.TileSize.Width), float64(m.TileSize.Height)
+	switch m.Orientation {
+	case Isometric:
+		x -= float64(m.Size.Height) * tw / 2
+		ty, tx := y/th, x/tw
+		return Point{X: int(math.Floor(ty + tx)), Y: int(math.Floor(ty - tx))}
+	case Staggered:
+		hp := m.hexParams()
+		return hp.staggeredToTile(x, y)
+	case Hexagonal:
+		hp := m.hexParams()
+		return hp.hexagonalToTile(x, y)
+	default:
+		return Point{X: int(math.Floor(x / tw)), Y: int(math.Floor(y / th))}
+	}
+}
+
+// hexParams contains the dimensions used to position tiles on staggered and hexagonal maps,
+// matching those used by the renderers of the Tiled editor.
+type hexParams struct {
+	tileWidth   int
+	tileHeight  int
+	sideLengthX int
+	sideLengthY int
+	sideOffsetX int
+	sideOffsetY int
+	columnWidth int
+	rowHeight   int
+	staggerX    bool
+	staggerEven bool
+}
+
+// hexParams returns the dimensions used to position tiles on staggered and hexagonal maps.
+func (m *Map) hexParams() hexParams {
+	hp := hexParams{
+		tileWidth:   m.TileSize.Width &^ 1,
+		tileHeight:  m.TileSize.Height &^ 1,
+		staggerX:    m.StaggerAxis == StaggerX,
+		staggerEven: m.StaggerIndex == StaggerEven,
+	}
+	if m.Orientation == Hexagonal {
+		if hp.staggerX {
+			hp.sideLengthX = m.HexSideLength
+		} else {
+			hp.sideLengthY = m.HexSideLength
+		}
+	}
+	hp.sideOffsetX = (hp.tileWidth - hp.sideLengthX) / 2
+	hp.sideOffsetY = (hp.tileHeight - hp.sideLengthY) / 2
+	hp.columnWidth = hp.sideOffsetX + hp.sideLengthX
+	hp.rowHeight = hp.sideOffsetY + hp.sideLengthY
+	return hp
+}
+
+// doStagger tests whether the row or column at the given index along the stagger axis is
+// shifted.
+func (hp *hexParams) doStagger(index int) bool {
+	return (index&1 == 1) != hp.staggerEven
+}
+
+// staggeredToTile returns the tile containing a pixel position on a staggered map.
+func (hp *hexParams) staggeredToTile(x, y float64) Point {
+	if hp.staggerX && hp.staggerEven {
+		x -= float64(hp.sideOffsetX)
+	} else if !hp.staggerX && hp.staggerEven {
+		y -= float64(hp.sideOffsetY)
+	}
+
+	// Start with the coordinates of a grid-aligned tile
+	tw, th := float64(hp.tileWidth), float64(hp.tileHeight)
+	ref := Point{X: int(math.Floor(x / tw)), Y: int(math.Floor(y / th))}
+	relX, relY := x-float64(ref.X)*tw, y-float64(ref.Y)*th
+	ref = hp.staggerReference(ref)
+
+	// Check whether the position is in any of the corners, which belong to neighboring tiles
+	side := float64(hp.sideOffsetY)
+	yPos := relX * (th / tw)
+	switch {
+	case side-yPos > relY:
+		return hp.neighbor(ref, -1, -1)
+	case -side+yPos > relY:
+		return hp.neighbor(ref, 1, -1)
+	case side+yPos < relY:
+		return hp.neighbor(ref, -1, 1)
+	case side*3-yPos < relY:
+		return hp.neighbor(ref, 1, 1)
+	}
+	return ref
+}
+
+// hexagonalToTile returns the tile containing a pixel position on a hexagonal map.
+func (hp *hexParams) hexagonalToTile(x, y float64) Point {
+	if hp.staggerX {
+		if hp.staggerEven {
+			x -= float64(hp.tileWidth)
+		} else {
+			x -= float64(hp.sideOffsetX)
+		}
+	} else {
+		if hp.staggerEven {
+			y -= float64(hp.tileHeight)
+		} else {
+			y -= float64(hp.sideOffsetY)
+		}
+	}
+
+	// Start with the coordinates of a grid-aligned pair of tiles
+	cw, rh := float64(hp.columnWidth*2), float64(hp.rowHeight*2)
+	ref := Point{X: int(math.Floor(x / cw)), Y: int(math.Floor(y / rh))}
+	relX, relY := x-float64(ref.X)*cw, y-float64(ref.Y)*rh
+	ref = hp.staggerReference(ref)
+
+	// Determine the nearest hexagon by the distance to its center
+	var centers [4]Vec2
+	var offsets [4]Point
+	if hp.staggerX {
+		left := float32(hp.sideLengthX / 2)
+		cx := left + float32(hp.columnWidth)
+		cy := float32(hp.tileHeight / 2)
+		rh := float32(hp.rowHeight)
+		centers = [4]Vec2{{left, cy}, {cx, cy - rh}, {cx, cy + rh}, {cx + float32(hp.columnWidth), cy}}
+		offsets = [4]Point{{0, 0}, {1, -1}, {1, 0}, {2, 0}}
+	} else {
+		top := float32(hp.sideLengthY / 2)
+		cx := float32(hp.tileWidth / 2)
+		cy := top + float32(hp.rowHeight)
+		cw := float32(hp.columnWidth)
+		centers = [4]Vec2{{cx, top}, {cx - cw, cy}, {cx + cw, cy}, {cx, cy + float32(hp.rowHeight)}}
+		offsets = [4]Point{{0, 0}, {-1, 1}, {0, 1}, {0, 2}}
+	}
+
+	nearest, dist := 0, math.MaxFloat64
+	for i, center := range centers {
+		dx, dy := float64(center.X)-relX, float64(center.Y)-relY
+		if d := dx*dx + dy*dy; d < dist {
+			nearest, dist = i, d
+		}
+	}
+	return Point{X: ref.X + offsets[nearest].X, Y: ref.Y + offsets[nearest].Y}
+}
+
+// staggerReference converts the position of a grid-aligned pair of tiles to the tile coordinates
+// of the first of them.
+func (hp *hexParams) staggerReference(ref Point) Point {
+	index := &ref.Y
+	if hp.staggerX {
+		index = &ref.X
+	}
+	*index *= 2
+	if hp.staggerEven {
+		*index++
+	}
+	return ref
+}
+
+// neighbor returns a diagonally adjacent tile on a staggered or hexagonal map, in the given
+// direction on each axis, where -1 is towards the top or left, and 1 is towards the bottom or
+// right.
+func (hp *hexParams) neighbor(p Point, dx, dy int) Point {
+	if hp.staggerX {
+		// Staggered columns share rows with their neighbors when shifted down
+		if hp.doStagger(p.X) {
+			return Point{X: p.X + dx, Y: p.Y + max(dy, 0)}
+		}
+		return Point{X: p.X + dx, Y: p.Y + min(dy, 0)}
+	}
+	// Staggered rows share columns with their neighbors when shifted right
+	if hp.doStagger(p.Y) {
+		return Point{X: p.X + max(dx, 0), Y: p.Y + dy}
+	}
+	return Point{X: p.X + min(dx, 0), Y: p.Y + dy}
+}
+
+// vim: ts=4
@@ -0,0 +1,90 @@
+package tmx
+
+import (
+	"fmt"
+	"testing"
+)
+
+// staggeredMap returns a staggered or hexagonal map with the given stagger axis and index.
+func staggeredMap(orientation Orientation, axis StaggerAxis, index StaggerIndex, tileSize Size, side int) *Map {
+	m := NewMap(orientation, Size{Width: 8, Height: 8}, tileSize)
+	m.StaggerAxis = axis
+	m.StaggerIndex = index
+	m.HexSideLength = side
+	return m
+}
+
+// coordMaps returns a map of each orientation, including each stagger axis and index of
+// staggered and hexagonal maps.
+func coordMaps() []*Map {
+	maps := []*Map{
+		NewMap(Orthogonal, Size{Width: 8, Height: 8}, Size{Width: 16, Height: 16}),
+		NewMap(Isometric, Size{Width: 8, Height: 6}, Size{Width: 32, Height: 16}),
+	}
+	for _, axis := range []StaggerAxis{StaggerX, StaggerY} {
+		for _, index := range []StaggerIndex{StaggerEven, StaggerOdd} {
+			maps = append(maps,
+				staggeredMap(Staggered, axis, index, Size{Width: 32, Height: 16}, 0),
+				staggeredMap(Hexagonal, axis, index, Size{Width: 32, Height: 28}, 12),
+			)
+		}
+	}
+	return maps
+}
+
+// mapName describes the orientation of a map for test failures.
+func mapName(m *Map) string {
+	if m.Orientation == Staggered || m.Orientation == Hexagonal {
+		return fmt.Sprintf("%v/%v/%v", m.Orientation, m.StaggerAxis, m.StaggerIndex)
+	}
+	return m.Orientation.String()
+}
+
+func TestTileToPixel(t *testing.T) {
+	tests := []struct {
+		m    *Map
+		tile Point
+		want Vec2
+	}{
+		{NewMap(Orthogonal, Size{Width: 8, Height: 8}, Size{Width: 16, Height: 16}), Point{X: 3, Y: 5}, Vec2{X: 48, Y: 80}},
+		{NewMap(Isometric, Size{Width: 4, Height: 4}, Size{Width: 32, Height: 16}), Point{X: 0, Y: 0}, Vec2{X: 48, Y: 0}},
+		{NewMap(Isometric, Size{Width: 4, Height: 4}, Size{Width: 32, Height: 16}), Point{X: 2, Y: 1}, Vec2{X: 64, Y: 24}},
+		{staggeredMap(Staggered, StaggerY, StaggerOdd, Size{Width: 32, Height: 16}, 0), Point{X: 2, Y: 1}, Vec2{X: 80, Y: 8}},
+		{staggeredMap(Staggered, StaggerY, StaggerOdd, Size{Width: 32, Height: 16}, 0), Point{X: 2, Y: 2}, Vec2{X: 64, Y: 16}},
+		{staggeredMap(Staggered, StaggerY, StaggerEven, Size{Width: 32, Height: 16}, 0), Point{X: 2, Y: 1}, Vec2{X: 64, Y: 8}},
+		{staggeredMap(Staggered, StaggerY, StaggerEven, Size{Width: 32, Height: 16}, 0), Point{X: 2, Y: 2}, Vec2{X: 80, Y: 16}},
+		{staggeredMap(Staggered, StaggerX, StaggerOdd, Size{Width: 32, Height: 16}, 0), Point{X: 1, Y: 2}, Vec2{X: 16, Y: 40}},
+		{staggeredMap(Staggered, StaggerX, StaggerOdd, Size{Width: 32, Height: 16}, 0), Point{X: 2, Y: 2}, Vec2{X: 32, Y: 32}},
+		{staggeredMap(Hexagonal, StaggerY, StaggerOdd, Size{Width: 32, Height: 32}, 16), Point{X: 1, Y: 1}, Vec2{X: 48, Y: 24}},
+		{staggeredMap(Hexagonal, StaggerX, StaggerEven, Size{Width: 32, Height: 32}, 16), Point{X: 2, Y: 1}, Vec2{X: 48, Y: 48}},
+	}
+
+	for _, test := range tests {
+		if got := test.m.TileToPixel(test.tile); got != test.want {
+			t.Errorf("%s: tile %v is at %v, want %v", mapName(test.m), test.tile, got, test.want)
+		}
+	}
+}
+
+func TestPixelToTile(t *testing.T) {
+	// Positions within each tile, relative to its center, all find the tile they are placed in
+	for _, m := range coordMaps() {
+		tw, th := float32(m.TileSize.Width), float32(m.TileSize.Height)
+		samples := []Vec2{{}, {X: tw / 4}, {X: -tw / 4}, {Y: th / 4}, {Y: -th / 4}}
+
+		for y := -3; y < 6; y++ {
+			for x := -3; x < 6; x++ {
+				tile := Point{X: x, Y: y}
+				corner := m.TileToPixel(tile)
+				for _, sample := range samples {
+					pos := Vec2{X: corner.X + tw/2 + sample.X, Y: corner.Y + th/2 + sample.Y}
+					if got := m.PixelToTile(pos); got != tile {
+						t.Errorf("%s: %v is within tile %v, got %v", mapName(m), pos, tile, got)
+					}
+				}
+			}
+		}
+	}
+}
+
+// vim: ts=4
@@ -5,7 +5,9 @@ import (
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
+	"io"
 	"os"
+	"sort"
 	"strconv"
 	"strings"
 )
@@ -29,9 +31,13 @@ var KnownTypes map[string]*CustomClass
 type CustomClass struct {
 	// Name is the user-defined name of the type.
 	Name string
+	// Color is the color used to display objects of this class in the editor (optional).
+	Color Color
 	// Members contain a collection properties that described the name, type, and
 	// default value for the members that make up the class.
 	Members Properties
+	// Extra contains unrecognized data that was retained while reading, see PreserveUnknown.
+	Extra Extra
 }
 
 // LoadTypes loads custom property types from file, and adds them to the KnownTypes
@@ -106,6 +112,69 @@ func NewClass(name string) *CustomClass {
 	return class
 }
 
+// WriteTypes writes custom class definitions to a file in a form that can be imported into
+// Tiled, using the specified format. When the format is FormatUnknown, it will be detected based
+// on the file extension.
+//
+// The XML format is written as an "objecttypes" file, and the JSON format as an array of
+// property types. When no classes are specified, all KnownTypes are written in alphabetical
+// order by name.
+func WriteTypes(path string, format Format, classes ...*CustomClass) error {
+	if format == FormatUnknown {
+		format = DetectExt(path)
+	}
+	if format != FormatXML && format != FormatJSON {
+		return errInvalidEnum("Format", fmt.Sprintf("Format(%d)", format))
+	}
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	if err = EncodeTypes(file, format, classes...); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// EncodeTypes writes custom class definitions to the writer using the specified format. When
+// no classes are specified, all KnownTypes are written in alphabetical order by name.
+//
+// See WriteTypes.
+func EncodeTypes(w io.Writer, format Format, classes ...*CustomClass) error {
+	if len(classes) == 0 {
+		names := make([]string, 0, len(KnownTypes))
+		for name := range KnownTypes {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			classes = append(classes, KnownTypes[name])
+		}
+	}
+
+	switch format {
+	case FormatXML:
+		type x struct {
+			XMLName xml.Name       `xml:"objecttypes"`
+			Types   []*CustomClass `xml:"objecttype"`
+		}
+		return Encode(w, format, &x{Types: classes})
+	case FormatJSON:
+		// Tiled requires each type to have a unique ID
+		types := make([]map[string]any, len(classes))
+		for i, class := range classes {
+			types[i] = class.jsonAttrs()
+			types[i]["id"] = i + 1
+		}
+		return Encode(w, format, types)
+	default:
+		return errInvalidEnum("Format", fmt.Sprintf("Format(%d)", format))
+	}
+}
+
 // String implements the Stringer interface.
 func (c *CustomClass) String() string {
 	var sb strings.Builder
@@ -122,6 +191,69 @@ func (c *CustomClass) String() string {
 	return sb.String()
 }
 
+// MarshalXML implements the xml.Marshaler interface.
+//
+// The class is written as an "objecttype" element, with the values of its members written as
+// their defaults.
+func (c *CustomClass) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start = xml.StartElement{Name: xml.Name{Local: "objecttype"}}
+	start.Attr = append(start.Attr, xmlStr("name", c.Name))
+	if c.Color != 0 {
+		start.Attr = append(start.Attr, xmlStr("color", c.Color.String()))
+	}
+	start.Attr = c.Extra.xmlAttrs(start.Attr)
+
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := c.Extra.marshalXML(e); err != nil {
+		return err
+	}
+	for _, name := range c.Members.names() {
+		if err := c.Members[name].marshalXML(nil, e, "default"); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (c *CustomClass) MarshalJSON() ([]byte, error) {
+	return json.Marshal(c.jsonAttrs())
+}
+
+// jsonAttrs returns the fields of the class as they are written in the JSON format.
+func (c *CustomClass) jsonAttrs() map[string]any {
+	members := make([]map[string]any, 0, len(c.Members))
+	for _, name := range c.Members.names() {
+		prop := c.Members[name]
+		member := map[string]any{
+			"name":  prop.Name,
+			"type":  TypeString,
+			"value": prop.marshalValue(nil),
+		}
+		if prop.Type.IsValid() {
+			member["type"] = prop.Type
+		}
+		if prop.Class != "" {
+			member["propertyType"] = prop.Class
+		}
+		prop.Extra.jsonAttrs(member)
+		members = append(members, member)
+	}
+
+	attrs := map[string]any{
+		"name":    c.Name,
+		"type":    "class",
+		"members": members,
+	}
+	if c.Color != 0 {
+		attrs["color"] = c.Color
+	}
+	c.Extra.jsonAttrs(attrs)
+	return attrs
+}
+
 // UnmarshalXML implements the xml.Unmarshaler interface.
 func (c *CustomClass) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	if c.Members == nil {
@@ -133,9 +265,13 @@ func (c *CustomClass) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error
 		case "name":
 			c.Name = attr.Value
 		case "color":
-			// Skip
+			if color, err := ParseColor(attr.Value); err != nil {
+				return err
+			} else {
+				c.Color = color
+			}
 		default:
-			logAttr(attr.Name.Local, start.Name.Local)
+			c.Extra.attr(attr, start.Name.Local)
 		}
 	}
 
@@ -154,7 +290,9 @@ func (c *CustomClass) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error
 				}
 				c.Members[prop.Name] = prop
 			default:
-				logElem(child.Name.Local, start.Name.Local)
+				if err = c.Extra.elem(d, child, start.Name.Local); err != nil {
+					return err
+				}
 			}
 		}
 		token, err = d.Token()
@@ -196,6 +334,12 @@ func (c *CustomClass) UnmarshalJSON(data []byte) error {
 			if c.Name, err = jsonProp[string](d); err != nil {
 				return err
 			}
+		case "color":
+			if str, err := jsonProp[string](d); err != nil {
+				return err
+			} else if c.Color, err = ParseColor(str); err != nil {
+				return err
+			}
 		case "members":
 			if token, err = d.Token(); err != nil {
 				return err
@@ -214,8 +358,10 @@ func (c *CustomClass) UnmarshalJSON(data []byte) error {
 				return err
 			}
 		default:
-			// Do not log unhandled values
-			jsonSkip(d)
+			// Do not log unhandled values, such as the ID, which is assigned when written
+			if err := c.Extra.skip(d, name); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -0,0 +1,103 @@
+package tmx
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// setKnownTypes replaces KnownTypes for the duration of a test.
+func setKnownTypes(t *testing.T) {
+	prev := KnownTypes
+	KnownTypes = nil
+	t.Cleanup(func() { KnownTypes = prev })
+}
+
+func TestWriteTypes(t *testing.T) {
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"types.xml", []string{`<objecttypes>`, `<objecttype name="enemy" color="#ffff0000">`,
+			`<property name="health" type="int" default="10">`, `<objecttype name="stats">`}},
+		{"types.json", []string{`"name": "enemy"`, `"type": "class"`, `"members": [`,
+			`"propertyType": "stats"`, `"id": 2`}},
+	}
+
+	for _, test := range tests {
+		setKnownTypes(t)
+		stats := NewClass("stats")
+		stats.Members["speed"] = Property{Name: "speed", Type: TypeFloat, Value: 2.5}
+		enemy := NewClass("enemy")
+		enemy.Color = NewRGB(255, 0, 0)
+		enemy.Members["health"] = Property{Name: "health", Type: TypeInt, Value: 10}
+		enemy.Members["name"] = Property{Name: "name", Type: TypeString, Value: "goblin"}
+		enemy.Members["hostile"] = Property{Name: "hostile", Type: TypeBool, Value: true}
+		enemy.Members["stats"] = Property{Name: "stats", Type: TypeClass, Class: "stats", Value: Properties{}}
+
+		path := filepath.Join(t.TempDir(), test.name)
+		if err := WriteTypes(path, FormatUnknown); err != nil {
+			t.Fatal(err)
+		}
+		doc := readFile(t, path)
+		for _, want := range test.want {
+			if !strings.Contains(doc, want) {
+				t.Errorf("%s does not contain %s:\n%s", test.name, want, doc)
+			}
+		}
+
+		// The written types are read back the same
+		KnownTypes = nil
+		if err := LoadTypes(path); err != nil {
+			t.Fatal(err)
+		}
+		got := KnownTypes["enemy"]
+		if got == nil || got.Color != enemy.Color || len(got.Members) != len(enemy.Members) {
+			t.Fatalf("%s: read %v", test.name, got)
+		}
+		for _, name := range []string{"health", "name", "hostile"} {
+			if !reflect.DeepEqual(got.Members[name], enemy.Members[name]) {
+				t.Errorf("%s: member is %#v, want %#v", test.name, got.Members[name], enemy.Members[name])
+			}
+		}
+		if member := got.Members["stats"]; member.Class != "stats" {
+			t.Errorf("%s: class member is %#v", test.name, member)
+		}
+	}
+}
+
+func TestEncodeTypesSelected(t *testing.T) {
+	setKnownTypes(t)
+	NewClass("first").Members["a"] = Property{Name: "a", Type: TypeInt, Value: 1}
+	second := NewClass("second")
+	second.Members["b"] = Property{Name: "b", Type: TypeInt, Value: 2}
+
+	var sb strings.Builder
+	if err := EncodeTypes(&sb, FormatXML, second); err != nil {
+		t.Fatal(err)
+	}
+	if doc := sb.String(); strings.Contains(doc, "first") || !strings.Contains(doc, "second") {
+		t.Errorf("only the given class was not written:\n%s", doc)
+	}
+	if err := EncodeTypes(&sb, FormatUnknown); err == nil {
+		t.Error("expected an error encoding with an unknown format")
+	}
+}
+
+func TestWriteTypesInvalidFormat(t *testing.T) {
+	setKnownTypes(t)
+	NewClass("enemy").Members["health"] = Property{Name: "health", Type: TypeInt, Value: 10}
+
+	// The file is not created when the format cannot be written
+	path := filepath.Join(t.TempDir(), "types.txt")
+	if err := WriteTypes(path, FormatUnknown); err == nil {
+		t.Error("expected an error writing with an unknown format")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("file was created: %v", err)
+	}
+}
+
+// vim: ts=4
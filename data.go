@@ -6,10 +6,12 @@ import (
 	"compress/zlib"
 	"encoding/base64"
 	"encoding/binary"
+	"encoding/json"
 	"encoding/xml"
 	"errors"
 	"fmt"
 	"io"
+	"slices"
 	"strconv"
 	"strings"
 	"sync"
@@ -45,6 +47,30 @@ type TileData struct {
 	tileData []byte
 }
 
+// MarshalXML implements the xml.Marshaler interface.
+func (data *Data) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	start = xml.StartElement{Name: xml.Name{Local: "data"}}
+	if data.Encoding != EncodingNone {
+		start.Attr = append(start.Attr, xmlStr("encoding", data.Encoding.String()))
+	}
+	if data.Compression != CompressionNone {
+		start.Attr = append(start.Attr, xmlStr("compression", data.Compression.String()))
+	}
+
+	payload := data.Payload
+	if data.Encoding == EncodingBase64 {
+		payload = []byte(base64.StdEncoding.EncodeToString(payload))
+	}
+
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := e.EncodeToken(xml.CharData(payload)); err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
+
 // UnmarshalXML implements the xml.Unmarshaler interface.
 func (data *Data) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	// Must use an alias type to avoid infinite recursion.
@@ -82,7 +108,9 @@ func (data *TileData) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error
 			Tiles []struct {
 				Value TileID `xml:"gid,attr"`
 			} `xml:"tile"`
-			Payload []byte `xml:",chardata"`
+			Payload  []byte       `xml:",chardata"`
+			Attrs    []xml.Attr   `xml:",any,attr"`
+			Elements []RawElement `xml:",any"`
 		} `xml:"chunk"`
 	}
 
@@ -107,6 +135,12 @@ func (data *TileData) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error
 				// Store for now, process later
 				result.tileData = trimPayload(chunk.Payload)
 			}
+			for _, attr := range chunk.Attrs {
+				result.Extra.attr(attr, "chunk")
+			}
+			for _, elem := range chunk.Elements {
+				result.Extra.raw(elem, "chunk")
+			}
 			data.Chunks[i] = result
 		}
 	} else if len(temp.Tiles) > 0 {
@@ -121,6 +155,216 @@ func (data *TileData) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error
 	return nil
 }
 
+// marshalXML writes the tile data as a <data> element, using the encoding and compression of
+// the tile data. The width of the tile layer is required to separate rows of tiles for finite
+// maps, and the level is the compression level to apply.
+func (data *TileData) marshalXML(e *xml.Encoder, width, level int) error {
+	start := xml.StartElement{Name: xml.Name{Local: "data"}}
+	if data.Encoding != EncodingNone {
+		start.Attr = append(start.Attr, xmlStr("encoding", data.Encoding.String()))
+	}
+	if data.Encoding == EncodingBase64 && data.Compression != CompressionNone {
+		start.Attr = append(start.Attr, xmlStr("compression", data.Compression.String()))
+	}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+
+	if len(data.Chunks) > 0 {
+		chunks := data.chunks()
+		for i := range chunks {
+			chunk := &chunks[i]
+			elem := xml.StartElement{Name: xml.Name{Local: "chunk"}}
+			elem.Attr = append(elem.Attr,
+				xmlInt("x", chunk.X),
+				xmlInt("y", chunk.Y),
+				xmlInt("width", chunk.Width),
+				xmlInt("height", chunk.Height),
+			)
+			elem.Attr = chunk.Extra.xmlAttrs(elem.Attr)
+			if err := e.EncodeToken(elem); err != nil {
+				return err
+			}
+			if err := chunk.Extra.marshalXML(e); err != nil {
+				return err
+			}
+			if err := data.marshalTiles(e, chunk.Tiles, chunk.Width, level); err != nil {
+				return err
+			}
+			if err := e.EncodeToken(elem.End()); err != nil {
+				return err
+			}
+		}
+	} else if err := data.marshalTiles(e, data.Tiles, width, level); err != nil {
+		return err
+	}
+
+	return e.EncodeToken(start.End())
+}
+
+// marshalTiles writes tile IDs as the content of a <data> or <chunk> element. Unencoded tiles
+// are written as individual <tile> elements.
+func (data *TileData) marshalTiles(e *xml.Encoder, gids []TileID, width, level int) error {
+	if data.Encoding == EncodingNone {
+		for _, gid := range gids {
+			var attrs []xml.Attr
+			if gid != 0 {
+				attrs = append(attrs, xmlID("gid", gid))
+			}
+			if err := xmlEmpty(e, "tile", attrs...); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	payload, err := EncodeTiles(gids, width, data.Encoding, data.Compression, level)
+	if err != nil {
+		return err
+	}
+	if data.Encoding == EncodingBase64 {
+		payload = append(append([]byte{'\n'}, payload...), '\n')
+	}
+	return e.EncodeToken(xml.CharData(payload))
+}
+
+// jsonData adds the tile data to the fields of a tile layer as they are written in the JSON
+// format, either as an array of tile IDs, or as chunks for infinite maps. Base64-encoded data
+// is written as a string, using the given compression level.
+func (data *TileData) jsonData(attrs map[string]any, level int) error {
+	if data.Encoding == EncodingBase64 {
+		attrs["encoding"] = data.Encoding
+		if data.Compression != CompressionNone {
+			attrs["compression"] = data.Compression
+		}
+	}
+
+	if len(data.Chunks) == 0 {
+		value, err := data.jsonTiles(data.Tiles, level)
+		if err != nil {
+			return err
+		}
+		attrs["data"] = value
+		return nil
+	}
+
+	sorted := data.chunks()
+	chunks := make([]map[string]any, len(sorted))
+	start := sorted[0].Point
+	for i := range sorted {
+		chunk := &sorted[i]
+		value, err := data.jsonTiles(chunk.Tiles, level)
+		if err != nil {
+			return err
+		}
+		chunks[i] = map[string]any{
+			"x":      chunk.X,
+			"y":      chunk.Y,
+			"width":  chunk.Width,
+			"height": chunk.Height,
+			"data":   value,
+		}
+		chunk.Extra.jsonAttrs(chunks[i])
+		start.X = min(start.X, chunk.X)
+		start.Y = min(start.Y, chunk.Y)
+	}
+	attrs["chunks"] = chunks
+	attrs["startx"] = start.X
+	attrs["starty"] = start.Y
+	return nil
+}
+
+// chunks returns the chunks in the order they are written, which in canonical mode is ordered
+// by row, then column.
+func (data *TileData) chunks() []Chunk {
+	if !Canonical {
+		return data.Chunks
+	}
+	chunks := slices.Clone(data.Chunks)
+	slices.SortStableFunc(chunks, func(a, b Chunk) int {
+		if a.Y != b.Y {
+			return a.Y - b.Y
+		}
+		return a.X - b.X
+	})
+	return chunks
+}
+
+// jsonTiles returns the tile IDs as they are written in the JSON format, which is a string
+// for base64-encoded data, otherwise an array of numbers.
+func (data *TileData) jsonTiles(gids []TileID, level int) (any, error) {
+	if data.Encoding != EncodingBase64 {
+		return jsonGIDs(gids), nil
+	}
+	payload, err := EncodeTiles(gids, 0, data.Encoding, data.Compression, level)
+	if err != nil {
+		return nil, err
+	}
+	return string(payload), nil
+}
+
+// jsonGIDs encodes tile IDs as a JSON array of numbers.
+func jsonGIDs(gids []TileID) json.RawMessage {
+	buffer := make([]byte, 0, len(gids)*4+2)
+	buffer = append(buffer, '[')
+	for i, gid := range gids {
+		if i > 0 {
+			buffer = append(buffer, ',')
+		}
+		buffer = strconv.AppendUint(buffer, uint64(gid), 10)
+	}
+	return append(buffer, ']')
+}
+
+// Encode encodes the tiles of a finite map using the encoding and compression of the tile
+// data. The width is the width of the tile layer in tile units, and level is the compression
+// level to apply, where -1 uses the default of the compression algorithm.
+//
+// Tile data of infinite maps is stored in chunks, which must be encoded individually.
+func (data *TileData) Encode(width, level int) ([]byte, error) {
+	if len(data.Chunks) > 0 {
+		return nil, errFormat("cannot encode chunked tile data, each chunk must be encoded")
+	}
+	return EncodeTiles(data.Tiles, width, data.Encoding, data.Compression, level)
+}
+
+// EncodeTiles encodes tile IDs using the given encoding and compression, in the same form
+// as they are stored in TMX documents. The width is the number of tiles in each row, and is
+// only used to separate rows of CSV data. The level is the compression level to apply, where -1
+// uses the default of the compression algorithm.
+//
+// Compression is only applicable to base64-encoded data, and is ignored otherwise.
+func EncodeTiles(gids []TileID, width int, encoding Encoding, compression Compression, level int) ([]byte, error) {
+	switch encoding {
+	case EncodingCSV:
+		return encodeCSV(gids, width), nil
+	case EncodingBase64:
+	default:
+		return nil, errInvalidEnum("Encoding", encoding.String())
+	}
+
+	buffer := make([]byte, len(gids)*4)
+	for i, gid := range gids {
+		binary.LittleEndian.PutUint32(buffer[i*4:], uint32(gid))
+	}
+
+	deflated, err := deflate(buffer, compression, level)
+	if err != nil {
+		return nil, err
+	}
+
+	encoded := make([]byte, base64.StdEncoding.EncodedLen(len(deflated)))
+	base64.StdEncoding.Encode(encoded, deflated)
+	return encoded, nil
+}
+
+// DecodeTiles decodes tile IDs that were encoded with the given encoding and compression into
+// the given slice, which must be exactly the length of the encoded tiles.
+func DecodeTiles(data []byte, encoding Encoding, compression Compression, gids []TileID) error {
+	temp := TileData{Encoding: encoding, Compression: compression}
+	return temp.decode(trimPayload(data), gids)
+}
+
 // decode processed the raw encoded/compressed bytes into tile IDs.
 func (data *TileData) decode(raw []byte, gids []TileID) error {
 	// Encoding: CSV
@@ -166,7 +410,7 @@ func decodeCSV(data []byte, gids []TileID) error {
 	}
 
 	for i, id := range ids {
-		if result, err := strconv.ParseUint(id, 10, 32); err != nil {
+		if result, err := strconv.ParseUint(strings.TrimSpace(id), 10, 32); err == nil {
 			gids[i] = TileID(result)
 		} else {
 			return err
@@ -175,6 +419,28 @@ func decodeCSV(data []byte, gids []TileID) error {
 	return nil
 }
 
+// encodeCSV encodes tile IDs as comma-separated values, with each row of the given width
+// written on its own line, as is done by Tiled.
+func encodeCSV(gids []TileID, width int) []byte {
+	if width <= 0 {
+		width = len(gids)
+	}
+
+	var buffer bytes.Buffer
+	buffer.WriteByte('\n')
+	for i, gid := range gids {
+		if i > 0 {
+			buffer.WriteByte(',')
+			if i%width == 0 {
+				buffer.WriteByte('\n')
+			}
+		}
+		buffer.WriteString(strconv.FormatUint(uint64(gid), 10))
+	}
+	buffer.WriteByte('\n')
+	return buffer.Bytes()
+}
+
 // isWhitespace tests whether the given buffer contains only whitespace characters.
 func (data *Data) isWhitespace(payload []byte) bool {
 	for i := 0; i < len(payload); {
@@ -250,51 +516,86 @@ func inflate(src, dst []byte, comp Compression) error {
 		return err
 	}
 
-	if n, err := reader.Read(dst); err != nil && err != io.EOF {
-		return err
-	} else if n != len(dst) {
+	if _, err := io.ReadFull(reader, dst); err == io.EOF || err == io.ErrUnexpectedEOF {
 		return errors.New("failed to read correct number of bytes")
+	} else if err != nil {
+		return err
 	}
 
 	return nil
 }
 
+// deflate compresses a slice of bytes with the given algorithm and compression level, returning
+// the result in a newly allocated slice.
+func deflate(src []byte, comp Compression, level int) ([]byte, error) {
+	var buffer bytes.Buffer
+	var writer io.WriteCloser
+	var err error
+
+	switch comp {
+	case CompressionGzip:
+		writer, err = gzip.NewWriterLevel(&buffer, level)
+	case CompressionZlib:
+		writer, err = zlib.NewWriterLevel(&buffer, level)
+	case CompressionZstd:
+		if level < 0 {
+			level = zstd.DefaultCompression
+		}
+		return zstd.CompressLevel(nil, src, level)
+	case CompressionNone:
+		return src, nil
+	default:
+		return nil, errInvalidEnum("Compression", comp.String())
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	if _, err = writer.Write(src); err != nil {
+		writer.Close()
+		return nil, err
+	}
+	if err = writer.Close(); err != nil {
+		return nil, err
+	}
+	return buffer.Bytes(), nil
+}
+
 // postProcess performs any necessary operations to decode, inflate, and cleanup tile data,
 // as well as  ensure it is correctly defined and of the required size.
 func (data *TileData) postProcess(tileCount int) error {
 	if len(data.Chunks) > 0 {
-
-		errors := make(chan error, len(data.Chunks))
-		defer close(errors)
+		var wg sync.WaitGroup
+		errs := make([]error, len(data.Chunks))
 
 		for i := range data.Chunks {
 			chunk := &data.Chunks[i]
 			area := chunk.Width * chunk.Height
 			if len(chunk.tileData) == 0 {
 				if len(chunk.Tiles) != area {
-					return fmt.Errorf("not enough tiles in chunk [%d, %d]", chunk.X, chunk.Y)
+					errs[i] = fmt.Errorf("not enough tiles in chunk [%d, %d]", chunk.X, chunk.Y)
 				}
 				continue
 			}
 
 			chunk.Tiles = make([]TileID, area)
 
-			go func(c *Chunk) {
-				err := data.decode(chunk.tileData, chunk.Tiles)
-				chunk.tileData = nil
-				errors <- err
-			}(chunk)
+			wg.Add(1)
+			go func(i int, c *Chunk) {
+				defer wg.Done()
+				errs[i] = data.decode(c.tileData, c.Tiles)
+				c.tileData = nil
+			}(i, chunk)
 		}
 
-		var wg sync.WaitGroup
-		wg.Add(len(data.Chunks))
-		go func() {
-			for range errors {
-				wg.Done()
-			}
-		}()
 		wg.Wait()
-
+		if err := errors.Join(errs...); err != nil {
+			return err
+		}
+		if i, j := overlappingChunks(data.Chunks); i >= 0 {
+			a, b := data.Chunks[i], data.Chunks[j]
+			return fmt.Errorf("%w: [%d, %d] and [%d, %d]", ErrChunkOverlap, a.X, a.Y, b.X, b.Y)
+		}
 		return nil
 	}
 
@@ -316,4 +617,16 @@ func (data *TileData) postProcess(tileCount int) error {
 	return nil
 }
 
+// clone returns a deep copy of the tile data.
+func (data *TileData) clone() TileData {
+	dup := *data
+	dup.Tiles = slices.Clone(data.Tiles)
+	dup.Chunks = make([]Chunk, len(data.Chunks))
+	for i := range data.Chunks {
+		dup.Chunks[i] = *data.Chunks[i].Clone()
+	}
+	dup.tileData = nil
+	return dup
+}
+
 // vim: ts=4
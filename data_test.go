@@ -0,0 +1,79 @@
+package tmx
+
+import (
+	"fmt"
+	"slices"
+	"testing"
+)
+
+// dataFormats lists every combination of encoding and compression supported for tile data.
+var dataFormats = []struct {
+	encoding    Encoding
+	compression Compression
+}{
+	{EncodingCSV, CompressionNone},
+	{EncodingBase64, CompressionNone},
+	{EncodingBase64, CompressionGzip},
+	{EncodingBase64, CompressionZlib},
+	{EncodingBase64, CompressionZstd},
+}
+
+func TestEncodeTiles(t *testing.T) {
+	gids := []TileID{0, 1, 2, 3, 4, 5 | FlipH, 6 | FlipV, 7 | FlipD, 0, 4096, 0, 1}
+
+	for _, format := range dataFormats {
+		for _, level := range []int{-1, 1, 9} {
+			name := fmt.Sprintf("%v/%v/%d", format.encoding, format.compression, level)
+			data, err := EncodeTiles(gids, 4, format.encoding, format.compression, level)
+			if err != nil {
+				t.Errorf("%s: encoding: %v", name, err)
+				continue
+			}
+
+			result := make([]TileID, len(gids))
+			if err := DecodeTiles(data, format.encoding, format.compression, result); err != nil {
+				t.Errorf("%s: decoding: %v", name, err)
+				continue
+			}
+			if !slices.Equal(result, gids) {
+				t.Errorf("%s: decoded %v, want %v", name, result, gids)
+			}
+		}
+	}
+}
+
+func TestEncodeTilesInvalid(t *testing.T) {
+	if _, err := EncodeTiles([]TileID{1}, 1, EncodingNone, CompressionNone, -1); err == nil {
+		t.Error("expected an error encoding with no encoding")
+	}
+	if _, err := EncodeTiles([]TileID{1}, 1, EncodingBase64, Compression(99), -1); err == nil {
+		t.Error("expected an error encoding with an unknown compression")
+	}
+	if err := DecodeTiles([]byte("1,2"), EncodingCSV, CompressionNone, make([]TileID, 3)); err == nil {
+		t.Error("expected an error decoding the wrong number of tiles")
+	}
+}
+
+func TestLayerEncoding(t *testing.T) {
+	// Tile layers are written with their own encoding and compression, which are read back
+	for _, format := range []Format{FormatXML, FormatJSON} {
+		for _, data := range dataFormats {
+			m := decodeMap(t, testMapXML, FormatXML)
+			layer := m.TileLayers[0]
+			layer.Encoding = data.encoding
+			layer.Compression = data.compression
+
+			got, _ := roundTrip(t, m, format)
+			result := got.TileLayers[0]
+			name := fmt.Sprintf("%v/%v/%v", format, data.encoding, data.compression)
+			if result.Encoding != data.encoding || result.Compression != data.compression {
+				t.Errorf("%s: layer was written as %v/%v", name, result.Encoding, result.Compression)
+			}
+			if !slices.Equal(result.Tiles, layer.Tiles) {
+				t.Errorf("%s: tiles are %v, want %v", name, result.Tiles, layer.Tiles)
+			}
+		}
+	}
+}
+
+// vim: ts=4
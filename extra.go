@@ -0,0 +1,217 @@
+package tmx
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// PreserveUnknown is a global configuration that determines how unrecognized data is handled
+// when reading TMX documents.
+//
+// By default, unrecognized attributes, elements, and JSON properties are logged and discarded.
+// When set to true, they are instead retained in the Extra field of the type they were found
+// in, and written back out when the document is saved. This prevents data such as editor
+// settings, export settings, or data from newer versions of Tiled from being lost.
+var PreserveUnknown bool
+
+// Extra contains unrecognized data that was retained while reading a TMX document, which will
+// be written back out in the same format it was read from.
+//
+// See PreserveUnknown.
+type Extra struct {
+	// Attrs contains unrecognized XML attributes.
+	Attrs []xml.Attr
+	// Elements contains unrecognized XML elements.
+	Elements []RawElement
+	// Props contains unrecognized JSON properties, with their raw JSON values.
+	Props map[string]json.RawMessage
+	// Children contains unrecognized data within XML child elements that are not represented
+	// by a type of their own, such as the "properties" element, keyed by element name.
+	Children map[string]*Extra
+}
+
+// RawElement is an XML element that is stored without being processed.
+type RawElement struct {
+	// XMLName is the name of the element.
+	XMLName xml.Name
+	// Attrs are the attributes of the element.
+	Attrs []xml.Attr `xml:",any,attr"`
+	// InnerXML is the raw content of the element.
+	InnerXML []byte `xml:",innerxml"`
+}
+
+// IsEmpty tests whether any unrecognized data is retained.
+func (x *Extra) IsEmpty() bool {
+	for _, child := range x.Children {
+		if !child.IsEmpty() {
+			return false
+		}
+	}
+	return len(x.Attrs) == 0 && len(x.Elements) == 0 && len(x.Props) == 0
+}
+
+// child returns the retained data of the child element with the given name, which is added
+// when not yet present. Returns nil when unrecognized data is not retained, which discards the
+// data given to it.
+func (x *Extra) child(name string) *Extra {
+	if x == nil || !PreserveUnknown {
+		return nil
+	}
+	if x.Children == nil {
+		x.Children = make(map[string]*Extra)
+	}
+	child, ok := x.Children[name]
+	if !ok {
+		child = &Extra{}
+		x.Children[name] = child
+	}
+	return child
+}
+
+// attr handles an unrecognized XML attribute.
+func (x *Extra) attr(attr xml.Attr, parent string) {
+	if PreserveUnknown && x != nil {
+		x.Attrs = append(x.Attrs, attr)
+		return
+	}
+	logAttr(attr.Name.Local, parent)
+}
+
+// elem handles an unrecognized XML element, consuming it entirely.
+func (x *Extra) elem(d *xml.Decoder, start xml.StartElement, parent string) error {
+	if !PreserveUnknown || x == nil {
+		logElem(start.Name.Local, parent)
+		return d.Skip()
+	}
+
+	var raw RawElement
+	if err := d.DecodeElement(&raw, &start); err != nil {
+		return err
+	}
+	x.Elements = append(x.Elements, raw)
+	return nil
+}
+
+// raw handles an unrecognized XML element that was already read.
+func (x *Extra) raw(elem RawElement, parent string) {
+	if PreserveUnknown && x != nil {
+		x.Elements = append(x.Elements, elem)
+		return
+	}
+	logElem(elem.XMLName.Local, parent)
+}
+
+// prop handles an unrecognized JSON property, consuming its value entirely.
+func (x *Extra) prop(d *json.Decoder, name, parent string) error {
+	if !PreserveUnknown {
+		logProp(name, parent)
+		return jsonSkip(d)
+	}
+
+	var raw json.RawMessage
+	if err := d.Decode(&raw); err != nil {
+		return err
+	}
+	if x.Props == nil {
+		x.Props = make(map[string]json.RawMessage)
+	}
+	x.Props[name] = raw
+	return nil
+}
+
+// skip handles a JSON property that is recognized but not used, such as one that is always
+// written with the same value, consuming its value. The value is only retained when
+// PreserveUnknown is set, in which case it is written in place of the value that would be
+// written otherwise.
+func (x *Extra) skip(d *json.Decoder, name string) error {
+	if !PreserveUnknown {
+		return jsonSkip(d)
+	}
+	return x.prop(d, name, "")
+}
+
+// xmlAttrs appends the retained XML attributes to the given attributes, excluding any with a
+// name that is already present. In canonical mode, they are appended in alphabetical order.
+func (x *Extra) xmlAttrs(attrs []xml.Attr) []xml.Attr {
+	if x == nil {
+		return attrs
+	}
+	extra := x.Attrs
+	if Canonical {
+		extra = slices.Clone(extra)
+		slices.SortStableFunc(extra, func(a, b xml.Attr) int {
+			return strings.Compare(a.Name.Local, b.Name.Local)
+		})
+	}
+	for _, attr := range extra {
+		if !hasAttr(attrs, attr.Name.Local) {
+			attrs = append(attrs, attr)
+		}
+	}
+	return attrs
+}
+
+// marshalXML writes the retained XML elements.
+func (x *Extra) marshalXML(e *xml.Encoder) error {
+	if x == nil {
+		return nil
+	}
+	for i := range x.Elements {
+		if err := e.Encode(&x.Elements[i]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// jsonDefaults adds the fields that are always written with the same value to the given fields,
+// excluding any retained with Extra.skip.
+func jsonDefaults(attrs map[string]any, defaults map[string]any) {
+	for name, value := range defaults {
+		if _, ok := attrs[name]; !ok {
+			attrs[name] = value
+		}
+	}
+}
+
+// jsonAttrs adds the retained JSON properties to the given fields, excluding any with a name
+// that is already present.
+func (x *Extra) jsonAttrs(attrs map[string]any) {
+	for name, value := range x.Props {
+		if _, ok := attrs[name]; !ok {
+			attrs[name] = value
+		}
+	}
+}
+
+// hasAttr tests whether an attribute with the given name is present.
+func hasAttr(attrs []xml.Attr, name string) bool {
+	for _, attr := range attrs {
+		if attr.Name.Local == name {
+			return true
+		}
+	}
+	return false
+}
+
+// clone returns a copy of the retained data that does not share its collections.
+func (x *Extra) clone() Extra {
+	dup := Extra{
+		Attrs:    slices.Clone(x.Attrs),
+		Elements: slices.Clone(x.Elements),
+		Props:    maps.Clone(x.Props),
+	}
+	if x.Children != nil {
+		dup.Children = make(map[string]*Extra, len(x.Children))
+		for name, child := range x.Children {
+			value := child.clone()
+			dup.Children[name] = &value
+		}
+	}
+	return dup
+}
+
+// vim: ts=4
@@ -0,0 +1,346 @@
+package tmx
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// unknownXML is a map with unrecognized attributes and elements on each type that retains them.
+const unknownXML = `<?xml version="1.0" encoding="UTF-8"?>
+<map version="1.10" orientation="orthogonal" width="2" height="2" tilewidth="16" tileheight="16" nextlayerid="5" nextobjectid="3" mapextra="1">
+ <editorsettings>
+  <export target="level.json" format="json"/>
+ </editorsettings>
+ <tileset firstgid="1" name="terrain" tilewidth="16" tileheight="16" tilecount="4" columns="2" tilesetextra="2">
+  <image source="terrain.png" width="32" height="32" imageextra="3"/>
+  <tilesetelement/>
+ </tileset>
+ <layer id="1" name="ground" width="2" height="2" layerextra="4">
+  <data encoding="csv">1,2,3,4</data>
+  <layerelement/>
+ </layer>
+ <group id="2" name="group" groupextra="5">
+  <objectgroup id="3" name="objects" objectsextra="6">
+   <object id="1" x="1" y="2" objectextra="7">
+    <objectelement value="8"/>
+   </object>
+   <object id="2" x="1" y="2" width="10" height="10">
+    <text textextra="9">Hello</text>
+   </object>
+  </objectgroup>
+ </group>
+ <imagelayer id="4" name="sky" imagelayerextra="10">
+  <image source="sky.png"/>
+ </imagelayer>
+</map>
+`
+
+// unknownXMLData lists the unrecognized data within unknownXML that must be written back.
+var unknownXMLData = []string{
+	`mapextra="1"`, `<export target="level.json" format="json"/>`, `tilesetextra="2"`, `imageextra="3"`,
+	`<tilesetelement>`, `layerextra="4"`, `<layerelement>`, `groupextra="5"`, `objectsextra="6"`,
+	`objectextra="7"`, `<objectelement value="8">`, `textextra="9"`, `imagelayerextra="10"`,
+}
+
+// unknownJSON is a map with unrecognized properties on each type that retains them.
+const unknownJSON = `{"type": "map", "version": "1.10", "orientation": "orthogonal", "width": 2, "height": 2,
+	"tilewidth": 16, "tileheight": 16, "nextlayerid": 3, "nextobjectid": 2, "infinite": false,
+	"mapextra": {"nested": [1, 2]},
+	"tilesets": [{"firstgid": 1, "name": "terrain", "tilewidth": 16, "tileheight": 16, "tilecount": 4,
+		"columns": 2, "image": "terrain.png", "imagewidth": 32, "imageheight": 32, "tilesetextra": "a"}],
+	"layers": [
+		{"type": "tilelayer", "id": 1, "name": "ground", "width": 2, "height": 2, "data": [1, 2, 3, 4],
+			"layerextra": true},
+		{"type": "objectgroup", "id": 2, "name": "objects", "objectsextra": 5,
+			"objects": [{"id": 1, "x": 1, "y": 2, "objectextra": "b"}]}
+	]}`
+
+// unknownJSONData lists the unrecognized data within unknownJSON that must be written back.
+var unknownJSONData = []string{
+	`"mapextra": {`, `"tilesetextra": "a"`, `"layerextra": true`, `"objectsextra": 5`, `"objectextra": "b"`,
+}
+
+// nestedXML is an infinite map with unrecognized data within chunks, tiles, Wang sets,
+// properties, and the child elements of types that do not have a type of their own.
+const nestedXML = `<?xml version="1.0" encoding="UTF-8"?>
+<map version="1.10" orientation="orthogonal" width="2" height="2" tilewidth="16" tileheight="16" infinite="1" nextlayerid="3" nextobjectid="3">
+ <properties propsextra="1">
+  <propselement/>
+  <property name="speed" type="float" value="2" propextra="2">
+   <propelement/>
+  </property>
+  <property name="stats" type="class" propertytype="stats">
+   <properties classextra="3">
+    <property name="hp" type="int" value="5"/>
+   </properties>
+  </property>
+ </properties>
+ <tileset firstgid="1" name="terrain" tilewidth="16" tileheight="16" tilecount="4" columns="2">
+  <image source="terrain.png" width="32" height="32"/>
+  <tile id="0" terrain="0,0,0,0">
+   <objectgroup draworder="index" id="2" collisionextra="4">
+    <collisionelement/>
+    <object id="1" x="0" y="0" width="4" height="4"/>
+   </objectgroup>
+   <animation animationextra="5">
+    <animationelement/>
+    <frame tileid="0" duration="100" frameextra="6"/>
+    <frame tileid="1" duration="100"/>
+   </animation>
+  </tile>
+  <wangsets wangsetsextra="7">
+   <wangset name="paths" type="corner" tile="-1" wangsetextra="8">
+    <wangcolor name="dirt" color="#ff0000" tile="-1" probability="1" wangcolorextra="9"/>
+    <wangtile tileid="0" wangid="0,1,0,1,0,1,0,1" wangtileextra="10">
+     <wangtileelement/>
+    </wangtile>
+   </wangset>
+  </wangsets>
+ </tileset>
+ <layer id="1" name="ground" width="2" height="2">
+  <data encoding="csv">
+   <chunk x="0" y="0" width="2" height="2" chunkextra="11">1,2,3,4</chunk>
+  </data>
+ </layer>
+ <objectgroup id="2" name="objects">
+  <object id="1" x="1" y="2">
+   <polygon points="0,0 4,0 4,4" polygonextra="12"/>
+  </object>
+  <object id="2" x="1" y="2" width="4" height="4">
+   <ellipse ellipseextra="13"/>
+  </object>
+ </objectgroup>
+</map>
+`
+
+// nestedXMLData lists the unrecognized data within nestedXML that must be written back.
+var nestedXMLData = []string{
+	`propsextra="1"`, `<propselement>`, `propextra="2"`, `<propelement>`, `classextra="3"`,
+	`collisionextra="4"`, `<collisionelement>`, `animationextra="5"`, `<animationelement>`,
+	`frameextra="6"`, `terrain="0,0,0,0"`, `wangsetsextra="7"`, `wangsetextra="8"`,
+	`wangcolorextra="9"`, `wangtileextra="10"`, `<wangtileelement>`, `chunkextra="11"`,
+	`polygonextra="12"`, `ellipseextra="13"`,
+}
+
+// nestedJSON is an infinite map with unrecognized properties within chunks, tiles, Wang sets,
+// and properties, along with fields that are always written with the same value.
+const nestedJSON = `{"type": "map", "version": "1.10", "orientation": "orthogonal", "width": 2, "height": 2,
+	"tilewidth": 16, "tileheight": 16, "nextlayerid": 2, "nextobjectid": 1, "infinite": true,
+	"properties": [{"name": "speed", "type": "float", "value": 2, "propextra": "a"}],
+	"tilesets": [{"firstgid": 1, "name": "terrain", "tilewidth": 16, "tileheight": 16, "tilecount": 4,
+		"columns": 2, "image": "terrain.png", "imagewidth": 32, "imageheight": 32,
+		"terrains": [{"name": "grass", "tile": 0}],
+		"tiles": [{"id": 0, "terrain": [0, 0, 0, 0],
+			"objectgroup": {"type": "objectgroup", "draworder": "index", "id": 2, "name": "kept",
+				"collisionextra": 1, "objects": []},
+			"animation": [{"tileid": 0, "duration": 100, "frameextra": 2}]}],
+		"wangsets": [{"name": "paths", "type": "corner", "tile": -1, "wangsetextra": 3,
+			"colors": [{"name": "dirt", "color": "#ff0000", "tile": -1, "probability": 1, "wangcolorextra": 4}],
+			"wangtiles": [{"tileid": 0, "wangid": [0, 1, 0, 1, 0, 1, 0, 1], "wangtileextra": 5}]}]}],
+	"layers": [
+		{"type": "tilelayer", "id": 1, "name": "ground", "width": 2, "height": 2, "startx": 0, "starty": 0,
+			"chunks": [{"x": 0, "y": 0, "width": 2, "height": 2, "data": [1, 2, 3, 4], "chunkextra": 6}]}
+	]}`
+
+// nestedJSONData lists the unrecognized data within nestedJSON that must be written back.
+var nestedJSONData = []string{
+	`"propextra": "a"`, `"terrains": [{`, `"terrain": [0,0,0,0]`, `"name": "kept"`, `"collisionextra": 1`,
+	`"frameextra": 2`, `"wangsetextra": 3`, `"wangcolorextra": 4`, `"wangtileextra": 5`, `"chunkextra": 6`,
+}
+
+// setPreserveUnknown changes PreserveUnknown for the duration of a test.
+func setPreserveUnknown(t *testing.T, value bool) {
+	prev := PreserveUnknown
+	PreserveUnknown = value
+	t.Cleanup(func() { PreserveUnknown = prev })
+}
+
+// writeUnknown decodes a map and writes it back in the same format, returning the document.
+func writeUnknown(t *testing.T, doc string, format Format) string {
+	t.Helper()
+
+	m := decodeMap(t, doc, format)
+	var buf bytes.Buffer
+	if err := Encode(&buf, format, m); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+// compactJSON removes the indentation of a JSON document for searching.
+func compactJSON(doc string) string {
+	var lines []string
+	for _, line := range strings.Split(doc, "\n") {
+		lines = append(lines, strings.TrimSpace(line))
+	}
+	return strings.Join(lines, "")
+}
+
+func TestPreserveUnknown(t *testing.T) {
+	setPreserveUnknown(t, true)
+
+	tests := []struct {
+		format Format
+		doc    string
+		data   []string
+	}{
+		{FormatXML, unknownXML, unknownXMLData},
+		{FormatJSON, unknownJSON, unknownJSONData},
+		{FormatXML, nestedXML, nestedXMLData},
+		{FormatJSON, nestedJSON, nestedJSONData},
+	}
+
+	for _, test := range tests {
+		doc := writeUnknown(t, test.doc, test.format)
+		search := doc
+		if test.format == FormatJSON {
+			search = compactJSON(strings.ReplaceAll(doc, `": `, `":`))
+		}
+		for _, data := range test.data {
+			if test.format == FormatJSON {
+				data = strings.ReplaceAll(data, `": `, `":`)
+			}
+			if !strings.Contains(search, data) {
+				t.Errorf("%v: %s was not written:\n%s", test.format, data, doc)
+			}
+		}
+
+		// The data is written the same way again
+		if again := writeUnknown(t, doc, test.format); again != doc {
+			t.Errorf("%v: document changed when written again:\n%s\n%s", test.format, doc, again)
+		}
+	}
+}
+
+func TestDiscardUnknown(t *testing.T) {
+	setPreserveUnknown(t, false)
+
+	doc := writeUnknown(t, unknownXML, FormatXML)
+	for _, data := range unknownXMLData {
+		if strings.Contains(doc, data) {
+			t.Errorf("%s was written:\n%s", data, doc)
+		}
+	}
+	doc = writeUnknown(t, nestedXML, FormatXML)
+	for _, data := range nestedXMLData {
+		if strings.Contains(doc, data) {
+			t.Errorf("%s was written:\n%s", data, doc)
+		}
+	}
+	doc = compactJSON(writeUnknown(t, unknownJSON, FormatJSON))
+	for _, name := range []string{"mapextra", "tilesetextra", "layerextra", "objectsextra", "objectextra"} {
+		if strings.Contains(doc, name) {
+			t.Errorf("%s was written:\n%s", name, doc)
+		}
+	}
+	doc = compactJSON(writeUnknown(t, nestedJSON, FormatJSON))
+	for _, name := range []string{"propextra", "terrains", `"terrain": [`, `"kept"`, "collisionextra",
+		"frameextra", "wangsetextra", "wangcolorextra", "wangtileextra", "chunkextra"} {
+		if strings.Contains(doc, name) {
+			t.Errorf("%s was written:\n%s", name, doc)
+		}
+	}
+}
+
+// unknownFiles are maps with unrecognized data within references to an external tileset, within
+// a template, and within custom types.
+var unknownFiles = map[string]string{
+	"level.tmx": `<?xml version="1.0" encoding="UTF-8"?>
+<map version="1.10" orientation="orthogonal" width="2" height="2" tilewidth="16" tileheight="16" nextlayerid="2" nextobjectid="2">
+ <tileset firstgid="1" source="terrain.tsx" refextra="1">
+  <refelement/>
+ </tileset>
+ <objectgroup id="1" name="objects">
+  <object id="1" template="chest.tx" x="16" y="16"/>
+ </objectgroup>
+</map>
+`,
+	"level.tmj": `{"type": "map", "version": "1.10", "orientation": "orthogonal", "width": 2, "height": 2,
+	"tilewidth": 16, "tileheight": 16, "nextlayerid": 1, "nextobjectid": 1, "infinite": false,
+	"tilesets": [{"firstgid": 1, "source": "terrain.tsx", "refextra": 2}], "layers": []}`,
+	"terrain.tsx": `<?xml version="1.0" encoding="UTF-8"?>
+<tileset version="1.10" name="terrain" tilewidth="16" tileheight="16" tilecount="4" columns="2">
+ <image source="terrain.png" width="32" height="32"/>
+</tileset>
+`,
+	"chest.tx": `<?xml version="1.0" encoding="UTF-8"?>
+<template templateextra="3">
+ <templateelement/>
+ <object name="chest" width="16" height="16"/>
+</template>
+`,
+	"chest.tj": `{"type": "template", "templateextra": 4, "object": {"name": "chest", "width": 16, "height": 16}}`,
+	"objecttypes.xml": `<?xml version="1.0" encoding="UTF-8"?>
+<objecttypes>
+ <objecttype name="enemy" color="#ffff0000" classextra="5">
+  <classelement/>
+  <property name="health" type="int" default="10"/>
+ </objecttype>
+</objecttypes>
+`,
+	"propertytypes.json": `[{"id": 7, "name": "enemy", "type": "class", "useAs": ["object"],
+	"members": [{"name": "health", "type": "int", "value": 10}]}]`,
+}
+
+func TestPreserveUnknownFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, unknownFiles)
+
+	// write reads a file and writes it to a new file, returning the document that is written
+	write := func(name, out string) string {
+		t.Helper()
+
+		path := filepath.Join(dir, name)
+		var err error
+		switch filepath.Ext(name) {
+		case ".tmx", ".tmj":
+			var m *Map
+			if m, err = ReadMap(path, FormatUnknown, nil); err == nil {
+				err = WriteMap(filepath.Join(dir, out), FormatUnknown, m)
+			}
+		case ".tx", ".tj":
+			var template *Template
+			if template, err = ReadTemplate(path, FormatUnknown, nil); err == nil {
+				err = WriteTemplate(filepath.Join(dir, out), FormatUnknown, template)
+			}
+		default:
+			setKnownTypes(t)
+			if err = LoadTypes(path); err == nil {
+				err = WriteTypes(filepath.Join(dir, out), FormatUnknown)
+			}
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		return compactJSON(readFile(t, filepath.Join(dir, out)))
+	}
+
+	tests := []struct {
+		name string
+		out  string
+		data []string
+	}{
+		{"level.tmx", "copy.tmx", []string{`refextra="1"`, `<refelement>`}},
+		{"level.tmj", "copy.tmj", []string{`"refextra": 2`}},
+		{"chest.tx", "copy.tx", []string{`templateextra="3"`, `<templateelement>`}},
+		{"chest.tj", "copy.tj", []string{`"templateextra": 4`}},
+		{"objecttypes.xml", "copy.xml", []string{`classextra="5"`, `<classelement>`}},
+		{"propertytypes.json", "copy.json", []string{`"useAs": ["object"]`}},
+	}
+
+	for _, preserve := range []bool{true, false} {
+		setPreserveUnknown(t, preserve)
+		for _, test := range tests {
+			doc := write(test.name, test.out)
+			for _, data := range test.data {
+				if strings.Contains(doc, data) != preserve {
+					t.Errorf("%s: %s written = %v, want %v:\n%s", test.name, data, !preserve, preserve, doc)
+				}
+			}
+		}
+	}
+}
+
+// vim: ts=4
@@ -1,8 +1,10 @@
 package tmx
 
 import (
+	"bytes"
 	"encoding/json"
 	"encoding/xml"
+	"strconv"
 	"time"
 )
 
@@ -20,25 +22,107 @@ type tmxFrame struct {
 // Frame describes a single frame within an animation.
 type Frame struct {
 	tmxFrame // unexported field, used for deserialization
+	// Extra contains unrecognized data that was retained while reading, see PreserveUnknown.
+	Extra Extra
+}
+
+// MarshalXML implements the xml.Marshaler interface.
+func (f Frame) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	ms := int(f.Duration / time.Millisecond)
+	start = xml.StartElement{Name: xml.Name{Local: "frame"}}
+	start.Attr = append(start.Attr, xmlID("tileid", f.ID), xmlInt("duration", ms))
+	start.Attr = f.Extra.xmlAttrs(start.Attr)
+
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := f.Extra.marshalXML(e); err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (f Frame) MarshalJSON() ([]byte, error) {
+	attrs := map[string]any{
+		"tileid":   f.ID,
+		"duration": f.Duration / time.Millisecond,
+	}
+	f.Extra.jsonAttrs(attrs)
+	return json.Marshal(attrs)
 }
 
 // UnmarshalXML implements the xml.Unmarshaler interface.
 func (f *Frame) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
-	// Simply delegate to composite type, then adjust the duration
-	if err := d.DecodeElement(&f.tmxFrame, &start); err != nil {
-		return err
+	for _, attr := range start.Attr {
+		switch attr.Name.Local {
+		case "tileid":
+			if err := f.ID.UnmarshalText([]byte(attr.Value)); err != nil {
+				return err
+			}
+		case "duration":
+			if value, err := strconv.Atoi(attr.Value); err == nil {
+				f.Duration = time.Duration(value) * time.Millisecond
+			} else {
+				return err
+			}
+		default:
+			f.Extra.attr(attr, start.Name.Local)
+		}
+	}
+
+	token, err := d.Token()
+	for token != start.End() {
+		if err != nil {
+			return err
+		}
+		if child, ok := token.(xml.StartElement); ok {
+			if err := f.Extra.elem(d, child, start.Name.Local); err != nil {
+				return err
+			}
+		}
+		token, err = d.Token()
 	}
-	f.Duration *= time.Millisecond
 	return nil
 }
 
 // UnmarshalJSON implements the json.Unmarshaler interface.
 func (f *Frame) UnmarshalJSON(data []byte) error {
-	// Simply delegate to composite type, then adjust the duration
-	if err := json.Unmarshal(data, &f.tmxFrame); err != nil {
+	d := json.NewDecoder(bytes.NewReader(data))
+	token, err := d.Token()
+	if err != nil {
 		return err
+	} else if token != json.Delim('{') {
+		return ErrExpectedObject
+	}
+
+	for {
+		if token, err = d.Token(); err != nil {
+			return err
+		} else if token == json.Delim('}') {
+			break
+		}
+
+		name := token.(string)
+		switch name {
+		case "tileid":
+			if value, err := jsonProp[float64](d); err != nil {
+				return err
+			} else {
+				f.ID = TileID(value)
+			}
+		case "duration":
+			if value, err := jsonProp[float64](d); err != nil {
+				return err
+			} else {
+				f.Duration = time.Duration(value) * time.Millisecond
+			}
+		default:
+			if err := f.Extra.prop(d, name, "frame"); err != nil {
+				return err
+			}
+		}
 	}
-	f.Duration *= time.Millisecond
 	return nil
 }
 
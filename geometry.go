@@ -0,0 +1,437 @@
+package tmx
+
+import (
+	"fmt"
+	"math"
+)
+
+// HitOptions specifies the tolerances used to test objects for hits. Values that are not
+// positive are replaced by their defaults, so the zero value uses the defaults.
+type HitOptions struct {
+	// EllipseSegments is the number of vertices used to approximate the outline of ellipse
+	// objects (defaults to 32).
+	EllipseSegments int
+	// PointRadius is the distance in pixels around point objects that is considered to be within
+	// them (defaults to 1).
+	PointRadius float32
+	// LineThickness is the width in pixels of the line of polyline objects (defaults to 1).
+	LineThickness float32
+}
+
+// withDefaults returns the options with the values that are not positive replaced by their
+// defaults.
+func (opts HitOptions) withDefaults() HitOptions {
+	if opts.EllipseSegments <= 0 {
+		opts.EllipseSegments = 32
+	}
+	if opts.PointRadius <= 0 {
+		opts.PointRadius = 1
+	}
+	if opts.LineThickness <= 0 {
+		opts.LineThickness = 1
+	}
+	return opts
+}
+
+// Matrix is a 2D affine transformation, which maps a vector <x, y> to
+// <A*x + C*y + E, B*x + D*y + F>.
+type Matrix struct {
+	A, B, C, D, E, F float32
+}
+
+// IdentityMatrix returns a transformation that leaves vectors unchanged.
+func IdentityMatrix() Matrix {
+	return Matrix{A: 1, D: 1}
+}
+
+// TranslationMatrix returns a transformation that moves vectors by the given offset.
+func TranslationMatrix(x, y float32) Matrix {
+	return Matrix{A: 1, D: 1, E: x, F: y}
+}
+
+// RotationMatrix returns a transformation that rotates vectors around the origin by the given
+// amount in degrees, which is clockwise when the y-axis points down.
+func RotationMatrix(degrees float32) Matrix {
+	sin, cos := math.Sincos(float64(degrees) * math.Pi / 180)
+	return Matrix{A: float32(cos), B: float32(sin), C: float32(-sin), D: float32(cos)}
+}
+
+// ScaleMatrix returns a transformation that scales vectors by the given factor on each axis.
+func ScaleMatrix(x, y float32) Matrix {
+	return Matrix{A: x, D: y}
+}
+
+// Mul returns the transformation that applies the other transformation followed by this one.
+func (m Matrix) Mul(other Matrix) Matrix {
+	return Matrix{
+		A: m.A*other.A + m.C*other.B,
+		B: m.B*other.A + m.D*other.B,
+		C: m.A*other.C + m.C*other.D,
+		D: m.B*other.C + m.D*other.D,
+		E: m.A*other.E + m.C*other.F + m.E,
+		F: m.B*other.E + m.D*other.F + m.F,
+	}
+}
+
+// Apply returns the result of transforming a vector.
+func (m Matrix) Apply(v Vec2) Vec2 {
+	return Vec2{X: m.A*v.X + m.C*v.Y + m.E, Y: m.B*v.X + m.D*v.Y + m.F}
+}
+
+// Invert returns the transformation that reverses this one, or false when it cannot be
+// reversed, such as when it scales an axis to zero.
+func (m Matrix) Invert() (Matrix, bool) {
+	det := m.A*m.D - m.B*m.C
+	if det == 0 {
+		return Matrix{}, false
+	}
+	return Matrix{
+		A: m.D / det,
+		B: -m.B / det,
+		C: -m.C / det,
+		D: m.A / det,
+		E: (m.C*m.F - m.D*m.E) / det,
+		F: (m.B*m.E - m.A*m.F) / det,
+	}, true
+}
+
+// String implements the Stringer interface.
+func (m Matrix) String() string {
+	return fmt.Sprintf("[%f, %f, %f, %f, %f, %f]", m.A, m.B, m.C, m.D, m.E, m.F)
+}
+
+// Transform returns the transformation from the local coordinates of the object to the pixel
+// coordinates of the map, as the object is rendered by the Tiled editor. The offset of the layer
+// the object is within is not included.
+//
+// Rectangles, ellipses, tile objects, and text occupy the area from <0, 0> to Size in local
+// coordinates, and the Points of polygons and polylines are already local coordinates. The
+// transformation includes the Rotation of the object around its Location, the alignment of tile
+// objects within their tileset, and on isometric maps, the projection of the object onto the
+// screen. Tile objects and text are not projected, and are always drawn upright.
+func (obj *Object) Transform() Matrix {
+	m := obj.tilemap()
+	origin := obj.Location
+	if m != nil {
+		origin = m.projection().Apply(origin)
+	}
+	xf := TranslationMatrix(origin.X, origin.Y)
+	if obj.Rotation != 0 {
+		xf = xf.Mul(RotationMatrix(obj.Rotation))
+	}
+
+	switch {
+	case obj.GID != 0:
+		ax, ay := obj.alignment().factors()
+		return xf.Mul(TranslationMatrix(-ax*obj.Size.X, -ay*obj.Size.Y))
+	case obj.Type == ObjectText:
+		return xf
+	case m != nil && m.Orientation == Isometric:
+		p := m.projection()
+		p.E, p.F = 0, 0
+		return xf.Mul(p)
+	}
+	return xf
+}
+
+// Polygon returns the vertices of the outline of the object in the pixel coordinates of the
+// map, as transformed by Transform. Rectangles, tile objects, and text have four vertices, which
+// are clockwise from the top-left corner, while ellipses are approximated with the default number
+// of vertices of HitOptions. Point objects have a single vertex at their location.
+func (obj *Object) Polygon() []Vec2 {
+	return HitOptions{}.Polygon(obj)
+}
+
+// Polygon returns the vertices of the outline of an object as described by Object.Polygon, with
+// ellipses approximated with EllipseSegments vertices.
+func (opts HitOptions) Polygon(obj *Object) []Vec2 {
+	xf := obj.Transform()
+
+	var points []Vec2
+	switch {
+	case obj.GID != 0, obj.Type == ObjectNone, obj.Type == ObjectText:
+		w, h := obj.Size.X, obj.Size.Y
+		points = []Vec2{{0, 0}, {w, 0}, {w, h}, {0, h}}
+	case obj.Type == ObjectEllipse:
+		rx, ry := obj.Size.X/2, obj.Size.Y/2
+		n := max(opts.withDefaults().EllipseSegments, 3)
+		points = make([]Vec2, n)
+		for i := range points {
+			sin, cos := math.Sincos(2 * math.Pi * float64(i) / float64(n))
+			points[i] = Vec2{X: rx + rx*float32(cos), Y: ry + ry*float32(sin)}
+		}
+	case obj.Type == ObjectPoint:
+		points = []Vec2{{0, 0}}
+	default:
+		points = make([]Vec2, len(obj.Points))
+		copy(points, obj.Points)
+	}
+
+	for i, point := range points {
+		points[i] = xf.Apply(point)
+	}
+	return points
+}
+
+// Bounds returns the smallest rectangle containing the object in the pixel coordinates of the
+// map, accounting for its rotation, alignment, and projection as described by Transform.
+func (obj *Object) Bounds() RectF {
+	if obj.GID == 0 && obj.Type == ObjectEllipse {
+		// The extents of an ellipse are found exactly, rather than from its approximation
+		xf := obj.Transform()
+		rx, ry := obj.Size.X/2, obj.Size.Y/2
+		center := xf.Apply(Vec2{X: rx, Y: ry})
+		ex := float32(math.Hypot(float64(xf.A*rx), float64(xf.C*ry)))
+		ey := float32(math.Hypot(float64(xf.B*rx), float64(xf.D*ry)))
+		return RectF{
+			Location: Vec2{X: center.X - ex, Y: center.Y - ey},
+			Size:     Vec2{X: ex * 2, Y: ey * 2},
+		}
+	}
+	return boundsOf(obj.Polygon())
+}
+
+// Contains tests whether a position in the pixel coordinates of the map is within the object,
+// accounting for its rotation, alignment, and projection as described by Transform. Point objects
+// and polylines contain positions near them, within the default tolerances of HitOptions.
+func (obj *Object) Contains(v Vec2) bool {
+	return HitOptions{}.Contains(obj, v)
+}
+
+// Contains tests whether a position is within an object as described by Object.Contains, where
+// point objects contain positions within PointRadius of them, and polylines those within half of
+// LineThickness of their line.
+func (opts HitOptions) Contains(obj *Object, v Vec2) bool {
+	if obj.GID == 0 && obj.Type == ObjectEllipse {
+		inv, ok := obj.Transform().Invert()
+		rx, ry := obj.Size.X/2, obj.Size.Y/2
+		if !ok || rx == 0 || ry == 0 {
+			return false
+		}
+		local := inv.Apply(v)
+		dx, dy := (local.X-rx)/rx, (local.Y-ry)/ry
+		return dx*dx+dy*dy <= 1
+	}
+	return opts.shape(obj).contains(v)
+}
+
+// Intersects tests whether two objects overlap, including when one is entirely within the other,
+// using the same shapes as Contains. Ellipses are approximated by their Polygon.
+func (obj *Object) Intersects(other *Object) bool {
+	return HitOptions{}.Intersects(obj, other)
+}
+
+// Intersects tests whether two objects overlap as described by Object.Intersects, using the
+// tolerances of the options.
+func (opts HitOptions) Intersects(obj, other *Object) bool {
+	a, b := opts.shape(obj), opts.shape(other)
+	if len(a.points) == 0 || len(b.points) == 0 {
+		return false
+	}
+	reach := a.radius + b.radius
+	ba, bb := boundsOf(a.points), boundsOf(b.points)
+	if ba.Left()-reach > bb.Right() || bb.Left()-reach > ba.Right() ||
+		ba.Top()-reach > bb.Bottom() || bb.Top()-reach > ba.Bottom() {
+		return false
+	}
+
+	if (a.closed && a.encloses(b.points[0])) || (b.closed && b.encloses(a.points[0])) {
+		return true
+	}
+	for i, n := 0, a.segments(); i < n; i++ {
+		a0, a1 := a.segment(i)
+		for j, m := 0, b.segments(); j < m; j++ {
+			b0, b1 := b.segment(j)
+			if segmentDistance(a0, a1, b0, b1) <= float64(reach) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// shape is the outline of an object in the pixel coordinates of the map used for hit testing.
+type shape struct {
+	// points are the vertices of the outline.
+	points []Vec2
+	// closed determines whether the outline encloses an area, or is only a line.
+	closed bool
+	// radius is the distance around the outline that is considered to be within it.
+	radius float32
+}
+
+// shape returns the outline of an object used for hit testing.
+func (opts HitOptions) shape(obj *Object) shape {
+	points := opts.Polygon(obj)
+	switch {
+	case obj.GID != 0:
+		return shape{points: points, closed: true}
+	case obj.Type == ObjectPoint:
+		return shape{points: points, radius: opts.withDefaults().PointRadius}
+	case obj.Type == ObjectPolyline:
+		return shape{points: points, radius: opts.withDefaults().LineThickness / 2}
+	}
+	return shape{points: points, closed: len(points) >= 3}
+}
+
+// contains tests whether a position is within the shape.
+func (s shape) contains(v Vec2) bool {
+	if s.closed && s.encloses(v) {
+		return true
+	}
+	for i, n := 0, s.segments(); i < n; i++ {
+		p0, p1 := s.segment(i)
+		if pointSegmentDistance(v, p0, p1) <= float64(s.radius) {
+			return true
+		}
+	}
+	return false
+}
+
+// encloses tests whether a position is within the area of a closed shape, using the even-odd
+// rule.
+func (s shape) encloses(v Vec2) bool {
+	inside := false
+	for i, j := 0, len(s.points)-1; i < len(s.points); j, i = i, i+1 {
+		pi, pj := s.points[i], s.points[j]
+		if (pi.Y > v.Y) != (pj.Y > v.Y) && v.X <= (pj.X-pi.X)*(v.Y-pi.Y)/(pj.Y-pi.Y)+pi.X {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// segments returns the number of line segments of the outline. A shape with a single vertex has
+// one segment of zero length.
+func (s shape) segments() int {
+	switch {
+	case len(s.points) <= 1:
+		return len(s.points)
+	case s.closed:
+		return len(s.points)
+	}
+	return len(s.points) - 1
+}
+
+// segment returns the endpoints of a line segment of the outline.
+func (s shape) segment(i int) (Vec2, Vec2) {
+	return s.points[i], s.points[(i+1)%len(s.points)]
+}
+
+// pointSegmentDistance returns the distance from a position to the nearest point on a line
+// segment.
+func pointSegmentDistance(v, p0, p1 Vec2) float64 {
+	px, py := float64(v.X-p0.X), float64(v.Y-p0.Y)
+	dx, dy := float64(p1.X-p0.X), float64(p1.Y-p0.Y)
+	if length := dx*dx + dy*dy; length > 0 {
+		t := math.Max(0, math.Min(1, (px*dx+py*dy)/length))
+		px, py = px-t*dx, py-t*dy
+	}
+	return math.Hypot(px, py)
+}
+
+// segmentDistance returns the distance between the nearest points of two line segments, which
+// is 0 when they cross.
+func segmentDistance(a0, a1, b0, b1 Vec2) float64 {
+	cross := func(o, p, q Vec2) float64 {
+		return float64(p.X-o.X)*float64(q.Y-o.Y) - float64(p.Y-o.Y)*float64(q.X-o.X)
+	}
+	d0, d1 := cross(b0, b1, a0), cross(b0, b1, a1)
+	d2, d3 := cross(a0, a1, b0), cross(a0, a1, b1)
+	if ((d0 > 0 && d1 < 0) || (d0 < 0 && d1 > 0)) && ((d2 > 0 && d3 < 0) || (d2 < 0 && d3 > 0)) {
+		return 0
+	}
+	return min(
+		pointSegmentDistance(a0, b0, b1),
+		pointSegmentDistance(a1, b0, b1),
+		pointSegmentDistance(b0, a0, a1),
+		pointSegmentDistance(b1, a0, a1),
+	)
+}
+
+// tilemap returns the map the object is within, or nil when it is not within one.
+func (obj *Object) tilemap() *Map {
+	if obj.layer != nil {
+		return obj.layer.parent
+	}
+	return nil
+}
+
+// tileset returns the tileset of a tile object, or nil when it is not a tile object or the
+// tileset cannot be found.
+func (obj *Object) tileset() *Tileset {
+	if obj.GID == 0 {
+		return nil
+	}
+	// Tiles inherited from a template refer to the tileset of the template
+	if obj.Template != nil && obj.flags&flagGID == 0 {
+		if obj.Template.Tileset != nil {
+			return obj.Template.Tileset.Tileset
+		}
+		return nil
+	}
+	if m := obj.tilemap(); m != nil {
+		ts, _ := m.Tileset(obj.GID)
+		return ts
+	}
+	return nil
+}
+
+// alignment returns the point of a tile object that is placed at its location. When the tileset
+// does not specify one, it is the bottom-center on isometric maps, and bottom-left on all others.
+func (obj *Object) alignment() Align {
+	if ts := obj.tileset(); ts != nil && ts.ObjectAlign != AlignUnspecified {
+		return ts.ObjectAlign
+	}
+	if m := obj.tilemap(); m != nil && m.Orientation == Isometric {
+		return AlignBottom
+	}
+	return AlignBottomLeft
+}
+
+// factors returns the position of the alignment within a rectangle on each axis, where 0 is the
+// left or top edge, and 1 is the right or bottom edge. An axis without a single edge specified
+// is centered.
+func (align Align) factors() (float32, float32) {
+	x, y := float32(0.5), float32(0.5)
+	switch align & AlignCenterH {
+	case AlignLeft:
+		x = 0
+	case AlignRight:
+		x = 1
+	}
+	switch align & AlignCenterV {
+	case AlignTop:
+		y = 0
+	case AlignBottom:
+		y = 1
+	}
+	return x, y
+}
+
+// projection returns the transformation from the pixel coordinates used by objects to those the
+// map is rendered with. Objects on isometric maps use the tile height as the unit of both axes,
+// and are projected onto the screen, while all other orientations are unchanged.
+func (m *Map) projection() Matrix {
+	if m.Orientation != Isometric || m.TileSize.Height == 0 {
+		return IdentityMatrix()
+	}
+	tw, th := float32(m.TileSize.Width), float32(m.TileSize.Height)
+	ratio := tw / th / 2
+	return Matrix{A: ratio, B: 0.5, C: -ratio, D: 0.5, E: float32(m.Size.Height) * tw / 2}
+}
+
+// boundsOf returns the smallest rectangle containing all of the given points.
+func boundsOf(points []Vec2) RectF {
+	if len(points) == 0 {
+		return RectF{}
+	}
+	lo, hi := points[0], points[0]
+	for _, point := range points[1:] {
+		lo.X, lo.Y = min(lo.X, point.X), min(lo.Y, point.Y)
+		hi.X, hi.Y = max(hi.X, point.X), max(hi.Y, point.Y)
+	}
+	return RectF{Location: lo, Size: Vec2{X: hi.X - lo.X, Y: hi.Y - lo.Y}}
+}
+
+// vim: ts=4
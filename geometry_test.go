@@ -0,0 +1,365 @@
+package tmx
+
+import (
+	"math"
+	"sync"
+	"testing"
+)
+
+// nearVec tests whether two vectors are equal within a small tolerance.
+func nearVec(a, b Vec2) bool {
+	return math.Abs(float64(a.X-b.X)) < 1e-3 && math.Abs(float64(a.Y-b.Y)) < 1e-3
+}
+
+// nearPoints tests whether two lists of vectors are equal within a small tolerance.
+func nearPoints(a, b []Vec2) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !nearVec(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// nearRect tests whether two rectangles are equal within a small tolerance.
+func nearRect(a, b RectF) bool {
+	return nearVec(a.Location, b.Location) && nearVec(a.Size, b.Size)
+}
+
+// objectMap returns a map of the given orientation with a tileset and an object layer, to which
+// the objects are added.
+func objectMap(orientation Orientation, tileSize Size, align Align, objects ...Object) []*Object {
+	m := NewMap(orientation, Size{Width: 4, Height: 4}, tileSize)
+	image := &Image{Source: "terrain.png", Size: Size{Width: 64, Height: 64}}
+	ts := NewTileset("terrain", Size{Width: 16, Height: 16}, image, 0, 0)
+	ts.ObjectAlign = align
+	m.AddTileset(ts)
+	layer := NewObjectLayer("objects")
+	m.AddLayer(layer)
+	for _, obj := range objects {
+		layer.AddObject(obj)
+	}
+
+	result := make([]*Object, len(layer.Objects))
+	for i := range layer.Objects {
+		result[i] = &layer.Objects[i]
+	}
+	return result
+}
+
+func TestMatrix(t *testing.T) {
+	v := Vec2{X: 3, Y: 4}
+	tests := []struct {
+		name string
+		m    Matrix
+		want Vec2
+	}{
+		{"identity", IdentityMatrix(), Vec2{3, 4}},
+		{"translate", TranslationMatrix(10, -2), Vec2{13, 2}},
+		{"rotate", RotationMatrix(90), Vec2{-4, 3}},
+		{"scale", ScaleMatrix(2, 0.5), Vec2{6, 2}},
+		// The right side is applied first
+		{"translate then rotate", RotationMatrix(90).Mul(TranslationMatrix(1, 0)), Vec2{-4, 4}},
+		{"rotate then translate", TranslationMatrix(1, 0).Mul(RotationMatrix(90)), Vec2{-3, 3}},
+	}
+	for _, test := range tests {
+		got := test.m.Apply(v)
+		if !nearVec(got, test.want) {
+			t.Errorf("%s: %v is %v, want %v", test.name, v, got, test.want)
+		}
+		inv, ok := test.m.Invert()
+		if !ok || !nearVec(inv.Apply(got), v) {
+			t.Errorf("%s: inverse of %v is %v", test.name, test.m, inv)
+		}
+	}
+	if _, ok := ScaleMatrix(0, 1).Invert(); ok {
+		t.Error("inverted a matrix without an inverse")
+	}
+}
+
+func TestObjectPolygon(t *testing.T) {
+	tests := []struct {
+		name   string
+		obj    Object
+		want   []Vec2
+		bounds RectF
+	}{
+		{"rectangle", Object{Location: Vec2{10, 20}, Size: Vec2{4, 2}},
+			[]Vec2{{10, 20}, {14, 20}, {14, 22}, {10, 22}}, RectF{Vec2{10, 20}, Vec2{4, 2}}},
+		// Objects rotate clockwise around their location
+		{"rotated rectangle", Object{Location: Vec2{10, 20}, Size: Vec2{4, 2}, Rotation: 90},
+			[]Vec2{{10, 20}, {10, 24}, {8, 24}, {8, 20}}, RectF{Vec2{8, 20}, Vec2{2, 4}}},
+		{"polygon", Object{Location: Vec2{5, 5}, Type: ObjectPolygon, Points: []Vec2{{0, 0}, {10, 0}, {0, -5}}},
+			[]Vec2{{5, 5}, {15, 5}, {5, 0}}, RectF{Vec2{5, 0}, Vec2{10, 5}}},
+		{"rotated polyline", Object{Location: Vec2{5, 5}, Type: ObjectPolyline, Rotation: 180, Points: []Vec2{{0, 0}, {10, 2}}},
+			[]Vec2{{5, 5}, {-5, 3}}, RectF{Vec2{-5, 3}, Vec2{10, 2}}},
+		{"point", Object{Location: Vec2{7, 8}, Type: ObjectPoint, Rotation: 45},
+			[]Vec2{{7, 8}}, RectF{Location: Vec2{7, 8}}},
+		{"text", Object{Location: Vec2{0, 0}, Size: Vec2{30, 10}, Type: ObjectText, Text: &Text{Value: "a"}},
+			[]Vec2{{0, 0}, {30, 0}, {30, 10}, {0, 10}}, RectF{Size: Vec2{30, 10}}},
+		// Tile objects are placed by their bottom-left corner
+		{"tile", Object{Location: Vec2{32, 48}, Size: Vec2{16, 16}, GID: 1},
+			[]Vec2{{32, 32}, {48, 32}, {48, 48}, {32, 48}}, RectF{Vec2{32, 32}, Vec2{16, 16}}},
+		{"rotated tile", Object{Location: Vec2{32, 48}, Size: Vec2{16, 16}, GID: 1, Rotation: 90},
+			[]Vec2{{48, 48}, {48, 64}, {32, 64}, {32, 48}}, RectF{Vec2{32, 48}, Vec2{16, 16}}},
+	}
+	for _, test := range tests {
+		obj := objectMap(Orthogonal, Size{Width: 16, Height: 16}, AlignUnspecified, test.obj)[0]
+		if got := obj.Polygon(); !nearPoints(got, test.want) {
+			t.Errorf("%s: polygon is %v, want %v", test.name, got, test.want)
+		}
+		if got := obj.Bounds(); !nearRect(got, test.bounds) {
+			t.Errorf("%s: bounds are %v, want %v", test.name, got, test.bounds)
+		}
+	}
+}
+
+func TestEllipsePolygon(t *testing.T) {
+	obj := objectMap(Orthogonal, Size{Width: 16, Height: 16}, AlignUnspecified,
+		Object{Location: Vec2{0, 0}, Size: Vec2{20, 10}, Type: ObjectEllipse, Rotation: 90})[0]
+
+	// Every vertex is on the outline of the rotated ellipse, centered at <-5, 10>
+	points := obj.Polygon()
+	if want := (HitOptions{}).withDefaults().EllipseSegments; len(points) != want {
+		t.Fatalf("ellipse has %d vertices, want %d", len(points), want)
+	}
+	for _, p := range points {
+		dx, dy := float64(p.X+5)/5, float64(p.Y-10)/10
+		if d := dx*dx + dy*dy; math.Abs(d-1) > 1e-3 {
+			t.Errorf("vertex %v is not on the outline", p)
+		}
+	}
+
+	// The bounds are exact, rather than those of the vertices
+	if got, want := obj.Bounds(), (RectF{Vec2{-10, 0}, Vec2{10, 20}}); !nearRect(got, want) {
+		t.Errorf("bounds are %v, want %v", got, want)
+	}
+	obj.Rotation = 45
+	r := float32(math.Sqrt((100 + 25) / 2.0))
+	if got := obj.Bounds(); !nearVec(got.Size, Vec2{2 * r, 2 * r}) {
+		t.Errorf("rotated bounds are %v, want size %v", got, 2*r)
+	}
+}
+
+func TestObjectAlignment(t *testing.T) {
+	tests := []struct {
+		align Align
+		want  RectF
+	}{
+		{AlignUnspecified, RectF{Vec2{32, 32}, Vec2{16, 16}}},
+		{AlignTopLeft, RectF{Vec2{32, 48}, Vec2{16, 16}}},
+		{AlignCenter, RectF{Vec2{24, 40}, Vec2{16, 16}}},
+		{AlignBottomRight, RectF{Vec2{16, 32}, Vec2{16, 16}}},
+		{AlignTop, RectF{Vec2{24, 48}, Vec2{16, 16}}},
+	}
+	for _, test := range tests {
+		obj := objectMap(Orthogonal, Size{Width: 16, Height: 16}, test.align,
+			Object{Location: Vec2{32, 48}, Size: Vec2{16, 16}, GID: 1})[0]
+		if got := obj.Bounds(); !nearRect(got, test.want) {
+			t.Errorf("%v: bounds are %v, want %v", test.align, got, test.want)
+		}
+	}
+
+	// Objects that are not within a map use the default alignment of orthogonal maps
+	obj := &Object{Location: Vec2{32, 48}, Size: Vec2{16, 16}, GID: 1}
+	if got := obj.Bounds(); !nearRect(got, RectF{Vec2{32, 32}, Vec2{16, 16}}) {
+		t.Errorf("bounds are %v", got)
+	}
+}
+
+func TestIsometricObjects(t *testing.T) {
+	objects := objectMap(Isometric, Size{Width: 32, Height: 16}, AlignUnspecified,
+		// A rectangle covering the tile at <1, 0>, in the units of the tile height
+		Object{Location: Vec2{16, 0}, Size: Vec2{16, 16}},
+		// Tile objects are placed upright by their bottom-center
+		Object{Location: Vec2{16, 16}, Size: Vec2{32, 16}, GID: 1},
+		Object{Location: Vec2{16, 0}, Type: ObjectPoint},
+	)
+
+	// The rectangle is projected onto the diamond of the tile
+	tile := objects[0].tilemap().TileToPixel(Point{X: 1, Y: 0})
+	want := []Vec2{{tile.X + 16, tile.Y}, {tile.X + 32, tile.Y + 8}, {tile.X + 16, tile.Y + 16}, {tile.X, tile.Y + 8}}
+	if got := objects[0].Polygon(); !nearPoints(got, want) {
+		t.Errorf("rectangle is %v, want %v", got, want)
+	}
+	if got := objects[0].Bounds(); !nearRect(got, RectF{tile, Vec2{32, 16}}) {
+		t.Errorf("rectangle bounds are %v", got)
+	}
+
+	want = []Vec2{{48, 0}, {80, 0}, {80, 16}, {48, 16}}
+	if got := objects[1].Polygon(); !nearPoints(got, want) {
+		t.Errorf("tile is %v, want %v", got, want)
+	}
+	if got := objects[2].Polygon(); !nearPoints(got, []Vec2{{tile.X + 16, tile.Y}}) {
+		t.Errorf("point is %v", got)
+	}
+}
+
+func TestObjectContains(t *testing.T) {
+	objects := objectMap(Orthogonal, Size{Width: 16, Height: 16}, AlignUnspecified,
+		// Covers <8, 20> to <10, 24> when rotated
+		Object{Name: "rotated", Location: Vec2{10, 20}, Size: Vec2{4, 2}, Rotation: 90},
+		// Covers <-10, 0> to <0, 20> when rotated, centered at <-5, 10>
+		Object{Name: "ellipse", Size: Vec2{20, 10}, Type: ObjectEllipse, Rotation: 90},
+		Object{Name: "polyline", Location: Vec2{0, 50}, Type: ObjectPolyline, Points: []Vec2{{0, 0}, {10, 0}, {10, 10}}},
+		Object{Name: "triangle", Location: Vec2{50, 50}, Type: ObjectPolygon, Points: []Vec2{{0, 0}, {10, 0}, {0, 10}}},
+		Object{Name: "point", Location: Vec2{30, 30}, Type: ObjectPoint},
+		Object{Name: "tile", Location: Vec2{64, 16}, Size: Vec2{16, 16}, GID: 1, Rotation: 180},
+	)
+	tests := []struct {
+		obj  int
+		v    Vec2
+		want bool
+	}{
+		{0, Vec2{9, 22}, true},
+		{0, Vec2{8, 20}, true},
+		{0, Vec2{11, 22}, false},
+		{0, Vec2{12, 21}, false},
+		{1, Vec2{-5, 10}, true},
+		{1, Vec2{-5, 19.5}, true},
+		{1, Vec2{-9.5, 10}, true},
+		{1, Vec2{-9, 3}, false},
+		{1, Vec2{5, 5}, false},
+		{2, Vec2{5, 50}, true},
+		{2, Vec2{5, 50.4}, true},
+		{2, Vec2{5, 51}, false},
+		{2, Vec2{10.4, 55}, true},
+		{2, Vec2{5, 55}, false},
+		{3, Vec2{52, 52}, true},
+		{3, Vec2{58, 58}, false},
+		{4, Vec2{30, 30}, true},
+		{4, Vec2{30.7, 30.7}, true},
+		{4, Vec2{31, 31}, false},
+		// Rotated about its bottom-left corner, the tile covers <48, 16> to <64, 32>
+		{5, Vec2{56, 24}, true},
+		{5, Vec2{70, 8}, false},
+	}
+	for _, test := range tests {
+		obj := objects[test.obj]
+		if got := obj.Contains(test.v); got != test.want {
+			t.Errorf("%s: contains %v is %v, want %v", obj.Name, test.v, got, test.want)
+		}
+	}
+}
+
+func TestObjectIntersects(t *testing.T) {
+	objects := objectMap(Orthogonal, Size{Width: 16, Height: 16}, AlignUnspecified,
+		Object{Name: "square", Location: Vec2{0, 0}, Size: Vec2{10, 10}},
+		// A diamond from <16.5, -2> to <23.5, 5>, which does not reach the square
+		Object{Name: "diamond", Location: Vec2{20, -2}, Size: Vec2{5, 5}, Rotation: 45},
+		// A diamond from <8.5, -2> to <15.5, 5>, whose left corner is within the square
+		Object{Name: "overlap", Location: Vec2{12, -2}, Size: Vec2{5, 5}, Rotation: 45},
+		Object{Name: "inner", Location: Vec2{2, 2}, Size: Vec2{2, 2}},
+		Object{Name: "ellipse", Location: Vec2{-12, 0}, Size: Vec2{10, 10}, Type: ObjectEllipse},
+		Object{Name: "line", Location: Vec2{-5, 5}, Type: ObjectPolyline, Points: []Vec2{{0, 0}, {-5, 0}}},
+		Object{Name: "far line", Location: Vec2{-5, 30}, Type: ObjectPolyline, Points: []Vec2{{0, 0}, {40, 0}}},
+		Object{Name: "point", Location: Vec2{10.5, 5}, Type: ObjectPoint},
+		Object{Name: "near point", Location: Vec2{11.5, 5}, Type: ObjectPoint},
+	)
+	names := make(map[string]*Object)
+	for _, obj := range objects {
+		names[obj.Name] = obj
+	}
+
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"square", "diamond", false},
+		{"square", "overlap", true},
+		{"square", "inner", true},
+		{"square", "ellipse", false},
+		{"ellipse", "line", true},
+		{"square", "line", false},
+		{"far line", "square", false},
+		{"square", "point", true},
+		{"point", "near point", true},
+		{"square", "near point", false},
+		{"diamond", "overlap", false},
+	}
+	for _, test := range tests {
+		a, b := names[test.a], names[test.b]
+		if got := a.Intersects(b); got != test.want {
+			t.Errorf("%s intersects %s is %v, want %v", test.a, test.b, got, test.want)
+		}
+		if got := b.Intersects(a); got != test.want {
+			t.Errorf("%s intersects %s is %v, want %v", test.b, test.a, got, test.want)
+		}
+	}
+}
+
+func TestHitOptions(t *testing.T) {
+	objects := objectMap(Orthogonal, Size{Width: 16, Height: 16}, AlignUnspecified,
+		// Covers <8, 20> to <10, 24> when rotated
+		Object{Name: "rotated", Location: Vec2{10, 20}, Size: Vec2{4, 2}, Rotation: 90},
+		// Covers <-10, 0> to <0, 20> when rotated, centered at <-5, 10>
+		Object{Name: "ellipse", Size: Vec2{20, 10}, Type: ObjectEllipse, Rotation: 90},
+		Object{Name: "polyline", Location: Vec2{0, 50}, Type: ObjectPolyline, Points: []Vec2{{0, 0}, {10, 0}, {10, 10}}},
+		Object{Name: "point", Location: Vec2{30, 30}, Type: ObjectPoint},
+		Object{Name: "near point", Location: Vec2{28, 34}, Size: Vec2{4, 4}},
+		Object{Name: "near polyline", Location: Vec2{11.5, 52}, Size: Vec2{4, 4}},
+	)
+	wide := HitOptions{EllipseSegments: 6, PointRadius: 5, LineThickness: 4}
+
+	if points := wide.Polygon(objects[1]); len(points) != 6 {
+		t.Errorf("ellipse has %d vertices, want 6", len(points))
+	}
+
+	tests := []struct {
+		obj          int
+		v            Vec2
+		def, options bool
+	}{
+		{0, Vec2{9, 22}, true, true},
+		{0, Vec2{11, 22}, false, false},
+		{1, Vec2{-5, 19.5}, true, true},
+		{1, Vec2{-9, 3}, false, false},
+		{2, Vec2{5, 51.5}, false, true},
+		{2, Vec2{5, 52.5}, false, false},
+		{2, Vec2{11.5, 55}, false, true},
+		{3, Vec2{33, 33}, false, true},
+		{3, Vec2{34, 34}, false, false},
+	}
+	for _, test := range tests {
+		obj := objects[test.obj]
+		if got := (HitOptions{}).Contains(obj, test.v); got != test.def {
+			t.Errorf("%s: contains %v is %v, want %v", obj.Name, test.v, got, test.def)
+		}
+		if got := wide.Contains(obj, test.v); got != test.options {
+			t.Errorf("%s: contains %v with options is %v, want %v", obj.Name, test.v, got, test.options)
+		}
+	}
+
+	// The polyline and point only reach the rectangles near them with the options
+	for _, pair := range [][2]int{{2, 5}, {3, 4}} {
+		a, b := objects[pair[0]], objects[pair[1]]
+		if a.Intersects(b) || b.Intersects(a) {
+			t.Errorf("%s intersects %s without the options", a.Name, b.Name)
+		}
+		if !wide.Intersects(a, b) || !wide.Intersects(b, a) {
+			t.Errorf("%s does not intersect %s with the options", a.Name, b.Name)
+		}
+	}
+	if !wide.Intersects(objects[0], objects[0]) || wide.Intersects(objects[0], objects[1]) {
+		t.Errorf("rotated rectangle intersects are wrong with the options")
+	}
+
+	// Options used at the same time do not affect each other
+	var wg sync.WaitGroup
+	for i := 1; i <= 8; i++ {
+		wg.Add(1)
+		go func(radius float32) {
+			defer wg.Done()
+			opts := HitOptions{PointRadius: radius}
+			if got := opts.Contains(objects[3], Vec2{30, 30 + 4.5}); got != (radius >= 4.5) {
+				t.Errorf("radius %v: contains is %v", radius, got)
+			}
+		}(float32(i))
+	}
+	wg.Wait()
+}
+
+// vim: ts=4
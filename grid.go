@@ -1,6 +1,9 @@
 package tmx
 
-import "fmt"
+import (
+	"encoding/xml"
+	"fmt"
+)
 
 // Grid describes grid settings used for tiles in a tileset.
 type Grid struct {
@@ -23,4 +26,13 @@ func (g Grid) String() string {
 	return fmt.Sprintf("%dx%d (%s)", g.Width, g.Height, g.Orientation.String())
 }
 
+// MarshalXML implements the xml.Marshaler interface.
+func (g Grid) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return xmlEmpty(e, "grid",
+		xmlStr("orientation", g.Orientation.String()),
+		xmlInt("width", g.Width),
+		xmlInt("height", g.Height),
+	)
+}
+
 // vim: ts=4
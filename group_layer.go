@@ -1,6 +1,9 @@
 package tmx
 
-import "encoding/xml"
+import (
+	"encoding/json"
+	"encoding/xml"
+)
 
 // GroupLayer is a map layer that acts as a container for other map layers. Its offset,
 // visibility, opacity, and tint recursively affect child layers.
@@ -9,6 +12,52 @@ type GroupLayer struct {
 	container
 }
 
+// NewGroupLayer creates a new empty group layer with the given name. The group and its children
+// are assigned IDs when it is added to a map.
+func NewGroupLayer(name string) *GroupLayer {
+	var layer GroupLayer
+	layer.initDefaults(LayerGroup)
+	layer.Name = name
+	return &layer
+}
+
+// MarshalXML implements the xml.Marshaler interface.
+func (layer *GroupLayer) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return layer.encodeXML(nil, e, start)
+}
+
+// encodeXML implements the xmlEncoding interface.
+func (layer *GroupLayer) encodeXML(enc *encoder, e *xml.Encoder, start xml.StartElement) error {
+	start = xml.StartElement{Name: xml.Name{Local: "group"}, Attr: layer.xmlAttrs()}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := layer.Extra.marshalXML(e); err != nil {
+		return err
+	}
+	if err := e.EncodeElement(enc.xml(layer.Properties.owned(&layer.Extra)), start); err != nil {
+		return err
+	}
+	for child := layer.Head(); child != nil; child = child.Next() {
+		if err := e.EncodeElement(enc.xml(child), start); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (layer *GroupLayer) MarshalJSON() ([]byte, error) {
+	return layer.encodeJSON(nil)
+}
+
+// encodeJSON implements the jsonEncoding interface.
+func (layer *GroupLayer) encodeJSON(enc *encoder) ([]byte, error) {
+	attrs := layer.jsonAttrs(enc, LayerGroup)
+	attrs["layers"] = jsonLayers(enc, layer.Head())
+	return json.Marshal(attrs)
+}
+
 // UnmarshalXML implements the xml.Unmarshaler interface.
 func (layer *GroupLayer) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	layer.initDefaults(LayerGroup)
@@ -19,7 +68,7 @@ func (layer *GroupLayer) UnmarshalXML(d *xml.Decoder, start xml.StartElement) er
 		} else if handled {
 			continue
 		}
-		logAttr(attr.Name.Local, start.Name.Local)
+		layer.Extra.attr(attr, start.Name.Local)
 	}
 
 	token, err := d.Token()
@@ -58,7 +107,9 @@ func (layer *GroupLayer) UnmarshalXML(d *xml.Decoder, start xml.StartElement) er
 					}
 					layer.AddLayer(&value)
 				default:
-					logElem(child.Name.Local, start.Name.Local)
+					if err := layer.Extra.elem(d, child, start.Name.Local); err != nil {
+						return err
+					}
 				}
 			}
 		}
@@ -68,10 +119,136 @@ func (layer *GroupLayer) UnmarshalXML(d *xml.Decoder, start xml.StartElement) er
 	return nil
 }
 
-// AddLayer appends a new layer to the group.
-func (g *GroupLayer) AddLayer(layer Layer) {
-	g.container.AddLayer(layer)
-	g.head.setParent(g.parent)
+// AddLayer appends a new layer to the group. When the layer is already within a container, it
+// is moved. Returns ErrCyclicLayer without any effect when the layer is the group itself, or one
+// of its ancestors.
+func (g *GroupLayer) AddLayer(layer Layer) error {
+	return insertLayer(g, layer, nil)
+}
+
+// InsertBefore inserts a layer into the group before the mark layer, which must be a child of
+// the group. When the layer is already within a container, it is moved.
+func (g *GroupLayer) InsertBefore(layer, mark Layer) error {
+	if mark == nil {
+		return ErrNotChild
+	}
+	return insertLayer(g, layer, mark)
+}
+
+// InsertAfter inserts a layer into the group after the mark layer, which must be a child of the
+// group. When the layer is already within a container, it is moved.
+func (g *GroupLayer) InsertAfter(layer, mark Layer) error {
+	if mark == nil || mark.Container() != Container(g) {
+		return ErrNotChild
+	}
+	return insertLayer(g, layer, mark.Next())
+}
+
+// RemoveLayer removes a child layer from the group.
+func (g *GroupLayer) RemoveLayer(layer Layer) error {
+	return removeLayer(g, layer)
+}
+
+// MoveLayer moves a child layer of the group to the given index, where 0 is the head.
+func (g *GroupLayer) MoveLayer(layer Layer, index int) error {
+	return moveLayer(g, layer, index)
+}
+
+// setParent implements the Layer interface, propagating the parent map to all child layers.
+func (g *GroupLayer) setParent(parent *Map) {
+	g.baseLayer.setParent(parent)
+	for child := g.Head(); child != nil; child = child.Next() {
+		child.setParent(parent)
+	}
+}
+
+// Clone creates a deep copy of the GroupLayer and all of its children. The copy is not within
+// any map or group.
+func (g *GroupLayer) Clone() *GroupLayer {
+	dup := &GroupLayer{baseLayer: g.baseLayer.clone()}
+	for child := g.Head(); child != nil; child = child.Next() {
+		dup.AddLayer(cloneLayer(child))
+	}
+	return dup
+}
+
+// Flatten merges each run of adjacent visible tile layers within the group into the bottom-most
+// layer of the run with MergeLayers, and removes the others, which reduces the number of layers
+// that must be drawn without changing how the group is rendered. Layers are only merged when
+// every filter returns true for them.
+//
+// A run is ended by any other layer, including tile layers that are hidden or rejected by a
+// filter, and by a tile layer that is drawn differently, with a different Opacity, TintColor,
+// Offset, Parallax, or size. Visible nested groups are flattened the same way, separately from
+// the layers around them, as the opacity, tint, offset, and parallax of the group also apply to
+// its children.
+//
+// Returns the layers that others were merged into.
+func (g *GroupLayer) Flatten(filters ...func(layer *TileLayer) bool) ([]*TileLayer, error) {
+	accept := func(layer *TileLayer) bool {
+		if !layer.Visible {
+			return false
+		}
+		for _, filter := range filters {
+			if !filter(layer) {
+				return false
+			}
+		}
+		return true
+	}
+	return flattenLayers(g, accept)
+}
+
+// flattenLayers merges the runs of adjacent tile layers within a container that are accepted
+// and drawn the same way, and flattens its nested groups, returning the layers others were merged
+// into.
+func flattenLayers(owner Container, accept func(layer *TileLayer) bool) ([]*TileLayer, error) {
+	var children []Layer
+	for child := owner.Head(); child != nil; child = child.Next() {
+		children = append(children, child)
+	}
+
+	var merged, run []*TileLayer
+	flush := func() error {
+		if len(run) > 1 {
+			if err := MergeLayers(run[0], run[1:]...); err != nil {
+				return err
+			}
+			for _, layer := range run[1:] {
+				if err := removeLayer(owner, layer); err != nil {
+					return err
+				}
+			}
+			merged = append(merged, run[0])
+		}
+		run = nil
+		return nil
+	}
+
+	for _, child := range children {
+		if tiles, ok := child.(*TileLayer); ok && accept(tiles) {
+			if len(run) > 0 && !run[0].drawsLike(tiles) {
+				if err := flush(); err != nil {
+					return merged, err
+				}
+			}
+			run = append(run, tiles)
+			continue
+		}
+
+		if err := flush(); err != nil {
+			return merged, err
+		}
+		if group, ok := child.(*GroupLayer); ok && group.Visible {
+			nested, err := flattenLayers(group, accept)
+			merged = append(merged, nested...)
+			if err != nil {
+				return merged, err
+			}
+		}
+	}
+	err := flush()
+	return merged, err
 }
 
 // vim: ts=4
@@ -0,0 +1,139 @@
+package tmx
+
+import (
+	"slices"
+	"testing"
+)
+
+// filledLayer returns a tile layer of the map size with a single tile set.
+func filledLayer(m *Map, name string, x, y int, gid TileID) *TileLayer {
+	layer := NewTileLayer(name, m.Size)
+	layer.SetGID(x, y, gid)
+	return layer
+}
+
+// flatten flattens a group, failing the test on errors, and returns the names of the layers that
+// others were merged into.
+func flatten(t *testing.T, g *GroupLayer, filters ...func(layer *TileLayer) bool) []string {
+	t.Helper()
+
+	merged, err := g.Flatten(filters...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, len(merged))
+	for i, layer := range merged {
+		names[i] = layer.Name
+	}
+	return names
+}
+
+func TestFlatten(t *testing.T) {
+	m := NewMap(Orthogonal, Size{Width: 4, Height: 4}, Size{Width: 16, Height: 16})
+	root := NewGroupLayer("root")
+	m.AddLayer(root)
+
+	tiles := make(map[string]*TileLayer)
+	add := func(owner *GroupLayer, name string, x, y int, gid TileID) *TileLayer {
+		tiles[name] = filledLayer(m, name, x, y, gid)
+		owner.AddLayer(tiles[name])
+		return tiles[name]
+	}
+	nested := NewGroupLayer("nested")
+	add(root, "a", 0, 0, 1)
+	add(root, "b", 0, 0, 2)
+	root.AddLayer(NewObjectLayer("objects"))
+	add(root, "c", 1, 1, 3)
+	add(root, "hidden", 3, 3, 9).Visible = false
+	add(root, "d", 2, 2, 4)
+	add(root, "e", 3, 3, 5)
+	root.AddLayer(nested)
+	add(nested, "f", 1, 1, 6)
+	add(nested, "g", 2, 2, 7)
+	add(root, "h", 0, 0, 8)
+
+	// Only adjacent runs are merged, ended by other layers and groups
+	if merged := flatten(t, root); !slices.Equal(merged, []string{"a", "d", "f"}) {
+		t.Errorf("merged into %v", merged)
+	}
+	names := []string{"root", "a", "objects", "c", "hidden", "d", "nested", "f", "h"}
+	if got := layerNames(m); !slices.Equal(got, names) {
+		t.Errorf("layers are %v, want %v", got, names)
+	}
+	want := map[string]map[Point]TileID{
+		"a": {{0, 0}: 2},
+		"c": {{1, 1}: 3},
+		"d": {{2, 2}: 4, {3, 3}: 5},
+		"f": {{1, 1}: 6, {2, 2}: 7},
+		"h": {{0, 0}: 8, {1, 1}: 0},
+	}
+	for name, points := range want {
+		for p, gid := range points {
+			if got := tiles[name].GetGID(p.X, p.Y); got != gid {
+				t.Errorf("tile of %s at %v is %d, want %d", name, p, got, gid)
+			}
+		}
+	}
+
+	// Flattening again has nothing left to merge
+	if merged := flatten(t, root); len(merged) != 0 {
+		t.Errorf("merged into %v", merged)
+	}
+}
+
+func TestFlattenDrawn(t *testing.T) {
+	m := NewMap(Orthogonal, Size{Width: 4, Height: 4}, Size{Width: 16, Height: 16})
+	group := NewGroupLayer("group")
+	m.AddLayer(group)
+
+	layers := make([]*TileLayer, 8)
+	for i, name := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		layers[i] = filledLayer(m, name, i%4, i/4, TileID(i+1))
+		group.AddLayer(layers[i])
+	}
+	layers[1].Opacity, layers[2].Opacity = 0.5, 0.5
+	layers[3].TintColor = NewRGBA(255, 0, 0, 255)
+	layers[4].Offset, layers[5].Offset = Vec2{X: 4}, Vec2{X: 4}
+	layers[7].Parallax = Vec2{X: 2, Y: 2}
+
+	// Layers drawn differently from those before them start a new run
+	if merged := flatten(t, group); !slices.Equal(merged, []string{"b", "e"}) {
+		t.Errorf("merged into %v", merged)
+	}
+	if names := layerNames(group); !slices.Equal(names, []string{"a", "b", "d", "e", "g", "h"}) {
+		t.Errorf("layers are %v", names)
+	}
+	if got := layers[1].Tiles[:4]; !slices.Equal(got, []TileID{0, 2, 3, 0}) {
+		t.Errorf("tiles are %v", got)
+	}
+}
+
+func TestFlattenFilter(t *testing.T) {
+	m := NewMap(Orthogonal, Size{Width: 4, Height: 4}, Size{Width: 16, Height: 16})
+	group := NewGroupLayer("group")
+	m.AddLayer(group)
+	for i, name := range []string{"a", "keep", "b", "c"} {
+		group.AddLayer(filledLayer(m, name, i, 0, TileID(i+1)))
+	}
+
+	merged, err := group.Flatten(func(layer *TileLayer) bool { return layer.Name != "keep" })
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(merged) != 1 || merged[0].Name != "b" {
+		t.Fatalf("merged into %v", merged)
+	}
+	if names := layerNames(group); !slices.Equal(names, []string{"a", "keep", "b"}) {
+		t.Errorf("layers are %v", names)
+	}
+	if got := merged[0].Tiles[:4]; !slices.Equal(got, []TileID{0, 0, 3, 4}) {
+		t.Errorf("tiles are %v", got)
+	}
+
+	// Groups without tile layers have nothing to merge
+	if merged := flatten(t, NewGroupLayer("empty")); len(merged) != 0 {
+		t.Errorf("merged into %v", merged)
+	}
+}
+
+// vim: ts=4
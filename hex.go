@@ -0,0 +1,264 @@
+package tmx
+
+import "math"
+
+// Axial is the position of a tile on a hexagonal grid in axial coordinates, which unlike the
+// offset coordinates of the map, do not depend on the stagger axis or index.
+type Axial struct {
+	// Q is the position along the q-axis.
+	Q int
+	// R is the position along the r-axis.
+	R int
+}
+
+// Cube is the position of a tile on a hexagonal grid in cube coordinates, where the sum of Q, R
+// and S is always zero.
+type Cube struct {
+	// Q is the position along the q-axis.
+	Q int
+	// R is the position along the r-axis.
+	R int
+	// S is the position along the s-axis.
+	S int
+}
+
+// cubeDirections are the offsets to each of the six neighbors of a tile in cube coordinates.
+var cubeDirections = [6]Cube{
+	{Q: 1, R: -1, S: 0},
+	{Q: 1, R: 0, S: -1},
+	{Q: 0, R: 1, S: -1},
+	{Q: -1, R: 1, S: 0},
+	{Q: -1, R: 0, S: 1},
+	{Q: 0, R: -1, S: 1},
+}
+
+// Cube returns the position in cube coordinates.
+func (a Axial) Cube() Cube {
+	return Cube{Q: a.Q, R: a.R, S: -a.Q - a.R}
+}
+
+// Axial returns the position in axial coordinates.
+func (c Cube) Axial() Axial {
+	return Axial{Q: c.Q, R: c.R}
+}
+
+// Add returns the sum of two positions.
+func (c Cube) Add(other Cube) Cube {
+	return Cube{Q: c.Q + other.Q, R: c.R + other.R, S: c.S + other.S}
+}
+
+// Distance returns the number of steps between two positions, moving only between neighbors.
+func (c Cube) Distance(other Cube) int {
+	return max(abs(c.Q-other.Q), abs(c.R-other.R), abs(c.S-other.S))
+}
+
+// ToAxial converts the position of a tile on a hexagonal or staggered map to axial coordinates,
+// according to the StaggerAxis and StaggerIndex of the map. For other orientations, the position
+// is returned unchanged.
+func (m *Map) ToAxial(p Point) Axial {
+	if m.Orientation != Hexagonal && m.Orientation != Staggered {
+		return Axial{Q: p.X, R: p.Y}
+	}
+
+	even := m.StaggerIndex == StaggerEven
+	if m.StaggerAxis == StaggerX {
+		shift := (p.X - p.X&1) / 2
+		if even {
+			shift = (p.X + p.X&1) / 2
+		}
+		return Axial{Q: p.X, R: p.Y - shift}
+	}
+	shift := (p.Y - p.Y&1) / 2
+	if even {
+		shift = (p.Y + p.Y&1) / 2
+	}
+	return Axial{Q: p.X - shift, R: p.Y}
+}
+
+// FromAxial converts a position in axial coordinates to the position of a tile on the map. This
+// is the inverse of ToAxial.
+func (m *Map) FromAxial(a Axial) Point {
+	if m.Orientation != Hexagonal && m.Orientation != Staggered {
+		return Point{X: a.Q, Y: a.R}
+	}
+
+	even := m.StaggerIndex == StaggerEven
+	if m.StaggerAxis == StaggerX {
+		shift := (a.Q - a.Q&1) / 2
+		if even {
+			shift = (a.Q + a.Q&1) / 2
+		}
+		return Point{X: a.Q, Y: a.R + shift}
+	}
+	shift := (a.R - a.R&1) / 2
+	if even {
+		shift = (a.R + a.R&1) / 2
+	}
+	return Point{X: a.Q + shift, Y: a.R}
+}
+
+// ToCube converts the position of a tile on a hexagonal or staggered map to cube coordinates,
+// according to the StaggerAxis and StaggerIndex of the map.
+func (m *Map) ToCube(p Point) Cube {
+	return m.ToAxial(p).Cube()
+}
+
+// FromCube converts a position in cube coordinates to the position of a tile on the map. This is
+// the inverse of ToCube.
+func (m *Map) FromCube(c Cube) Point {
+	return m.FromAxial(c.Axial())
+}
+
+// Neighbors returns the tiles that share an edge with the given tile, which are the six
+// surrounding tiles on hexagonal maps, and four on all others. The result is not restricted to
+// the bounds of the map.
+func (m *Map) Neighbors(p Point) []Point {
+	if m.Orientation == Hexagonal {
+		c := m.ToCube(p)
+		points := make([]Point, len(cubeDirections))
+		for i, dir := range cubeDirections {
+			points[i] = m.FromCube(c.Add(dir))
+		}
+		return points
+	}
+
+	g := m.toGrid(p)
+	return []Point{
+		m.fromGrid(Point{X: g.X, Y: g.Y - 1}),
+		m.fromGrid(Point{X: g.X + 1, Y: g.Y}),
+		m.fromGrid(Point{X: g.X, Y: g.Y + 1}),
+		m.fromGrid(Point{X: g.X - 1, Y: g.Y}),
+	}
+}
+
+// Distance returns the number of steps between two tiles, moving only between neighbors.
+func (m *Map) Distance(a, b Point) int {
+	if m.Orientation == Hexagonal {
+		return m.ToCube(a).Distance(m.ToCube(b))
+	}
+	ga, gb := m.toGrid(a), m.toGrid(b)
+	return abs(ga.X-gb.X) + abs(ga.Y-gb.Y)
+}
+
+// Ring returns the tiles that are exactly the given distance from the center tile. A radius of
+// 0 returns only the center tile.
+func (m *Map) Ring(center Point, radius int) []Point {
+	if radius <= 0 {
+		return []Point{center}
+	}
+
+	if m.Orientation == Hexagonal {
+		points := make([]Point, 0, radius*6)
+		dir := cubeDirections[4]
+		c := m.ToCube(center).Add(Cube{Q: dir.Q * radius, R: dir.R * radius, S: dir.S * radius})
+		for _, dir := range cubeDirections {
+			for j := 0; j < radius; j++ {
+				points = append(points, m.FromCube(c))
+				c = c.Add(dir)
+			}
+		}
+		return points
+	}
+
+	g := m.toGrid(center)
+	points := make([]Point, 0, radius*4)
+	for i := 0; i < radius; i++ {
+		points = append(points,
+			m.fromGrid(Point{X: g.X + i, Y: g.Y - radius + i}),
+			m.fromGrid(Point{X: g.X + radius - i, Y: g.Y + i}),
+			m.fromGrid(Point{X: g.X - i, Y: g.Y + radius - i}),
+			m.fromGrid(Point{X: g.X - radius + i, Y: g.Y - i}),
+		)
+	}
+	return points
+}
+
+// Line returns the tiles along a straight line between two tiles, including both of them.
+func (m *Map) Line(a, b Point) []Point {
+	if m.Orientation == Hexagonal {
+		ca, cb := m.ToCube(a), m.ToCube(b)
+		n := ca.Distance(cb)
+		points := make([]Point, 0, n+1)
+		for i := 0; i <= n; i++ {
+			t := 0.0
+			if n > 0 {
+				t = float64(i) / float64(n)
+			}
+			// Nudge the endpoints to consistently break ties on the edges between tiles
+			q := lerp(float64(ca.Q)+1e-6, float64(cb.Q)+1e-6, t)
+			r := lerp(float64(ca.R)+2e-6, float64(cb.R)+2e-6, t)
+			s := lerp(float64(ca.S)-3e-6, float64(cb.S)-3e-6, t)
+			points = append(points, m.FromCube(cubeRound(q, r, s)))
+		}
+		return points
+	}
+
+	ga, gb := m.toGrid(a), m.toGrid(b)
+	n := max(abs(gb.X-ga.X), abs(gb.Y-ga.Y))
+	points := make([]Point, 0, n+1)
+	for i := 0; i <= n; i++ {
+		t := 0.0
+		if n > 0 {
+			t = float64(i) / float64(n)
+		}
+		x := math.Round(lerp(float64(ga.X), float64(gb.X), t))
+		y := math.Round(lerp(float64(ga.Y), float64(gb.Y), t))
+		points = append(points, m.fromGrid(Point{X: int(x), Y: int(y)}))
+	}
+	return points
+}
+
+// toGrid converts the position of a tile to coordinates on a square grid, where tiles sharing
+// an edge differ by one on a single axis. Tiles on orthogonal and isometric maps already are,
+// while those on staggered maps are converted from their offset coordinates.
+func (m *Map) toGrid(p Point) Point {
+	if m.Orientation != Staggered {
+		return p
+	}
+	a := m.ToAxial(p)
+	if m.StaggerAxis == StaggerX {
+		return Point{X: a.Q + a.R, Y: -a.R}
+	}
+	return Point{X: a.Q + a.R, Y: -a.Q}
+}
+
+// fromGrid converts coordinates on a square grid to the position of a tile. This is the inverse
+// of toGrid.
+func (m *Map) fromGrid(g Point) Point {
+	if m.Orientation != Staggered {
+		return g
+	}
+	if m.StaggerAxis == StaggerX {
+		return m.FromAxial(Axial{Q: g.X + g.Y, R: -g.Y})
+	}
+	return m.FromAxial(Axial{Q: -g.Y, R: g.X + g.Y})
+}
+
+// cubeRound rounds fractional cube coordinates to the nearest position.
+func cubeRound(q, r, s float64) Cube {
+	rq, rr, rs := math.Round(q), math.Round(r), math.Round(s)
+	dq, dr, ds := math.Abs(rq-q), math.Abs(rr-r), math.Abs(rs-s)
+	if dq > dr && dq > ds {
+		rq = -rr - rs
+	} else if dr > ds {
+		rr = -rq - rs
+	} else {
+		rs = -rq - rr
+	}
+	return Cube{Q: int(rq), R: int(rr), S: int(rs)}
+}
+
+// lerp linearly interpolates between two values.
+func lerp(a, b, t float64) float64 {
+	return a + (b-a)*t
+}
+
+// abs returns the absolute value of an integer.
+func abs(value int) int {
+	if value < 0 {
+		return -value
+	}
+	return value
+}
+
+// vim: ts=4
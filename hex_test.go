@@ -0,0 +1,201 @@
+package tmx
+
+import (
+	"cmp"
+	"math"
+	"slices"
+	"testing"
+)
+
+// gridMaps returns a map of each orientation with tiles of equal width and height, so that the
+// tiles sharing an edge with another are always the nearest to it.
+func gridMaps() []*Map {
+	maps := []*Map{
+		NewMap(Orthogonal, Size{Width: 8, Height: 8}, Size{Width: 32, Height: 32}),
+		NewMap(Isometric, Size{Width: 8, Height: 8}, Size{Width: 32, Height: 32}),
+	}
+	for _, axis := range []StaggerAxis{StaggerX, StaggerY} {
+		for _, index := range []StaggerIndex{StaggerEven, StaggerOdd} {
+			maps = append(maps,
+				staggeredMap(Staggered, axis, index, Size{Width: 32, Height: 32}, 0),
+				staggeredMap(Hexagonal, axis, index, Size{Width: 32, Height: 32}, 16),
+			)
+		}
+	}
+	return maps
+}
+
+// centerDistance returns the distance in pixels between the centers of two tiles.
+func centerDistance(m *Map, a, b Point) float64 {
+	pa, pb := m.TileToPixel(a), m.TileToPixel(b)
+	return math.Hypot(float64(pa.X-pb.X), float64(pa.Y-pb.Y))
+}
+
+// comparePoints orders points by row, then by column.
+func comparePoints(a, b Point) int {
+	if c := cmp.Compare(a.Y, b.Y); c != 0 {
+		return c
+	}
+	return cmp.Compare(a.X, b.X)
+}
+
+func TestAxial(t *testing.T) {
+	for _, m := range gridMaps() {
+		for y := -4; y < 4; y++ {
+			for x := -4; x < 4; x++ {
+				p := Point{X: x, Y: y}
+				c := m.ToCube(p)
+				if c.Q+c.R+c.S != 0 {
+					t.Errorf("%s: cube coordinates %v of %v do not sum to zero", mapName(m), c, p)
+				}
+				if got := m.FromCube(c); got != p {
+					t.Errorf("%s: %v converted to %v and back to %v", mapName(m), p, c, got)
+				}
+			}
+		}
+	}
+}
+
+func TestNeighbors(t *testing.T) {
+	// The neighbors of a tile are those with the nearest centers
+	for _, m := range gridMaps() {
+		count := 4
+		if m.Orientation == Hexagonal {
+			count = 6
+		}
+
+		for _, center := range []Point{{X: 0, Y: 0}, {X: 3, Y: 2}, {X: 2, Y: 5}, {X: -3, Y: -1}} {
+			var nearby []Point
+			for y := center.Y - 3; y <= center.Y+3; y++ {
+				for x := center.X - 3; x <= center.X+3; x++ {
+					if p := (Point{X: x, Y: y}); p != center {
+						nearby = append(nearby, p)
+					}
+				}
+			}
+			slices.SortStableFunc(nearby, func(a, b Point) int {
+				return cmp.Compare(centerDistance(m, center, a), centerDistance(m, center, b))
+			})
+			want := nearby[:count]
+			slices.SortFunc(want, comparePoints)
+
+			got := m.Neighbors(center)
+			slices.SortFunc(got, comparePoints)
+			if !slices.Equal(got, want) {
+				t.Errorf("%s: neighbors of %v are %v, want %v", mapName(m), center, got, want)
+			}
+			for _, p := range got {
+				if d := m.Distance(center, p); d != 1 {
+					t.Errorf("%s: neighbor %v is %d from %v", mapName(m), p, d, center)
+				}
+			}
+		}
+	}
+}
+
+// stepDistances returns the number of steps between neighbors from a tile to each tile up to
+// the given number of steps away.
+func stepDistances(m *Map, start Point, steps int) map[Point]int {
+	distances := map[Point]int{start: 0}
+	queue := []Point{start}
+	for len(queue) > 0 {
+		p := queue[0]
+		queue = queue[1:]
+		if distances[p] == steps {
+			continue
+		}
+		for _, next := range m.Neighbors(p) {
+			if _, ok := distances[next]; !ok {
+				distances[next] = distances[p] + 1
+				queue = append(queue, next)
+			}
+		}
+	}
+	return distances
+}
+
+func TestDistanceAndRing(t *testing.T) {
+	const steps = 5
+	for _, m := range gridMaps() {
+		for _, center := range []Point{{X: 0, Y: 0}, {X: 3, Y: 4}, {X: -2, Y: 1}} {
+			distances := stepDistances(m, center, steps)
+			rings := make([][]Point, steps+1)
+			for p, d := range distances {
+				if got := m.Distance(center, p); got != d {
+					t.Errorf("%s: distance from %v to %v is %d, want %d", mapName(m), center, p, got, d)
+				}
+				if got := m.Distance(p, center); got != d {
+					t.Errorf("%s: distance from %v to %v is %d, want %d", mapName(m), p, center, got, d)
+				}
+				rings[d] = append(rings[d], p)
+			}
+
+			for radius, want := range rings {
+				got := m.Ring(center, radius)
+				slices.SortFunc(got, comparePoints)
+				slices.SortFunc(want, comparePoints)
+				if !slices.Equal(got, want) {
+					t.Errorf("%s: ring %d around %v is %v, want %v", mapName(m), radius, center, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestLine(t *testing.T) {
+	pairs := [][2]Point{
+		{{X: 0, Y: 0}, {X: 0, Y: 0}},
+		{{X: 0, Y: 0}, {X: 5, Y: 0}},
+		{{X: 0, Y: 0}, {X: 0, Y: 6}},
+		{{X: 1, Y: 2}, {X: 6, Y: 7}},
+		{{X: 4, Y: -3}, {X: -2, Y: 3}},
+		{{X: 3, Y: 3}, {X: -4, Y: 1}},
+	}
+
+	// Lines on hexagonal maps step between neighbors, while those on other maps can also step
+	// diagonally, as with lines drawn on a square grid
+	step := func(m *Map, a, b Point) int {
+		if m.Orientation == Hexagonal {
+			return m.Distance(a, b)
+		}
+		ga, gb := m.toGrid(a), m.toGrid(b)
+		return max(abs(ga.X-gb.X), abs(ga.Y-gb.Y))
+	}
+
+	for _, m := range gridMaps() {
+		for _, pair := range pairs {
+			a, b := pair[0], pair[1]
+			line := m.Line(a, b)
+			if len(line) != step(m, a, b)+1 || line[0] != a || line[len(line)-1] != b {
+				t.Errorf("%s: line from %v to %v is %v", mapName(m), a, b, line)
+				continue
+			}
+			for i := 1; i < len(line); i++ {
+				if step(m, line[i-1], line[i]) != 1 {
+					t.Errorf("%s: line from %v to %v is not continuous: %v", mapName(m), a, b, line)
+					break
+				}
+			}
+		}
+	}
+}
+
+func TestCubeDistance(t *testing.T) {
+	tests := []struct {
+		a, b Axial
+		want int
+	}{
+		{Axial{}, Axial{}, 0},
+		{Axial{Q: 1}, Axial{}, 1},
+		{Axial{Q: 3, R: -3}, Axial{}, 3},
+		{Axial{Q: 2, R: 2}, Axial{}, 4},
+		{Axial{Q: -1, R: 4}, Axial{Q: 2, R: -2}, 6},
+	}
+	for _, test := range tests {
+		if got := test.a.Cube().Distance(test.b.Cube()); got != test.want {
+			t.Errorf("distance from %v to %v is %d, want %d", test.a, test.b, got, test.want)
+		}
+	}
+}
+
+// vim: ts=4
@@ -0,0 +1,457 @@
+package tmx
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// ErrInTransaction is returned when commands are undone or redone while a transaction is open.
+var ErrInTransaction = errors.New("cannot undo or redo while a transaction is open")
+
+// Command describes a reversible change to a map.
+type Command interface {
+	// Do applies the change.
+	Do() error
+	// Undo reverts the change after it has been applied.
+	Undo() error
+}
+
+// History maintains a record of commands that have been applied, allowing them to be undone
+// and redone.
+type History struct {
+	// Limit is the maximum number of commands that are retained for undoing, where the oldest
+	// are discarded first. A value of 0 retains all of them.
+	Limit int
+
+	undo  []Command
+	redo  []Command
+	tx    CommandGroup
+	depth int
+}
+
+// Do applies a command and records it in the history, discarding any commands that could be
+// redone. When a transaction is open, the command is added to the transaction instead. Commands
+// that fail are not recorded.
+func (h *History) Do(cmd Command) error {
+	if err := cmd.Do(); err != nil {
+		return err
+	}
+	if h.depth > 0 {
+		h.tx = append(h.tx, cmd)
+	} else {
+		h.push(cmd)
+	}
+	return nil
+}
+
+// Undo reverts the most recent command. Has no effect when there is nothing to undo.
+func (h *History) Undo() error {
+	if h.depth > 0 {
+		return ErrInTransaction
+	}
+	if len(h.undo) == 0 {
+		return nil
+	}
+
+	cmd := h.undo[len(h.undo)-1]
+	if err := cmd.Undo(); err != nil {
+		return err
+	}
+	h.undo = h.undo[:len(h.undo)-1]
+	h.redo = append(h.redo, cmd)
+	return nil
+}
+
+// Redo applies the most recently undone command again. Has no effect when there is nothing to
+// redo.
+func (h *History) Redo() error {
+	if h.depth > 0 {
+		return ErrInTransaction
+	}
+	if len(h.redo) == 0 {
+		return nil
+	}
+
+	cmd := h.redo[len(h.redo)-1]
+	if err := cmd.Do(); err != nil {
+		return err
+	}
+	h.redo = h.redo[:len(h.redo)-1]
+	h.undo = append(h.undo, cmd)
+	return nil
+}
+
+// CanUndo tests whether there is a command that can be undone.
+func (h *History) CanUndo() bool {
+	return h.depth == 0 && len(h.undo) > 0
+}
+
+// CanRedo tests whether there is a command that can be redone.
+func (h *History) CanRedo() bool {
+	return h.depth == 0 && len(h.redo) > 0
+}
+
+// Begin opens a transaction, where all commands applied until it is committed are grouped
+// together and undone as one. Transactions can be nested, in which case the commands are only
+// recorded when the outermost transaction is committed.
+func (h *History) Begin() {
+	h.depth++
+}
+
+// Commit closes the current transaction. When it is the outermost transaction, its commands are
+// recorded in the history as a single command.
+func (h *History) Commit() {
+	if h.depth == 0 {
+		return
+	}
+	if h.depth--; h.depth == 0 && len(h.tx) > 0 {
+		h.push(h.tx)
+		h.tx = nil
+	}
+}
+
+// Rollback closes all open transactions, reverting every command that was applied within them.
+//
+// When a command fails to be undone, the transactions remain open with the commands that have
+// not been undone, so that Rollback can be called again.
+func (h *History) Rollback() error {
+	for len(h.tx) > 0 {
+		if err := h.tx[len(h.tx)-1].Undo(); err != nil {
+			return err
+		}
+		h.tx = h.tx[:len(h.tx)-1]
+	}
+	h.depth = 0
+	h.tx = nil
+	return nil
+}
+
+// Clear removes all commands from the history.
+func (h *History) Clear() {
+	h.undo = nil
+	h.redo = nil
+}
+
+// push records an applied command for undoing.
+func (h *History) push(cmd Command) {
+	h.undo = append(h.undo, cmd)
+	h.redo = nil
+	if h.Limit > 0 && len(h.undo) > h.Limit {
+		h.undo = slices.Delete(h.undo, 0, len(h.undo)-h.Limit)
+	}
+}
+
+// CommandGroup is a command composed of other commands, which are applied in order and undone
+// in reverse order.
+type CommandGroup []Command
+
+// Do implements the Command interface. When a command fails, those already applied are undone.
+func (g CommandGroup) Do() error {
+	for i, cmd := range g {
+		if err := cmd.Do(); err != nil {
+			return errors.Join(err, g[:i].Undo())
+		}
+	}
+	return nil
+}
+
+// Undo implements the Command interface.
+func (g CommandGroup) Undo() error {
+	for i := len(g) - 1; i >= 0; i-- {
+		if err := g[i].Undo(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FuncCommand is a command that calls user-defined functions to apply and revert a change.
+type FuncCommand struct {
+	// DoFunc is called to apply the change.
+	DoFunc func() error
+	// UndoFunc is called to revert the change.
+	UndoFunc func() error
+}
+
+// Do implements the Command interface.
+func (c *FuncCommand) Do() error {
+	return c.DoFunc()
+}
+
+// Undo implements the Command interface.
+func (c *FuncCommand) Undo() error {
+	return c.UndoFunc()
+}
+
+// SetTileCommand is a command that sets the global tile ID at a position of a tile layer.
+type SetTileCommand struct {
+	// Layer is the tile layer that is changed.
+	Layer *TileLayer
+	// X is the position of the tile on the x-axis in tile units.
+	X int
+	// Y is the position of the tile on the y-axis in tile units.
+	Y int
+	// GID is the global tile ID to set, which may include flip/rotate bits.
+	GID TileID
+
+	prev      TileID
+	allocated bool
+}
+
+// Do implements the Command interface.
+func (c *SetTileCommand) Do() error {
+	count := len(c.Layer.Chunks)
+	prev := c.Layer.GetGID(c.X, c.Y)
+	if err := c.Layer.SetGID(c.X, c.Y, c.GID); err != nil {
+		return err
+	}
+	c.prev, c.allocated = prev, len(c.Layer.Chunks) > count
+	return nil
+}
+
+// Undo implements the Command interface. A chunk that was allocated for the tile is removed
+// again when it is empty.
+func (c *SetTileCommand) Undo() error {
+	if err := c.Layer.SetGID(c.X, c.Y, c.prev); err != nil {
+		return err
+	}
+	if c.allocated {
+		c.Layer.freeChunk(c.X, c.Y)
+		c.allocated = false
+	}
+	return nil
+}
+
+// MoveObjectCommand is a command that changes the location of an object.
+type MoveObjectCommand struct {
+	// Layer is the object layer that contains the object.
+	Layer *ObjectLayer
+	// ID is the ID of the object to move.
+	ID int
+	// Location is the new location of the object in pixel units.
+	Location Vec2
+
+	prev Vec2
+}
+
+// Do implements the Command interface.
+func (c *MoveObjectCommand) Do() error {
+	i := c.Layer.objectIndex(c.ID)
+	if i < 0 {
+		return ErrObjectNotFound
+	}
+	c.prev = c.Layer.Objects[i].Location
+	c.Layer.Objects[i].Location = c.Location
+	c.Layer.objectChanged(i)
+	return nil
+}
+
+// Undo implements the Command interface.
+func (c *MoveObjectCommand) Undo() error {
+	i := c.Layer.objectIndex(c.ID)
+	if i < 0 {
+		return ErrObjectNotFound
+	}
+	c.Layer.Objects[i].Location = c.prev
+	c.Layer.objectChanged(i)
+	return nil
+}
+
+// SetPropertyCommand is a command that sets a property, replacing any existing property with
+// the same name.
+type SetPropertyCommand struct {
+	// Properties is the set of properties that is changed, which is created when it is nil.
+	Properties *Properties
+	// Property is the property to set.
+	Property Property
+
+	prev   Property
+	exists bool
+}
+
+// Do implements the Command interface.
+func (c *SetPropertyCommand) Do() error {
+	if *c.Properties == nil {
+		*c.Properties = make(Properties)
+	}
+	c.prev, c.exists = (*c.Properties)[c.Property.Name]
+	(*c.Properties)[c.Property.Name] = c.Property
+	return nil
+}
+
+// Undo implements the Command interface.
+func (c *SetPropertyCommand) Undo() error {
+	if c.exists {
+		(*c.Properties)[c.Property.Name] = c.prev
+	} else {
+		delete(*c.Properties, c.Property.Name)
+	}
+	return nil
+}
+
+// RemovePropertyCommand is a command that removes a property.
+type RemovePropertyCommand struct {
+	// Properties is the set of properties that is changed.
+	Properties *Properties
+	// Name is the name of the property to remove.
+	Name string
+
+	prev   Property
+	exists bool
+}
+
+// Do implements the Command interface.
+func (c *RemovePropertyCommand) Do() error {
+	c.prev, c.exists = (*c.Properties)[c.Name]
+	delete(*c.Properties, c.Name)
+	return nil
+}
+
+// Undo implements the Command interface.
+func (c *RemovePropertyCommand) Undo() error {
+	if c.exists {
+		if *c.Properties == nil {
+			*c.Properties = make(Properties)
+		}
+		(*c.Properties)[c.Name] = c.prev
+	}
+	return nil
+}
+
+// MoveLayerCommand is a command that moves a layer to an index within a container, which may
+// be different from the one it is currently within.
+//
+// Layers moved to a different map are assigned new layer and object IDs, which are restored when
+// the command is undone.
+type MoveLayerCommand struct {
+	// Layer is the layer to move.
+	Layer Layer
+	// Container is the map or group layer the layer is moved into.
+	Container Container
+	// Index is the position within the container the layer is moved to, where 0 is the head.
+	Index int
+
+	prev      Container
+	prevIndex int
+	ids       []int
+	counters  []mapCounters
+}
+
+// mapCounters records the next layer and object IDs of a map.
+type mapCounters struct {
+	tilemap    *Map
+	nextLayer  int
+	nextObject int
+}
+
+// Do implements the Command interface.
+func (c *MoveLayerCommand) Do() error {
+	prev, prevIndex := c.Layer.Container(), layerIndex(c.Layer)
+	ids := layerIDs(c.Layer)
+	var counters []mapCounters
+	for _, m := range []*Map{c.Layer.Map(), containerMap(c.Container)} {
+		if m != nil && (len(counters) == 0 || counters[0].tilemap != m) {
+			counters = append(counters, mapCounters{m, m.NextLayerId, m.NextObjectId})
+		}
+	}
+
+	if err := placeLayer(c.Container, c.Layer, c.Index); err != nil {
+		return err
+	}
+	c.prev, c.prevIndex, c.ids, c.counters = prev, prevIndex, ids, counters
+	return nil
+}
+
+// Undo implements the Command interface.
+func (c *MoveLayerCommand) Undo() error {
+	var err error
+	if c.prev == nil {
+		err = removeLayer(c.Container, c.Layer)
+	} else {
+		err = placeLayer(c.prev, c.Layer, c.prevIndex)
+	}
+	if err != nil {
+		return err
+	}
+
+	restoreLayerIDs(c.Layer, c.ids)
+	for _, counters := range c.counters {
+		counters.tilemap.NextLayerId = counters.nextLayer
+		counters.tilemap.NextObjectId = counters.nextObject
+	}
+	return nil
+}
+
+// placeLayer moves a layer to the given index within a container. The index is validated before
+// the layer is moved, so that the layer is left unchanged when it is out of range.
+func placeLayer(owner Container, layer Layer, index int) error {
+	count := owner.Len()
+	if layer.Container() != owner {
+		count++
+	}
+	if index < 0 || index >= count {
+		return fmt.Errorf("layer index %d out of range", index)
+	}
+
+	if layer.Container() != owner {
+		if err := insertLayer(owner, layer, nil); err != nil {
+			return err
+		}
+	}
+	return moveLayer(owner, layer, index)
+}
+
+// eachLayerID calls fn with a pointer to the ID of a layer, each of its descendants, and each of
+// their objects, in a consistent order.
+func eachLayerID(layer Layer, fn func(id *int)) {
+	visit := func(layer Layer) {
+		fn(&layer.base().ID)
+		if objects, ok := layer.(*ObjectLayer); ok {
+			for i := range objects.Objects {
+				fn(&objects.Objects[i].ID)
+			}
+		}
+	}
+	visit(layer)
+	if group, ok := layer.(*GroupLayer); ok {
+		walkLayers(group, visit)
+	}
+}
+
+// layerIDs returns the IDs of a layer, its descendants, and their objects.
+func layerIDs(layer Layer) []int {
+	var ids []int
+	eachLayerID(layer, func(id *int) { ids = append(ids, *id) })
+	return ids
+}
+
+// restoreLayerIDs sets the IDs of a layer, its descendants, and their objects to those returned
+// by layerIDs.
+func restoreLayerIDs(layer Layer, ids []int) {
+	i := 0
+	eachLayerID(layer, func(id *int) {
+		if i < len(ids) {
+			*id = ids[i]
+			i++
+		}
+	})
+}
+
+// layerIndex returns the index of a layer within its container, or -1 when it is not within one.
+func layerIndex(layer Layer) int {
+	owner := layer.Container()
+	if owner == nil {
+		return -1
+	}
+	i := 0
+	for child := owner.Head(); child != nil; child = child.Next() {
+		if child == layer {
+			return i
+		}
+		i++
+	}
+	return -1
+}
+
+// vim: ts=4
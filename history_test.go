@@ -0,0 +1,336 @@
+package tmx
+
+import (
+	"errors"
+	"reflect"
+	"slices"
+	"testing"
+)
+
+func TestHistoryUndoRedo(t *testing.T) {
+	m := NewMap(Orthogonal, Size{Width: 4, Height: 4}, Size{Width: 16, Height: 16})
+	layer := NewTileLayer("tiles", m.Size)
+	m.AddLayer(layer)
+
+	var history History
+	for _, gid := range []TileID{1, 2, 3} {
+		if err := history.Do(&SetTileCommand{Layer: layer, X: 1, Y: 1, GID: gid}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	check := func(want TileID, undo, redo bool) {
+		t.Helper()
+		if got := layer.GetGID(1, 1); got != want {
+			t.Errorf("tile is %d, want %d", got, want)
+		}
+		if history.CanUndo() != undo || history.CanRedo() != redo {
+			t.Errorf("can undo and redo are %v %v, want %v %v", history.CanUndo(), history.CanRedo(), undo, redo)
+		}
+	}
+
+	check(3, true, false)
+	history.Undo()
+	check(2, true, true)
+	history.Undo()
+	history.Undo()
+	check(0, false, true)
+	history.Undo()
+	check(0, false, true)
+	history.Redo()
+	check(1, true, true)
+
+	// Applying a command discards those that could be redone
+	history.Do(&SetTileCommand{Layer: layer, X: 1, Y: 1, GID: 9})
+	check(9, true, false)
+	history.Undo()
+	check(1, true, true)
+}
+
+func TestHistoryFailedCommand(t *testing.T) {
+	layer := NewObjectLayer("objects")
+	var history History
+	if err := history.Do(&MoveObjectCommand{Layer: layer, ID: 5}); !errors.Is(err, ErrObjectNotFound) {
+		t.Errorf("moving a missing object returned %v", err)
+	}
+	if history.CanUndo() {
+		t.Error("failed command was recorded")
+	}
+}
+
+func TestHistoryLimit(t *testing.T) {
+	layer := NewTileLayer("tiles", Size{Width: 4, Height: 1})
+	history := History{Limit: 2}
+	for x := 0; x < 4; x++ {
+		history.Do(&SetTileCommand{Layer: layer, X: x, GID: 1})
+	}
+	for history.CanUndo() {
+		history.Undo()
+	}
+	if want := []TileID{1, 1, 0, 0}; !slices.Equal(layer.Tiles, want) {
+		t.Errorf("tiles are %v, want %v", layer.Tiles, want)
+	}
+}
+
+func TestHistoryTransaction(t *testing.T) {
+	m := NewMap(Orthogonal, Size{Width: 4, Height: 4}, Size{Width: 16, Height: 16})
+	tiles := NewTileLayer("tiles", m.Size)
+	objects := NewObjectLayer("objects")
+	m.AddLayer(tiles)
+	m.AddLayer(objects)
+	obj := objects.AddObject(Object{Location: Vec2{X: 1, Y: 2}})
+
+	var history History
+	history.Begin()
+	history.Do(&SetTileCommand{Layer: tiles, X: 2, Y: 3, GID: 7})
+	history.Begin()
+	history.Do(&MoveObjectCommand{Layer: objects, ID: obj.ID, Location: Vec2{X: 32, Y: 48}})
+	history.Commit()
+	if history.CanUndo() {
+		t.Error("commands can be undone within a transaction")
+	}
+	if err := history.Undo(); !errors.Is(err, ErrInTransaction) {
+		t.Errorf("undoing within a transaction returned %v", err)
+	}
+	history.Commit()
+
+	if err := history.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	if tiles.GetGID(2, 3) != 0 || obj.Location != (Vec2{X: 1, Y: 2}) || history.CanUndo() {
+		t.Errorf("transaction was not undone as one: tile %d, object at %v", tiles.GetGID(2, 3), obj.Location)
+	}
+	if err := history.Redo(); err != nil {
+		t.Fatal(err)
+	}
+	if tiles.GetGID(2, 3) != 7 || obj.Location != (Vec2{X: 32, Y: 48}) {
+		t.Errorf("transaction was not redone: tile %d, object at %v", tiles.GetGID(2, 3), obj.Location)
+	}
+
+	// Rolling back reverts the commands without recording them
+	history.Begin()
+	history.Do(&SetTileCommand{Layer: tiles, X: 0, Y: 0, GID: 4})
+	history.Do(&SetPropertyCommand{Properties: &m.Properties, Property: Property{Name: "a", Type: TypeInt, Value: 1}})
+	if err := history.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := m.Properties["a"]; ok || tiles.GetGID(0, 0) != 0 {
+		t.Error("transaction was not rolled back")
+	}
+	history.Undo()
+	if tiles.GetGID(2, 3) != 0 {
+		t.Error("rolled back transaction was recorded")
+	}
+}
+
+func TestHistoryRollbackFailed(t *testing.T) {
+	layer := NewTileLayer("tiles", Size{Width: 4, Height: 1})
+	var undone []int
+	fail := true
+	command := func(n int) Command {
+		return &FuncCommand{
+			DoFunc: func() error { return nil },
+			UndoFunc: func() error {
+				if n == 1 && fail {
+					return ErrObjectNotFound
+				}
+				undone = append(undone, n)
+				return nil
+			},
+		}
+	}
+
+	var history History
+	history.Begin()
+	history.Do(command(0))
+	history.Do(command(1))
+	history.Do(&SetTileCommand{Layer: layer, X: 3, GID: 5})
+	if err := history.Rollback(); !errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("rollback returned %v", err)
+	}
+	if layer.Tiles[3] != 0 || len(undone) != 0 {
+		t.Errorf("commands before the failure were not undone: tiles %v, undone %v", layer.Tiles, undone)
+	}
+
+	// The commands that were not undone remain in the transaction
+	fail = false
+	if err := history.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(undone, []int{1, 0}) {
+		t.Errorf("undone commands are %v, want [1 0]", undone)
+	}
+	if history.CanUndo() || history.Undo() != nil {
+		t.Error("rolled back transaction was recorded")
+	}
+}
+
+func TestSetTileCommandChunk(t *testing.T) {
+	_, layer := tileMap(t, true)
+	layer.SetGID(0, 0, 1)
+
+	var history History
+	history.Do(&SetTileCommand{Layer: layer, X: -20, Y: 40, GID: 2})
+	history.Do(&SetTileCommand{Layer: layer, X: 5, Y: 5, GID: 3})
+	if len(layer.Chunks) != 2 {
+		t.Fatalf("%d chunks, want 2", len(layer.Chunks))
+	}
+
+	// The chunk allocated for a tile is removed when undone, but not existing chunks
+	history.Undo()
+	history.Undo()
+	if len(layer.Chunks) != 1 || layer.GetGID(0, 0) != 1 || layer.GetGID(5, 5) != 0 {
+		t.Errorf("chunks are %v", layer.Chunks)
+	}
+	if chunk, _, _ := layer.ChunkAt(-20, 40); chunk != nil {
+		t.Errorf("allocated chunk %v remains", chunk.Rect)
+	}
+
+	// The chunk is allocated again when redone
+	history.Redo()
+	if layer.GetGID(-20, 40) != 2 || len(layer.Chunks) != 2 {
+		t.Errorf("tile was not redone: chunks are %v", layer.Chunks)
+	}
+}
+
+func TestPropertyCommands(t *testing.T) {
+	props := Properties{"a": {Name: "a", Type: TypeInt, Value: 1}}
+	var history History
+	history.Do(&SetPropertyCommand{Properties: &props, Property: Property{Name: "a", Type: TypeInt, Value: 2}})
+	history.Do(&SetPropertyCommand{Properties: &props, Property: Property{Name: "b", Type: TypeBool, Value: true}})
+	history.Do(&RemovePropertyCommand{Properties: &props, Name: "a"})
+	if _, ok := props["a"]; ok || len(props) != 1 {
+		t.Errorf("properties are %v", props)
+	}
+
+	for history.CanUndo() {
+		history.Undo()
+	}
+	if want := (Properties{"a": {Name: "a", Type: TypeInt, Value: 1}}); !reflect.DeepEqual(props, want) {
+		t.Errorf("properties are %v, want %v", props, want)
+	}
+}
+
+func TestMoveLayerCommand(t *testing.T) {
+	m := NewMap(Orthogonal, Size{Width: 4, Height: 4}, Size{Width: 16, Height: 16})
+	for _, name := range []string{"a", "b", "c"} {
+		m.AddLayer(NewTileLayer(name, m.Size))
+	}
+	layer := m.Head()
+
+	var history History
+	if err := history.Do(&MoveLayerCommand{Layer: layer, Container: m, Index: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if names := layerNames(m); !slices.Equal(names, []string{"b", "c", "a"}) {
+		t.Errorf("layers are %v after moving", names)
+	}
+
+	// Invalid indices leave the layer unchanged
+	for _, index := range []int{-1, 3} {
+		if err := history.Do(&MoveLayerCommand{Layer: layer, Container: m, Index: index}); err == nil {
+			t.Errorf("moving to %d did not fail", index)
+		}
+		if names := layerNames(m); !slices.Equal(names, []string{"b", "c", "a"}) {
+			t.Errorf("layers are %v after failing to move to %d", names, index)
+		}
+	}
+	group := NewGroupLayer("group")
+	if err := history.Do(&MoveLayerCommand{Layer: group, Container: m, Index: 4}); err == nil {
+		t.Error("adding at an invalid index did not fail")
+	}
+	if group.Container() != nil || m.Len() != 3 {
+		t.Error("layer was added at an invalid index")
+	}
+	history.Undo()
+	if names := layerNames(m); !slices.Equal(names, []string{"a", "b", "c"}) {
+		t.Errorf("layers are %v after undoing", names)
+	}
+}
+
+func TestMoveLayerCommandGroup(t *testing.T) {
+	m := NewMap(Orthogonal, Size{Width: 4, Height: 4}, Size{Width: 16, Height: 16})
+	group := NewGroupLayer("group")
+	layer := NewTileLayer("layer", m.Size)
+	m.AddLayer(NewTileLayer("first", m.Size))
+	m.AddLayer(layer)
+	m.AddLayer(group)
+	group.AddLayer(NewTileLayer("child", m.Size))
+
+	var history History
+	if err := history.Do(&MoveLayerCommand{Layer: layer, Container: group, Index: 0}); err != nil {
+		t.Fatal(err)
+	}
+	if names := layerNames(m); layer.Container() != Container(group) ||
+		!slices.Equal(names, []string{"first", "group", "layer", "child"}) {
+		t.Errorf("layers are %v after moving", names)
+	}
+
+	// The layer returns to its previous position
+	history.Undo()
+	if names := layerNames(m); layer.Container() != Container(m) ||
+		!slices.Equal(names, []string{"first", "layer", "group", "child"}) {
+		t.Errorf("layers are %v after undoing", names)
+	}
+
+	// Layers that were not within a container are removed when undone
+	added := NewObjectLayer("added")
+	history.Do(&MoveLayerCommand{Layer: added, Container: group, Index: 1})
+	history.Undo()
+	if added.Container() != nil || group.Len() != 1 {
+		t.Errorf("added layer was not removed")
+	}
+}
+
+func TestMoveLayerCommandMaps(t *testing.T) {
+	// Layers moved to another map are given new IDs, which are restored when undone
+	src := NewMap(Orthogonal, Size{Width: 4, Height: 4}, Size{Width: 16, Height: 16})
+	dst := NewMap(Orthogonal, Size{Width: 4, Height: 4}, Size{Width: 16, Height: 16})
+	dst.AddLayer(NewTileLayer("other", dst.Size))
+	dst.AddLayer(NewObjectLayer("other objects"))
+	dst.ObjectLayers[0].AddObject(Object{})
+
+	group := NewGroupLayer("group")
+	objects := NewObjectLayer("objects")
+	src.AddLayer(NewTileLayer("first", src.Size))
+	src.AddLayer(group)
+	group.AddLayer(objects)
+	objects.AddObject(Object{})
+	objects.AddObject(Object{})
+
+	ids := layerIDs(group)
+	srcCounters := []int{src.NextLayerId, src.NextObjectId}
+	dstCounters := []int{dst.NextLayerId, dst.NextObjectId}
+
+	var history History
+	if err := history.Do(&MoveLayerCommand{Layer: group, Container: dst, Index: 1}); err != nil {
+		t.Fatal(err)
+	}
+	if group.Map() != dst || layerIndex(group) != 1 {
+		t.Fatalf("group was not moved to the other map")
+	}
+	if moved := layerIDs(group); slices.Equal(moved, ids) {
+		t.Errorf("IDs of moved layers are unchanged: %v", moved)
+	}
+	if obj, _ := dst.FindObjectByID(objects.Objects[0].ID); obj != &objects.Objects[0] {
+		t.Error("moved object cannot be found by its new ID")
+	}
+
+	if err := history.Undo(); err != nil {
+		t.Fatal(err)
+	}
+	if group.Map() != src || layerIndex(group) != 1 {
+		t.Errorf("group was not moved back")
+	}
+	if restored := layerIDs(group); !slices.Equal(restored, ids) {
+		t.Errorf("IDs are %v, want %v", restored, ids)
+	}
+	if got := []int{src.NextLayerId, src.NextObjectId}; !slices.Equal(got, srcCounters) {
+		t.Errorf("next IDs of source map are %v, want %v", got, srcCounters)
+	}
+	if got := []int{dst.NextLayerId, dst.NextObjectId}; !slices.Equal(got, dstCounters) {
+		t.Errorf("next IDs of destination map are %v, want %v", got, dstCounters)
+	}
+}
+
+// vim: ts=4
@@ -3,6 +3,7 @@ package tmx
 import (
 	"encoding/xml"
 	"image"
+	"slices"
 	"strconv"
 )
 
@@ -45,6 +46,45 @@ type Image struct {
 	// UserImage provides a field to that can be used to store a decoded image with the Image
 	// instance.
 	UserImage image.Image
+	// Extra contains unrecognized data that was retained while reading, see PreserveUnknown.
+	Extra Extra
+}
+
+// MarshalXML implements the xml.Marshaler interface.
+func (img *Image) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return img.encodeXML(nil, e, start)
+}
+
+// encodeXML implements the xmlEncoding interface.
+func (img *Image) encodeXML(enc *encoder, e *xml.Encoder, start xml.StartElement) error {
+	start = xml.StartElement{Name: xml.Name{Local: "image"}}
+	if img.Format != "" {
+		start.Attr = append(start.Attr, xmlStr("format", img.Format))
+	}
+	if img.Source != "" {
+		start.Attr = append(start.Attr, xmlStr("source", enc.refPath(img.Source)))
+	}
+	if img.Transparency != 0 {
+		// Written without the leading '#', as is done by Tiled
+		start.Attr = append(start.Attr, xmlStr("trans", img.Transparency.hex()[1:]))
+	}
+	if img.Width > 0 && img.Height > 0 {
+		start.Attr = append(start.Attr, xmlInt("width", img.Width), xmlInt("height", img.Height))
+	}
+	start.Attr = img.Extra.xmlAttrs(start.Attr)
+
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := img.Extra.marshalXML(e); err != nil {
+		return err
+	}
+	if img.Data != nil {
+		if err := e.EncodeElement(img.Data, start); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
 }
 
 // UnmarshalXML implements the xml.Unmarshaler interface.
@@ -75,7 +115,7 @@ func (img *Image) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 			}
 		case "id": // Ignore, deprecated legacy Java filth
 		default:
-			logAttr(attr.Name.Local, start.Name.Local)
+			img.Extra.attr(attr, start.Name.Local)
 		}
 	}
 
@@ -87,7 +127,9 @@ func (img *Image) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 
 		if child, ok := token.(xml.StartElement); ok {
 			if child.Name.Local != "data" {
-				logElem(child.Name.Local, start.Name.Local)
+				if err := img.Extra.elem(d, child, start.Name.Local); err != nil {
+					return err
+				}
 			} else {
 				var data Data
 				if err := data.UnmarshalXML(d, child); err != nil {
@@ -107,4 +149,16 @@ func (img *Image) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	return nil
 }
 
+// Clone creates a deep copy of the Image. The UserID and UserImage fields are copied as-is.
+func (img *Image) Clone() *Image {
+	dup := *img
+	if img.Data != nil {
+		data := *img.Data
+		data.Payload = slices.Clone(img.Data.Payload)
+		dup.Data = &data
+	}
+	dup.Extra = img.Extra.clone()
+	return &dup
+}
+
 // vim: ts=4
@@ -1,6 +1,7 @@
 package tmx
 
 import (
+	"encoding/json"
 	"encoding/xml"
 	"strconv"
 )
@@ -16,6 +17,76 @@ type ImageLayer struct {
 	Image *Image
 }
 
+// NewImageLayer creates a new image layer with the given name and image, which may be nil. The
+// layer is assigned an ID when it is added to a map.
+func NewImageLayer(name string, image *Image) *ImageLayer {
+	var layer ImageLayer
+	layer.initDefaults(LayerImage)
+	layer.Name = name
+	layer.Image = image
+	return &layer
+}
+
+// MarshalXML implements the xml.Marshaler interface.
+func (layer *ImageLayer) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return layer.encodeXML(nil, e, start)
+}
+
+// encodeXML implements the xmlEncoding interface.
+func (layer *ImageLayer) encodeXML(enc *encoder, e *xml.Encoder, start xml.StartElement) error {
+	start = xml.StartElement{Name: xml.Name{Local: "imagelayer"}, Attr: layer.xmlAttrs()}
+	if layer.RepeatX {
+		start.Attr = append(start.Attr, xmlBool("repeatx", true))
+	}
+	if layer.RepeatY {
+		start.Attr = append(start.Attr, xmlBool("repeaty", true))
+	}
+
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := layer.Extra.marshalXML(e); err != nil {
+		return err
+	}
+	if err := e.EncodeElement(enc.xml(layer.Properties.owned(&layer.Extra)), start); err != nil {
+		return err
+	}
+	if layer.Image != nil {
+		if err := e.EncodeElement(enc.xml(layer.Image), start); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (layer *ImageLayer) MarshalJSON() ([]byte, error) {
+	return layer.encodeJSON(nil)
+}
+
+// encodeJSON implements the jsonEncoding interface.
+func (layer *ImageLayer) encodeJSON(enc *encoder) ([]byte, error) {
+	attrs := layer.jsonAttrs(enc, LayerImage)
+	attrs["image"] = ""
+	if layer.RepeatX {
+		attrs["repeatx"] = true
+	}
+	if layer.RepeatY {
+		attrs["repeaty"] = true
+	}
+	if img := layer.Image; img != nil {
+		attrs["image"] = enc.refPath(img.Source)
+		if img.Width != 0 && img.Height != 0 {
+			attrs["imagewidth"] = img.Width
+			attrs["imageheight"] = img.Height
+		}
+		if img.Transparency != 0 {
+			attrs["transparentcolor"] = img.Transparency.hex()
+		}
+	}
+	return json.Marshal(attrs)
+}
+
 // UnmarshalXML implements the xml.Unmarshaler interface.
 func (layer *ImageLayer) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	layer.initDefaults(LayerImage)
@@ -41,7 +112,7 @@ func (layer *ImageLayer) UnmarshalXML(d *xml.Decoder, start xml.StartElement) er
 				layer.RepeatY = value
 			}
 		default:
-			logAttr(attr.Name.Local, start.Name.Local)
+			layer.Extra.attr(attr, start.Name.Local)
 		}
 	}
 
@@ -63,7 +134,9 @@ func (layer *ImageLayer) UnmarshalXML(d *xml.Decoder, start xml.StartElement) er
 					}
 					layer.Image = &img
 				default:
-					logElem(child.Name.Local, start.Name.Local)
+					if err := layer.Extra.elem(d, child, start.Name.Local); err != nil {
+						return err
+					}
 				}
 			}
 		}
@@ -72,4 +145,14 @@ func (layer *ImageLayer) UnmarshalXML(d *xml.Decoder, start xml.StartElement) er
 	return nil
 }
 
+// Clone creates a deep copy of the ImageLayer. The copy is not within any map or group.
+func (layer *ImageLayer) Clone() *ImageLayer {
+	dup := *layer
+	dup.baseLayer = layer.baseLayer.clone()
+	if layer.Image != nil {
+		dup.Image = layer.Image.Clone()
+	}
+	return &dup
+}
+
 // vim: ts=4
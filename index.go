@@ -0,0 +1,458 @@
+package tmx
+
+import (
+	"math"
+	"slices"
+)
+
+// defaultCellSize is the size of the cells of an ObjectIndex in pixels when none is given.
+const defaultCellSize = 256
+
+// ObjectIndex is a spatial index of the objects within one or more object layers, allowing
+// objects to be found by their location without searching every object.
+//
+// Objects are divided into a grid of square cells by their Bounds. The index is kept up to date
+// as objects are added, duplicated, removed, and moved with the methods of ObjectLayer, Map, and
+// MoveObjectCommand, as well as when the map is resized or its tilesets are changed. Objects that
+// are changed directly must be updated with Update, or the index rebuilt with Rebuild.
+type ObjectIndex struct {
+	cellSize float32
+	hit      HitOptions
+	tilemap  *Map
+	layers   []*indexedLayer
+	cells    map[Point][]*indexEntry
+	// lo and hi are the corners of an area containing every occupied cell, which is recomputed
+	// when stale after an occupied cell on its edge was emptied.
+	lo, hi Point
+	stale  bool
+}
+
+// indexedLayer contains the entries of an object layer within an index, in the same order as
+// the Objects of the layer.
+type indexedLayer struct {
+	layer   *ObjectLayer
+	entries []*indexEntry
+}
+
+// indexEntry is an object within an index, with its bounds, and the area around it that is
+// considered to be within it when testing for hits.
+type indexEntry struct {
+	owner  *indexedLayer
+	pos    int
+	bounds RectF
+	area   RectF
+}
+
+// NewObjectIndex creates a spatial index of the objects within the given layers, divided into
+// cells of the given size in pixels. When the cell size is not positive, a default is used.
+//
+// The cell size is best chosen to be close to the size of a typical object, such as a few tiles.
+func NewObjectIndex(cellSize float32, layers ...*ObjectLayer) *ObjectIndex {
+	if cellSize <= 0 {
+		cellSize = defaultCellSize
+	}
+	idx := &ObjectIndex{cellSize: cellSize, cells: make(map[Point][]*indexEntry)}
+	for _, layer := range layers {
+		idx.AddLayer(layer)
+	}
+	return idx
+}
+
+// IndexObjects creates a spatial index of the objects within every object layer of the map,
+// including those within groups. Layers that are added to the map afterwards can be included
+// with AddLayer, while layers that are removed from the map are removed from the index.
+func (m *Map) IndexObjects(cellSize float32) *ObjectIndex {
+	idx := NewObjectIndex(cellSize)
+	idx.tilemap = m
+	walkLayers(m, func(layer Layer) {
+		if objects, ok := layer.(*ObjectLayer); ok {
+			idx.AddLayer(objects)
+		}
+	})
+	return idx
+}
+
+// AddLayer adds the objects of a layer to the index. Has no effect when the layer is already
+// within the index.
+func (idx *ObjectIndex) AddLayer(layer *ObjectLayer) {
+	if idx.layerIndex(layer) >= 0 {
+		return
+	}
+	owner := &indexedLayer{layer: layer}
+	idx.layers = append(idx.layers, owner)
+	layer.indexes = append(layer.indexes, idx)
+	idx.fill(owner)
+}
+
+// RemoveLayer removes the objects of a layer from the index, and stops tracking changes to it.
+func (idx *ObjectIndex) RemoveLayer(layer *ObjectLayer) {
+	i := idx.layerIndex(layer)
+	if i < 0 {
+		return
+	}
+	idx.clear(idx.layers[i])
+	idx.layers = slices.Delete(idx.layers, i, i+1)
+	layer.indexes = slices.DeleteFunc(layer.indexes, func(other *ObjectIndex) bool { return other == idx })
+}
+
+// Close removes all layers from the index, after which it no longer tracks changes to them.
+func (idx *ObjectIndex) Close() {
+	for len(idx.layers) > 0 {
+		idx.RemoveLayer(idx.layers[0].layer)
+	}
+}
+
+// Update refreshes the position of an object within the index after it has been changed
+// directly, such as its Location, Size, or Rotation. Has no effect when the object is not within
+// the index.
+func (idx *ObjectIndex) Update(obj *Object) {
+	if obj.layer == nil {
+		return
+	}
+	for i := range obj.layer.Objects {
+		if &obj.layer.Objects[i] == obj {
+			idx.update(obj.layer, i)
+			return
+		}
+	}
+}
+
+// Rebuild refreshes every object within the index, such as after changing the objects of a layer
+// directly, or the orientation or tile size of the map.
+func (idx *ObjectIndex) Rebuild() {
+	for _, owner := range idx.layers {
+		idx.clear(owner)
+		idx.fill(owner)
+	}
+}
+
+// SetHitOptions changes the tolerances used to test objects for hits with At, which are the
+// defaults of HitOptions until changed.
+func (idx *ObjectIndex) SetHitOptions(opts HitOptions) {
+	idx.hit = opts
+	idx.Rebuild()
+}
+
+// Query returns the objects whose Bounds overlap an area in the pixel coordinates of the map,
+// in no particular order.
+//
+// The returned pointers refer to elements of the Objects of each layer, and are only valid
+// until they are modified.
+func (idx *ObjectIndex) Query(area RectF) []*Object {
+	idx.sync()
+	var objects []*Object
+	seen := make(map[*indexEntry]bool)
+	idx.visit(area, func(entry *indexEntry) {
+		if !seen[entry] && entry.bounds.Intersects(area) {
+			seen[entry] = true
+			objects = append(objects, entry.object())
+		}
+	})
+	return objects
+}
+
+// At returns the objects that contain a position in the pixel coordinates of the map, as tested
+// by Contains with the options given to SetHitOptions, in no particular order.
+//
+// The returned pointers refer to elements of the Objects of each layer, and are only valid
+// until they are modified.
+func (idx *ObjectIndex) At(v Vec2) []*Object {
+	idx.sync()
+	var objects []*Object
+	for _, entry := range idx.cells[idx.cell(v)] {
+		if obj := entry.object(); entry.area.Contains(v) && idx.hit.Contains(obj, v) {
+			objects = append(objects, obj)
+		}
+	}
+	return objects
+}
+
+// Nearest returns the object whose Bounds are closest to a position in the pixel coordinates of
+// the map, or nil when the index is empty. Objects whose bounds contain the position are at a
+// distance of 0.
+//
+// The returned pointer refers to an element of the Objects of its layer, and is only valid until
+// it is modified.
+func (idx *ObjectIndex) Nearest(v Vec2) *Object {
+	idx.sync()
+	if len(idx.cells) == 0 {
+		return nil
+	}
+
+	// Limit the search to the rings of cells that overlap the occupied cells
+	lo, hi := idx.extent()
+	center := idx.cell(v)
+	first := max(lo.X-center.X, center.X-hi.X, lo.Y-center.Y, center.Y-hi.Y, 0)
+	last := max(abs(lo.X-center.X), abs(hi.X-center.X), abs(lo.Y-center.Y), abs(hi.Y-center.Y))
+
+	// Search rings of cells outwards, until no unvisited cell can contain a nearer object
+	var nearest *indexEntry
+	best := math.Inf(1)
+	search := func(x, y int) {
+		for _, entry := range idx.cells[Point{X: x, Y: y}] {
+			if d := rectDistance(entry.bounds, v); d < best {
+				nearest, best = entry, d
+			}
+		}
+	}
+	for ring := first; ring <= last; ring++ {
+		top, bottom := center.Y-ring, center.Y+ring
+		left, right := center.X-ring, center.X+ring
+		for y := max(top, lo.Y); y <= min(bottom, hi.Y); y++ {
+			if y == top || y == bottom {
+				for x := max(left, lo.X); x <= min(right, hi.X); x++ {
+					search(x, y)
+				}
+				continue
+			}
+			if left >= lo.X {
+				search(left, y)
+			}
+			if right <= hi.X {
+				search(right, y)
+			}
+		}
+		if nearest != nil && best <= float64(ring)*float64(idx.cellSize) {
+			break
+		}
+	}
+	return nearest.object()
+}
+
+// extent returns the corners of an area containing every occupied cell, recomputing it when it
+// is stale.
+func (idx *ObjectIndex) extent() (lo, hi Point) {
+	if idx.stale {
+		first := true
+		for cell := range idx.cells {
+			if first {
+				idx.lo, idx.hi, first = cell, cell, false
+				continue
+			}
+			idx.lo = Point{X: min(idx.lo.X, cell.X), Y: min(idx.lo.Y, cell.Y)}
+			idx.hi = Point{X: max(idx.hi.X, cell.X), Y: max(idx.hi.Y, cell.Y)}
+		}
+		idx.stale = false
+	}
+	return idx.lo, idx.hi
+}
+
+// object returns the object referred to by an entry.
+func (entry *indexEntry) object() *Object {
+	return &entry.owner.layer.Objects[entry.pos]
+}
+
+// layerIndex returns the index of the layer within the indexed layers, or -1 when it is not found.
+func (idx *ObjectIndex) layerIndex(layer *ObjectLayer) int {
+	return slices.IndexFunc(idx.layers, func(owner *indexedLayer) bool { return owner.layer == layer })
+}
+
+// owner returns the indexed layer for a layer, or nil when it is not within the index.
+func (idx *ObjectIndex) owner(layer *ObjectLayer) *indexedLayer {
+	if i := idx.layerIndex(layer); i >= 0 {
+		return idx.layers[i]
+	}
+	return nil
+}
+
+// sync rebuilds the entries of any layer whose objects were changed without the index being
+// updated, which is detected by the number of objects no longer matching.
+func (idx *ObjectIndex) sync() {
+	for _, owner := range idx.layers {
+		if len(owner.entries) != len(owner.layer.Objects) {
+			idx.clear(owner)
+			idx.fill(owner)
+		}
+	}
+}
+
+// fill adds all objects of an indexed layer to the index.
+func (idx *ObjectIndex) fill(owner *indexedLayer) {
+	owner.entries = make([]*indexEntry, len(owner.layer.Objects))
+	for i := range owner.layer.Objects {
+		owner.entries[i] = &indexEntry{owner: owner, pos: i}
+		idx.place(owner.entries[i])
+	}
+}
+
+// clear removes all objects of an indexed layer from the index.
+func (idx *ObjectIndex) clear(owner *indexedLayer) {
+	for _, entry := range owner.entries {
+		idx.unplace(entry)
+	}
+	owner.entries = nil
+}
+
+// insert adds the object at the given index of a layer, after it was inserted into the layer.
+func (idx *ObjectIndex) insert(layer *ObjectLayer, i int) {
+	owner := idx.owner(layer)
+	if owner == nil {
+		return
+	}
+	entry := &indexEntry{owner: owner, pos: i}
+	owner.entries = slices.Insert(owner.entries, i, entry)
+	for _, next := range owner.entries[i+1:] {
+		next.pos++
+	}
+	idx.place(entry)
+}
+
+// remove removes the object at the given index of a layer, after it was removed from the layer.
+func (idx *ObjectIndex) remove(layer *ObjectLayer, i int) {
+	owner := idx.owner(layer)
+	if owner == nil || i >= len(owner.entries) {
+		return
+	}
+	idx.unplace(owner.entries[i])
+	owner.entries = slices.Delete(owner.entries, i, i+1)
+	for _, next := range owner.entries[i:] {
+		next.pos--
+	}
+}
+
+// update refreshes the object at the given index of a layer, after it was changed.
+func (idx *ObjectIndex) update(layer *ObjectLayer, i int) {
+	if owner := idx.owner(layer); owner != nil && i < len(owner.entries) {
+		idx.unplace(owner.entries[i])
+		idx.place(owner.entries[i])
+	}
+}
+
+// place computes the bounds of an entry and adds it to each cell it overlaps.
+func (idx *ObjectIndex) place(entry *indexEntry) {
+	obj := entry.object()
+	entry.bounds = obj.Bounds()
+	entry.area = idx.hit.hitArea(obj, entry.bounds)
+	idx.eachCell(entry.area, func(cell Point) {
+		if _, ok := idx.cells[cell]; !ok {
+			if len(idx.cells) == 0 {
+				idx.lo, idx.hi, idx.stale = cell, cell, false
+			} else {
+				idx.lo = Point{X: min(idx.lo.X, cell.X), Y: min(idx.lo.Y, cell.Y)}
+				idx.hi = Point{X: max(idx.hi.X, cell.X), Y: max(idx.hi.Y, cell.Y)}
+			}
+		}
+		idx.cells[cell] = append(idx.cells[cell], entry)
+	})
+}
+
+// unplace removes an entry from each cell it overlaps.
+func (idx *ObjectIndex) unplace(entry *indexEntry) {
+	idx.eachCell(entry.area, func(cell Point) {
+		entries := slices.DeleteFunc(idx.cells[cell], func(other *indexEntry) bool { return other == entry })
+		if len(entries) == 0 {
+			delete(idx.cells, cell)
+			if cell.X == idx.lo.X || cell.X == idx.hi.X || cell.Y == idx.lo.Y || cell.Y == idx.hi.Y {
+				idx.stale = true
+			}
+		} else {
+			idx.cells[cell] = entries
+		}
+	})
+}
+
+// visit calls a function with every entry in the cells an area overlaps, which may include the
+// same entry more than once.
+func (idx *ObjectIndex) visit(area RectF, fn func(entry *indexEntry)) {
+	idx.eachCell(area, func(cell Point) {
+		for _, entry := range idx.cells[cell] {
+			fn(entry)
+		}
+	})
+}
+
+// eachCell calls a function with each cell an area overlaps.
+func (idx *ObjectIndex) eachCell(area RectF, fn func(cell Point)) {
+	lo := idx.cell(area.Location)
+	hi := idx.cell(Vec2{X: area.Right(), Y: area.Bottom()})
+	for y := lo.Y; y <= hi.Y; y++ {
+		for x := lo.X; x <= hi.X; x++ {
+			fn(Point{X: x, Y: y})
+		}
+	}
+}
+
+// cell returns the cell containing a position.
+func (idx *ObjectIndex) cell(v Vec2) Point {
+	return Point{
+		X: int(math.Floor(float64(v.X / idx.cellSize))),
+		Y: int(math.Floor(float64(v.Y / idx.cellSize))),
+	}
+}
+
+// hitArea returns the bounds of an object, extended to include the area around points and
+// polylines that is considered to be within them.
+func (opts HitOptions) hitArea(obj *Object, bounds RectF) RectF {
+	var pad float32
+	switch {
+	case obj.GID != 0:
+	case obj.Type == ObjectPoint:
+		pad = opts.withDefaults().PointRadius
+	case obj.Type == ObjectPolyline:
+		pad = opts.withDefaults().LineThickness / 2
+	}
+	bounds.Location.X -= pad
+	bounds.Location.Y -= pad
+	bounds.Size.X += pad * 2
+	bounds.Size.Y += pad * 2
+	return bounds
+}
+
+// rectDistance returns the distance from a position to the nearest point of a rectangle, which
+// is 0 when the position is within it.
+func rectDistance(r RectF, v Vec2) float64 {
+	dx := max(r.Left()-v.X, 0, v.X-r.Right())
+	dy := max(r.Top()-v.Y, 0, v.Y-r.Bottom())
+	return math.Hypot(float64(dx), float64(dy))
+}
+
+// objectInserted updates the indexes of the layer after an object was inserted at the given
+// index of Objects.
+func (layer *ObjectLayer) objectInserted(i int) {
+	for _, idx := range layer.indexes {
+		idx.insert(layer, i)
+	}
+}
+
+// objectRemoved updates the indexes of the layer after the object at the given index of Objects
+// was removed.
+func (layer *ObjectLayer) objectRemoved(i int) {
+	for _, idx := range layer.indexes {
+		idx.remove(layer, i)
+	}
+}
+
+// objectChanged updates the indexes of the layer after the object at the given index of Objects
+// was changed.
+func (layer *ObjectLayer) objectChanged(i int) {
+	for _, idx := range layer.indexes {
+		idx.update(layer, i)
+	}
+}
+
+// setParent implements the Layer interface. When the layer leaves a map, it is removed from the
+// indexes created for the objects of that map.
+func (layer *ObjectLayer) setParent(parent *Map) {
+	if prev := layer.parent; prev != nil && prev != parent {
+		for _, idx := range slices.Clone(layer.indexes) {
+			if idx.tilemap == prev {
+				idx.RemoveLayer(layer)
+			}
+		}
+	}
+	layer.baseLayer.setParent(parent)
+}
+
+// reindexObjects updates the spatial indexes of every object layer of the map, after all of the
+// objects were moved, or the tilesets that align tile objects were changed.
+func (m *Map) reindexObjects() {
+	walkLayers(m, func(layer Layer) {
+		if objects, ok := layer.(*ObjectLayer); ok {
+			for i := range objects.Objects {
+				objects.objectChanged(i)
+			}
+		}
+	})
+}
+
+// vim: ts=4
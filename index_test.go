@@ -0,0 +1,278 @@
+package tmx
+
+import (
+	"math"
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+// indexedObjects returns the objects of every object layer of a map, as found by searching each of
+// them rather than with an index.
+func indexedObjects(m *Map) []*Object {
+	var objects []*Object
+	walkLayers(m, func(layer Layer) {
+		if value, ok := layer.(*ObjectLayer); ok {
+			for i := range value.Objects {
+				objects = append(objects, &value.Objects[i])
+			}
+		}
+	})
+	return objects
+}
+
+// sortedIDs returns the sorted IDs of objects.
+func sortedIDs(objects []*Object) []int {
+	ids := make([]int, len(objects))
+	for i, obj := range objects {
+		ids[i] = obj.ID
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+// randomObject returns an object of a random shape, size, and location.
+func randomObject(rng *rand.Rand) Object {
+	obj := Object{
+		Location: Vec2{X: rng.Float32()*2000 - 500, Y: rng.Float32()*2000 - 500},
+		Size:     Vec2{X: rng.Float32() * 300, Y: rng.Float32() * 300},
+		Visible:  true,
+	}
+	switch rng.Intn(4) {
+	case 1:
+		obj.Type = ObjectEllipse
+	case 2:
+		obj.Type = ObjectPoint
+		obj.Size = Vec2{}
+	case 3:
+		obj.Rotation = rng.Float32() * 360
+	}
+	return obj
+}
+
+// checkIndex compares the results of an index with those found by searching every object.
+func checkIndex(t *testing.T, rng *rand.Rand, m *Map, idx *ObjectIndex) {
+	t.Helper()
+
+	objects := indexedObjects(m)
+	for i := 0; i < 20; i++ {
+		v := Vec2{X: rng.Float32()*3000 - 1000, Y: rng.Float32()*3000 - 1000}
+		area := RectF{Location: v, Size: Vec2{X: rng.Float32() * 400, Y: rng.Float32() * 400}}
+
+		var inside, within []*Object
+		best := math.Inf(1)
+		for _, obj := range objects {
+			if obj.Bounds().Intersects(area) {
+				inside = append(inside, obj)
+			}
+			if obj.Contains(v) {
+				within = append(within, obj)
+			}
+			best = min(best, rectDistance(obj.Bounds(), v))
+		}
+
+		if got, want := sortedIDs(idx.Query(area)), sortedIDs(inside); !slices.Equal(got, want) {
+			t.Fatalf("query of %v found %v, want %v", area, got, want)
+		}
+		if got, want := sortedIDs(idx.At(v)), sortedIDs(within); !slices.Equal(got, want) {
+			t.Fatalf("objects at %v are %v, want %v", v, got, want)
+		}
+		nearest := idx.Nearest(v)
+		if nearest == nil {
+			if len(objects) > 0 {
+				t.Fatalf("no object is nearest to %v", v)
+			}
+		} else if d := rectDistance(nearest.Bounds(), v); d != best {
+			t.Fatalf("nearest object to %v is %v away, want %v", v, d, best)
+		}
+	}
+}
+
+func TestObjectIndex(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	m := NewMap(Orthogonal, Size{Width: 64, Height: 64}, Size{Width: 16, Height: 16})
+	layers := []*ObjectLayer{NewObjectLayer("a"), NewObjectLayer("b")}
+	group := NewGroupLayer("group")
+	m.AddLayer(layers[0])
+	m.AddLayer(group)
+	group.AddLayer(layers[1])
+
+	idx := m.IndexObjects(64)
+	var history History
+	for i := 0; i < 500; i++ {
+		layer := layers[rng.Intn(len(layers))]
+		switch op := rng.Intn(10); {
+		case op < 4 || len(layer.Objects) == 0:
+			layer.AddObject(randomObject(rng))
+		case op < 5:
+			layer.RemoveObject(layer.Objects[rng.Intn(len(layer.Objects))].ID)
+		case op < 6:
+			layer.DuplicateObject(layer.Objects[rng.Intn(len(layer.Objects))].ID)
+		case op < 8:
+			obj := &layer.Objects[rng.Intn(len(layer.Objects))]
+			location := Vec2{X: rng.Float32()*2000 - 500, Y: rng.Float32()*2000 - 500}
+			history.Do(&MoveObjectCommand{Layer: layer, ID: obj.ID, Location: location})
+		case op < 9:
+			obj := &layer.Objects[rng.Intn(len(layer.Objects))]
+			obj.Size = Vec2{X: rng.Float32() * 500, Y: rng.Float32() * 500}
+			obj.Rotation = rng.Float32() * 360
+			idx.Update(obj)
+		default:
+			history.Undo()
+		}
+		checkIndex(t, rng, m, idx)
+	}
+}
+
+func TestObjectIndexEmpty(t *testing.T) {
+	idx := NewObjectIndex(0)
+	if obj := idx.Nearest(Vec2{}); obj != nil {
+		t.Errorf("nearest object of an empty index is %v", obj)
+	}
+	if objects := idx.Query(RectF{Size: Vec2{X: 1000, Y: 1000}}); len(objects) != 0 {
+		t.Errorf("query of an empty index found %d objects", len(objects))
+	}
+}
+
+func TestObjectIndexLayers(t *testing.T) {
+	m := NewMap(Orthogonal, Size{Width: 8, Height: 8}, Size{Width: 16, Height: 16})
+	first, second := NewObjectLayer("first"), NewObjectLayer("second")
+	m.AddLayer(first)
+	a := first.AddObject(Object{Location: Vec2{X: 10, Y: 10}, Size: Vec2{X: 10, Y: 10}}).ID
+	idx := m.IndexObjects(32)
+
+	// Layers added to the map afterwards are not included until added to the index
+	m.AddLayer(second)
+	b := second.AddObject(Object{Location: Vec2{X: 12, Y: 12}, Size: Vec2{X: 4, Y: 4}}).ID
+	area := RectF{Location: Vec2{X: 0, Y: 0}, Size: Vec2{X: 32, Y: 32}}
+	if got := sortedIDs(idx.Query(area)); !slices.Equal(got, []int{a}) {
+		t.Errorf("query found %v before adding the layer", got)
+	}
+	idx.AddLayer(second)
+	if got := sortedIDs(idx.Query(area)); !slices.Equal(got, []int{a, b}) {
+		t.Errorf("query found %v after adding the layer", got)
+	}
+
+	// Layers removed from the map are removed from the index
+	m.RemoveLayer(first)
+	if got := sortedIDs(idx.Query(area)); !slices.Equal(got, []int{b}) {
+		t.Errorf("query found %v after removing the layer from the map", got)
+	}
+	first.AddObject(Object{Location: Vec2{X: 1, Y: 1}})
+	if got := sortedIDs(idx.Query(area)); !slices.Equal(got, []int{b}) {
+		t.Errorf("query found %v after changing a removed layer", got)
+	}
+
+	idx.Close()
+	if got := idx.Query(area); len(got) != 0 {
+		t.Errorf("query found %d objects after closing", len(got))
+	}
+}
+
+func TestObjectIndexResize(t *testing.T) {
+	m := NewMap(Orthogonal, Size{Width: 8, Height: 8}, Size{Width: 16, Height: 16})
+	layer := NewObjectLayer("objects")
+	m.AddLayer(layer)
+	id := layer.AddObject(Object{Location: Vec2{X: 8, Y: 8}, Size: Vec2{X: 4, Y: 4}}).ID
+	idx := m.IndexObjects(16)
+
+	m.Resize(Size{Width: 16, Height: 16}, Point{X: 4, Y: 4})
+	if got := sortedIDs(idx.At(Vec2{X: 74, Y: 74})); !slices.Equal(got, []int{id}) {
+		t.Errorf("objects at the new location are %v", got)
+	}
+	if got := idx.At(Vec2{X: 10, Y: 10}); len(got) != 0 {
+		t.Errorf("objects remain at the previous location: %v", sortedIDs(got))
+	}
+}
+
+func TestObjectIndexTilesets(t *testing.T) {
+	obj := objectMap(Orthogonal, Size{Width: 16, Height: 16}, AlignUnspecified,
+		Object{Location: Vec2{X: 32, Y: 48}, Size: Vec2{X: 16, Y: 16}, GID: 1})[0]
+	m := obj.tilemap()
+	idx := m.IndexObjects(16)
+
+	// Tile objects are reindexed when aligned by a different tileset
+	image := &Image{Source: "terrain.png", Size: Size{Width: 64, Height: 64}}
+	ts := NewTileset("aligned", Size{Width: 16, Height: 16}, image, 0, 0)
+	ts.ObjectAlign = AlignTopLeft
+	if err := m.ReplaceTileset(m.Tilesets[0], ts, nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := sortedIDs(idx.At(Vec2{X: 40, Y: 56})); !slices.Equal(got, []int{obj.ID}) {
+		t.Errorf("objects at the aligned location are %v", got)
+	}
+	if got := idx.At(Vec2{X: 40, Y: 40}); len(got) != 0 {
+		t.Errorf("objects remain at the previous location: %v", sortedIDs(got))
+	}
+}
+
+func TestObjectIndexNearest(t *testing.T) {
+	m := NewMap(Orthogonal, Size{Width: 8, Height: 8}, Size{Width: 16, Height: 16})
+	layer := NewObjectLayer("objects")
+	m.AddLayer(layer)
+	left := layer.AddObject(Object{Location: Vec2{X: -100, Y: 0}, Size: Vec2{X: 10, Y: 10}}).ID
+	middle := layer.AddObject(Object{Location: Vec2{X: 0, Y: 0}, Size: Vec2{X: 10, Y: 10}}).ID
+	right := layer.AddObject(Object{Location: Vec2{X: 100, Y: 0}, Size: Vec2{X: 10, Y: 10}}).ID
+	idx := m.IndexObjects(16)
+
+	// Points far outside of the occupied cells find the object nearest to them
+	tests := []struct {
+		v    Vec2
+		want int
+	}{
+		{Vec2{X: -1e6, Y: 0}, left},
+		{Vec2{X: 1e6, Y: 1e6}, right},
+		{Vec2{X: 5, Y: -1e6}, middle},
+		{Vec2{X: 60, Y: 5}, right},
+	}
+	for _, test := range tests {
+		if obj := idx.Nearest(test.v); obj == nil || obj.ID != test.want {
+			t.Errorf("nearest object to %v is %v, want %d", test.v, obj, test.want)
+		}
+	}
+
+	// Removing the objects at the edges shrinks the area that is searched
+	layer.RemoveObject(right)
+	layer.RemoveObject(left)
+	for _, v := range []Vec2{{X: -1e6, Y: 0}, {X: 1e6, Y: 1e6}} {
+		if obj := idx.Nearest(v); obj == nil || obj.ID != middle {
+			t.Errorf("nearest object to %v is %v, want %d", v, obj, middle)
+		}
+	}
+	layer.RemoveObject(middle)
+	if obj := idx.Nearest(Vec2{}); obj != nil {
+		t.Errorf("nearest object is %v after removing every object", obj)
+	}
+}
+
+func TestObjectIndexHitOptions(t *testing.T) {
+	m := NewMap(Orthogonal, Size{Width: 4, Height: 4}, Size{Width: 16, Height: 16})
+	layer := NewObjectLayer("objects")
+	m.AddLayer(layer)
+	point := layer.AddObject(Object{Location: Vec2{X: 32, Y: 32}, Type: ObjectPoint})
+	line := layer.AddObject(Object{Location: Vec2{X: 64, Y: 0}, Type: ObjectPolyline, Points: []Vec2{{0, 0}, {0, 64}}})
+	idx := m.IndexObjects(16)
+
+	// Positions beyond the default tolerances are found once the options are changed
+	tests := []struct {
+		v    Vec2
+		want []int
+	}{
+		{Vec2{X: 32, Y: 37}, []int{point.ID}},
+		{Vec2{X: 61, Y: 48}, []int{line.ID}},
+		{Vec2{X: 32, Y: 40}, nil},
+	}
+	for _, test := range tests {
+		if got := idx.At(test.v); len(got) != 0 {
+			t.Errorf("objects at %v are %v with the default options", test.v, sortedIDs(got))
+		}
+	}
+	idx.SetHitOptions(HitOptions{PointRadius: 6, LineThickness: 8})
+	for _, test := range tests {
+		if got := sortedIDs(idx.At(test.v)); !slices.Equal(got, test.want) {
+			t.Errorf("objects at %v are %v, want %v", test.v, got, test.want)
+		}
+	}
+}
+
+// vim: ts=4
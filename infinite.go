@@ -0,0 +1,125 @@
+package tmx
+
+// ToFinite converts an infinite map to a finite one, where the tiles of each tile layer are
+// stored in Tiles rather than Chunks. The map is sized to the bounds of all tiles used by its
+// layers, with the top-left tile of the bounds becoming the origin of the map. Objects and image
+// layers are moved by the same distance. Has no effect when the map is already finite.
+func (m *Map) ToFinite() {
+	if !m.Infinite {
+		return
+	}
+
+	bounds := m.usedBounds()
+	walkLayers(m, func(layer Layer) {
+		if tiles, ok := layer.(*TileLayer); ok {
+			tiles.toFinite(bounds)
+		}
+	})
+	m.moveObjects(bounds.Size, Point{X: -bounds.X, Y: -bounds.Y})
+	m.Size = bounds.Size
+	m.Infinite = false
+	m.reindexObjects()
+}
+
+// ToInfinite converts a finite map to an infinite one, where the tiles of each tile layer are
+// split into chunks of the given size. Only chunks that contain tiles are created. When the
+// chunk size is zero, the default of 16x16 is used. Has no effect when the map is already
+// infinite.
+func (m *Map) ToInfinite(chunkSize Size) {
+	if m.Infinite {
+		return
+	}
+	if chunkSize.Width <= 0 || chunkSize.Height <= 0 {
+		chunkSize = defaultChunkSize
+	}
+
+	walkLayers(m, func(layer Layer) {
+		if tiles, ok := layer.(*TileLayer); ok {
+			tiles.toInfinite(chunkSize)
+		}
+	})
+	m.Infinite = true
+}
+
+// usedBounds returns the smallest area containing every tile of the tile layers of an infinite
+// map, or an area with the size of the map when there are none.
+func (m *Map) usedBounds() Rect {
+	var x0, y0, x1, y1 int
+	found := false
+	walkLayers(m, func(layer Layer) {
+		tiles, ok := layer.(*TileLayer)
+		if !ok {
+			return
+		}
+		for i := range tiles.Chunks {
+			chunk := &tiles.Chunks[i]
+			for j, gid := range chunk.Tiles {
+				if gid == 0 {
+					continue
+				}
+				x, y := chunk.X+j%chunk.Width, chunk.Y+j/chunk.Width
+				if !found {
+					x0, y0, x1, y1 = x, y, x+1, y+1
+					found = true
+					continue
+				}
+				x0, y0 = min(x0, x), min(y0, y)
+				x1, y1 = max(x1, x+1), max(y1, y+1)
+			}
+		}
+	})
+
+	if !found {
+		return Rect{Size: m.Size}
+	}
+	return Rect{Point: Point{X: x0, Y: y0}, Size: Size{Width: x1 - x0, Height: y1 - y0}}
+}
+
+// toFinite moves the tiles of the chunks of a layer within the given bounds into Tiles.
+func (layer *TileLayer) toFinite(bounds Rect) {
+	tiles := make([]TileID, bounds.Area())
+	for i := range layer.Chunks {
+		chunk := &layer.Chunks[i]
+		for j, gid := range chunk.Tiles {
+			x, y := chunk.X+j%chunk.Width-bounds.X, chunk.Y+j/chunk.Width-bounds.Y
+			if gid != 0 && x >= 0 && x < bounds.Width && y >= 0 && y < bounds.Height {
+				tiles[x+(y*bounds.Width)] = gid
+			}
+		}
+	}
+
+	layer.Chunks = nil
+	layer.Tiles = tiles
+	layer.Size = bounds.Size
+}
+
+// toInfinite splits the tiles of a layer into chunks of the given size, skipping chunks that
+// would be empty.
+func (layer *TileLayer) toInfinite(chunkSize Size) {
+	layer.Chunks = nil
+	layer.ChunkSize = chunkSize
+	for cy := 0; cy < layer.Height; cy += chunkSize.Height {
+		for cx := 0; cx < layer.Width; cx += chunkSize.Width {
+			chunk := Chunk{
+				Rect:  Rect{Point: Point{X: cx, Y: cy}, Size: chunkSize},
+				Tiles: make([]TileID, chunkSize.Area()),
+			}
+			area := chunk.Intersect(Rect{Size: layer.Size})
+			empty := true
+			for y := area.Top(); y < area.Bottom(); y++ {
+				for x := area.Left(); x < area.Right(); x++ {
+					if gid := layer.Tiles[x+(y*layer.Width)]; gid != 0 {
+						chunk.Tiles[(x-cx)+((y-cy)*chunkSize.Width)] = gid
+						empty = false
+					}
+				}
+			}
+			if !empty {
+				layer.Chunks = append(layer.Chunks, chunk)
+			}
+		}
+	}
+	layer.Tiles = nil
+}
+
+// vim: ts=4
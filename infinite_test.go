@@ -0,0 +1,119 @@
+package tmx
+
+import (
+	"slices"
+	"testing"
+)
+
+// infiniteMap returns an infinite map with a tile layer containing the given tiles, and an
+// object layer containing a single object.
+func infiniteMap(t *testing.T, tiles map[Point]TileID) (*Map, *TileLayer, *Object) {
+	t.Helper()
+
+	m := NewMap(Orthogonal, Size{Width: 10, Height: 10}, Size{Width: 16, Height: 16})
+	m.Infinite = true
+	layer := NewTileLayer("tiles", Size{})
+	objects := NewObjectLayer("objects")
+	m.AddLayer(layer)
+	m.AddLayer(objects)
+	for p, gid := range tiles {
+		if err := layer.SetGID(p.X, p.Y, gid); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return m, layer, objects.AddObject(Object{Location: Vec2{X: 0, Y: 0}})
+}
+
+func TestToFinite(t *testing.T) {
+	tiles := map[Point]TileID{{X: -5, Y: -2}: 1, {X: 30, Y: 4}: 2 | FlipH, {X: 0, Y: 20}: 3}
+	m, layer, obj := infiniteMap(t, tiles)
+
+	m.ToFinite()
+	want := Size{Width: 36, Height: 23}
+	if m.Infinite || m.Size != want || layer.Size != want || len(layer.Chunks) != 0 {
+		t.Fatalf("map is %v with infinite %v, layer %v with %d chunks", m.Size, m.Infinite, layer.Size,
+			len(layer.Chunks))
+	}
+	for p, gid := range tiles {
+		if got := layer.GetGID(p.X+5, p.Y+2); got != gid {
+			t.Errorf("tile from %v is %d, want %d", p, got, gid)
+		}
+	}
+	if loc := (Vec2{X: 80, Y: 32}); obj.Location != loc {
+		t.Errorf("object moved to %v, want %v", obj.Location, loc)
+	}
+
+	// Has no effect on a finite map
+	m.ToFinite()
+	if m.Size != want || obj.Location.X != 80 {
+		t.Errorf("finite map changed to %v", m.Size)
+	}
+}
+
+func TestToFiniteEmpty(t *testing.T) {
+	m, layer, _ := infiniteMap(t, nil)
+	m.ToFinite()
+	if m.Infinite || m.Size != (Size{Width: 10, Height: 10}) || len(layer.Tiles) != 100 {
+		t.Errorf("empty map is %v with %d tiles", m.Size, len(layer.Tiles))
+	}
+}
+
+func TestToInfinite(t *testing.T) {
+	m := NewMap(Orthogonal, Size{Width: 20, Height: 10}, Size{Width: 16, Height: 16})
+	layer := numberedLayer(t, m)
+	// Clear the chunk in the bottom-right corner
+	layer.Fill(Rect{Point: Point{X: 16, Y: 8}, Size: Size{Width: 4, Height: 2}}, 0)
+	old := NewTileLayer("old", layer.Size)
+	copy(old.Tiles, layer.Tiles)
+
+	m.ToInfinite(Size{Width: 8, Height: 4})
+	if !m.Infinite || layer.Tiles != nil || layer.ChunkSize != (Size{Width: 8, Height: 4}) {
+		t.Fatalf("map is not infinite, with %d tiles", len(layer.Tiles))
+	}
+	if len(layer.Chunks) != 8 {
+		t.Errorf("layer has %d chunks, want 8", len(layer.Chunks))
+	}
+	for i := range layer.Chunks {
+		chunk := &layer.Chunks[i]
+		if chunk.Size != layer.ChunkSize || chunk.X%8 != 0 || chunk.Y%4 != 0 {
+			t.Errorf("chunk %d is %v", i, chunk.Rect)
+		}
+	}
+	for y := -1; y <= m.Size.Height; y++ {
+		for x := -1; x <= m.Size.Width; x++ {
+			if got, want := layer.GetGID(x, y), old.GetGID(x, y); got != want {
+				t.Errorf("tile at %d,%d is %d, want %d", x, y, got, want)
+			}
+		}
+	}
+
+	// Converting back gives the same map, as the tiles cover the whole area of the map
+	m.ToFinite()
+	if m.Size != old.Size || !slices.Equal(layer.Tiles, old.Tiles) {
+		t.Errorf("finite map is %v with tiles %v", m.Size, layer.Tiles)
+	}
+}
+
+func TestInfiniteRoundTrip(t *testing.T) {
+	tiles := map[Point]TileID{{X: -17, Y: -1}: 4, {X: 3, Y: 40}: 5 | FlipV, {X: 16, Y: 16}: 6}
+	for _, format := range []Format{FormatXML, FormatJSON} {
+		for _, data := range dataFormats {
+			m, layer, _ := infiniteMap(t, tiles)
+			layer.Encoding, layer.Compression = data.encoding, data.compression
+
+			got, _ := roundTrip(t, m, format)
+			result := got.TileLayers[0]
+			if !got.Infinite || len(result.Chunks) != len(layer.Chunks) {
+				t.Errorf("%v: map has %d chunks, want %d", format, len(result.Chunks), len(layer.Chunks))
+				continue
+			}
+			for p, gid := range tiles {
+				if value := result.GetGID(p.X, p.Y); value != gid {
+					t.Errorf("%v/%v: tile at %v is %d, want %d", format, data.encoding, p, value, gid)
+				}
+			}
+		}
+	}
+}
+
+// vim: ts=4
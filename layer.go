@@ -31,6 +31,9 @@ type Layer interface {
 	setNext(layer Layer)
 	setParent(parent *Map)
 	setContainer(container Container)
+	base() *baseLayer
+	encodeXML(enc *encoder, e *xml.Encoder, start xml.StartElement) error
+	encodeJSON(enc *encoder) ([]byte, error)
 }
 
 type baseLayer struct {
@@ -68,6 +71,8 @@ type baseLayer struct {
 	next Layer
 	// next maintains a reference to the previous layer in the linked-list.
 	prev Layer
+	// Extra contains unrecognized data that was retained while reading, see PreserveUnknown.
+	Extra Extra
 	// cache is a reference to the parent map's Cache.
 	cache *Cache
 }
@@ -88,7 +93,7 @@ func (layer *baseLayer) xmlAttr(attr xml.Attr) (bool, error) {
 			layer.ID = value
 		}
 	case "name":
-		layer.Class = attr.Value
+		layer.Name = attr.Value
 	case "class":
 		layer.Class = attr.Value
 	case "tintcolor":
@@ -163,13 +168,97 @@ func (layer *baseLayer) xmlAttr(attr xml.Attr) (bool, error) {
 	return true, nil
 }
 
+// xmlAttrs returns the XML attributes for the values of the base layer type that differ
+// from their defaults.
+func (layer *baseLayer) xmlAttrs() []xml.Attr {
+	attrs := []xml.Attr{xmlInt("id", layer.ID), xmlStr("name", layer.Name)}
+	if layer.Class != "" {
+		attrs = append(attrs, xmlStr("class", layer.Class))
+	}
+	if layer.X != 0 || layer.Y != 0 {
+		attrs = append(attrs, xmlInt("x", layer.X), xmlInt("y", layer.Y))
+	}
+	if layer.Width != 0 || layer.Height != 0 {
+		attrs = append(attrs, xmlInt("width", layer.Width), xmlInt("height", layer.Height))
+	}
+	if !layer.Visible {
+		attrs = append(attrs, xmlBool("visible", false))
+	}
+	if layer.Opacity != 1.0 {
+		attrs = append(attrs, xmlFloat32("opacity", layer.Opacity))
+	}
+	if layer.TintColor != 0 {
+		attrs = append(attrs, xmlStr("tintcolor", layer.TintColor.hex()))
+	}
+	if layer.Offset.X != 0 || layer.Offset.Y != 0 {
+		attrs = append(attrs,
+			xmlFloat32("offsetx", layer.Offset.X),
+			xmlFloat32("offsety", layer.Offset.Y),
+		)
+	}
+	if layer.Parallax.X != 1.0 || layer.Parallax.Y != 1.0 {
+		attrs = append(attrs,
+			xmlFloat32("parallaxx", layer.Parallax.X),
+			xmlFloat32("parallaxy", layer.Parallax.Y),
+		)
+	}
+	return layer.Extra.xmlAttrs(attrs)
+}
+
+// jsonAttrs returns the fields of the base layer type as they are written in the JSON format
+// within the document of the encoder, using the given type name for the layer.
+func (layer *baseLayer) jsonAttrs(enc *encoder, lt LayerType) map[string]any {
+	attrs := map[string]any{
+		"id":      layer.ID,
+		"name":    layer.Name,
+		"type":    lt,
+		"x":       layer.X,
+		"y":       layer.Y,
+		"visible": layer.Visible,
+		"opacity": jsonFloat(layer.Opacity),
+	}
+	if layer.Class != "" {
+		attrs["class"] = layer.Class
+	}
+	if layer.Width != 0 || layer.Height != 0 {
+		attrs["width"] = layer.Width
+		attrs["height"] = layer.Height
+	}
+	if layer.TintColor != 0 {
+		attrs["tintcolor"] = layer.TintColor.hex()
+	}
+	if layer.Offset.X != 0 || layer.Offset.Y != 0 {
+		attrs["offsetx"] = jsonFloat(layer.Offset.X)
+		attrs["offsety"] = jsonFloat(layer.Offset.Y)
+	}
+	if layer.Parallax.X != 1.0 || layer.Parallax.Y != 1.0 {
+		attrs["parallaxx"] = jsonFloat(layer.Parallax.X)
+		attrs["parallaxy"] = jsonFloat(layer.Parallax.Y)
+	}
+	if len(layer.Properties) > 0 {
+		attrs["properties"] = enc.json(&layer.Properties)
+	}
+	layer.Extra.jsonAttrs(attrs)
+	return attrs
+}
+
+// jsonLayers collects the given layer and all that follow it into a slice, as the layers of
+// a container are written in the JSON format within the document of the encoder.
+func jsonLayers(enc *encoder, head Layer) []jsonValue {
+	layers := []jsonValue{}
+	for layer := head; layer != nil; layer = layer.Next() {
+		layers = append(layers, enc.json(layer))
+	}
+	return layers
+}
+
 // xmlProp attempts to process the given element into the base layer type, returning whether
 // it was handled or not and if an error occurred.
 func (layer *baseLayer) xmlProp(d *xml.Decoder, start xml.StartElement) (bool, error) {
 	switch start.Name.Local {
 	case "properties":
 		layer.Properties = make(Properties)
-		if err := d.DecodeElement(&layer.Properties, &start); err != nil {
+		if err := layer.Properties.decodeXML(d, start, &layer.Extra); err != nil {
 			return false, err
 		}
 	default:
@@ -204,7 +293,10 @@ func (layer *baseLayer) Container() Container {
 	if layer.container != nil {
 		return layer.container
 	}
-	return layer.parent
+	if layer.parent != nil {
+		return layer.parent
+	}
+	return nil
 }
 
 // setPrev implements the Layer interface.
@@ -220,6 +312,9 @@ func (layer *baseLayer) setNext(next Layer) {
 // setParent implements the Layer interface.
 func (layer *baseLayer) setParent(parent *Map) {
 	layer.parent = parent
+	if parent != nil {
+		layer.cache = parent.cache
+	}
 }
 
 // setContainer implements the Layer interface.
@@ -227,11 +322,17 @@ func (layer *baseLayer) setContainer(container Container) {
 	layer.container = container
 }
 
+// base implements the Layer interface.
+func (layer *baseLayer) base() *baseLayer {
+	return layer
+}
+
 // initDefaults initializes default values of a layer.
 func (layer *baseLayer) initDefaults(lt LayerType) {
 	layer.layerType = lt
 	layer.Opacity = 1.0
 	layer.Visible = true
+	layer.Parallax = Vec2{X: 1.0, Y: 1.0}
 }
 
 // jsonLayer decodes a JSON-formatted TMX layer, returning it as an interface.
@@ -240,13 +341,16 @@ func jsonLayer(d *json.Decoder, cache *Cache) (Layer, error) {
 	base.cache = cache
 	base.Opacity = 1.0
 	base.Visible = true
+	base.Parallax = Vec2{X: 1.0, Y: 1.0}
 
 	var objects []Object
-	var tileData TileData
+	// The JSON format defaults to CSV, which is an array of tile IDs
+	tileData := TileData{Encoding: EncodingCSV}
 	var layers []Layer
 	var image Image
 	var repeatX, repeatY bool
 	var order DrawOrder
+	var color Color
 	var start Point // TODO: Is "startx" and "starty" actually ever in output?
 
 	token, err := d.Token()
@@ -397,6 +501,24 @@ func jsonLayer(d *json.Decoder, cache *Cache) (Layer, error) {
 			if image.Source, err = jsonProp[string](d); err != nil {
 				return nil, err
 			}
+		case "imagewidth":
+			if value, err := jsonProp[float64](d); err != nil {
+				return nil, err
+			} else {
+				image.Width = int(value)
+			}
+		case "imageheight":
+			if value, err := jsonProp[float64](d); err != nil {
+				return nil, err
+			} else {
+				image.Height = int(value)
+			}
+		case "color":
+			if value, err := jsonProp[string](d); err != nil {
+				return nil, err
+			} else if color, err = ParseColor(value); err != nil {
+				return nil, err
+			}
 		case "repeatx":
 			if value, err := jsonProp[bool](d); err != nil {
 				return nil, err
@@ -483,7 +605,9 @@ func jsonLayer(d *json.Decoder, cache *Cache) (Layer, error) {
 				tileData.tileData = []byte(str)
 			}
 		default:
-			jsonSkip(d)
+			if err := base.Extra.prop(d, name, "layer"); err != nil {
+				return nil, err
+			}
 		}
 	}
 
@@ -498,7 +622,10 @@ func jsonLayer(d *json.Decoder, cache *Cache) (Layer, error) {
 		impl := ImageLayer{baseLayer: base, Image: &image, RepeatX: repeatX, RepeatY: repeatY}
 		return &impl, nil
 	case LayerObject:
-		impl := ObjectLayer{baseLayer: base, Objects: objects, DrawOrder: order}
+		impl := ObjectLayer{baseLayer: base, Color: color, Objects: objects, DrawOrder: order}
+		for i := range impl.Objects {
+			impl.Objects[i].layer = &impl
+		}
 		return &impl, nil
 	case LayerGroup:
 		impl := GroupLayer{baseLayer: base}
@@ -511,4 +638,32 @@ func jsonLayer(d *json.Decoder, cache *Cache) (Layer, error) {
 	}
 }
 
+// clone returns a copy of the base layer that is not within any container.
+func (layer *baseLayer) clone() baseLayer {
+	dup := *layer
+	dup.parent = nil
+	dup.container = nil
+	dup.next = nil
+	dup.prev = nil
+	dup.Properties = layer.Properties.clone()
+	dup.Extra = layer.Extra.clone()
+	return dup
+}
+
+// cloneLayer creates a deep copy of a layer of any type.
+func cloneLayer(layer Layer) Layer {
+	switch value := layer.(type) {
+	case *TileLayer:
+		return value.Clone()
+	case *ObjectLayer:
+		return value.Clone()
+	case *ImageLayer:
+		return value.Clone()
+	case *GroupLayer:
+		return value.Clone()
+	default:
+		return nil
+	}
+}
+
 // vim: ts=4
@@ -4,13 +4,18 @@ import (
 	"bytes"
 	"encoding/json"
 	"encoding/xml"
+	"errors"
 	"fmt"
 	"io"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
 )
 
+// ErrTilesetNotFound is returned when a tileset is expected to be used by a map, but it is not.
+var ErrTilesetNotFound = errors.New("tileset is not used by the map")
+
 // Map is the top-level object defining a complete tilemap, composed of tilesets, layers, and
 // objects.
 type Map struct {
@@ -32,8 +37,8 @@ type Map struct {
 	Size Size
 	// TileSize is the dimensions of tiles on the map in pixel units.
 	TileSize Size
-	// compressionlevel is the compression level to use for tile layer data (defaults to -1, which means to use the algorithm default).
-	compressionlevel int
+	// CompressionLevel is the compression level to use for tile layer data (defaults to -1, which means to use the algorithm default).
+	CompressionLevel int
 	// HexSideLength determines the width or height (depending on the staggered axis) of the tile’s edge, in pixels.
 	// Only for hexagonal maps.
 	HexSideLength int
@@ -59,6 +64,8 @@ type Map struct {
 	Properties
 	// container is the base container implementation for types that hold a collection of layers.
 	container
+	// Extra contains unrecognized data that was retained while reading, see PreserveUnknown.
+	Extra Extra
 
 	cache *Cache
 }
@@ -73,11 +80,27 @@ func (m *Map) String() string {
 	return sb.String()
 }
 
+// NewMap creates a new finite map with the given orientation, size in tiles, and tile size in
+// pixels. The map is ready to have tilesets and layers added to it, and behaves the same as one
+// that was read from a file.
+func NewMap(orientation Orientation, size, tileSize Size) *Map {
+	var m Map
+	m.initDefault()
+	m.Version = formatVersion
+	m.Orientation = orientation
+	m.Size = size
+	m.TileSize = tileSize
+	m.NextLayerId = 1
+	m.NextObjectId = 1
+	m.Properties = make(Properties)
+	return &m
+}
+
 func (m *Map) initDefault() {
 	if m.cache == nil {
 		m.cache = NewCache()
 	}
-	m.compressionlevel = -1
+	m.CompressionLevel = -1
 	m.TileSize = Size{Width: 16, Height: 16}
 }
 
@@ -106,7 +129,7 @@ func (m *Map) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 			}
 		case "compressionlevel":
 			if value, err := strconv.Atoi(attr.Value); err == nil {
-				m.compressionlevel = value
+				m.CompressionLevel = value
 			} else {
 				return err
 			}
@@ -189,7 +212,7 @@ func (m *Map) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 				return err
 			}
 		default:
-			logAttr(attr.Value, start.Name.Local)
+			m.Extra.attr(attr, start.Name.Local)
 		}
 	}
 
@@ -206,11 +229,9 @@ func (m *Map) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 			switch child.Name.Local {
 			case "properties":
 				m.Properties = make(Properties)
-				if err := m.Properties.UnmarshalXML(d, child); err != nil {
+				if err := m.Properties.decodeXML(d, child, &m.Extra); err != nil {
 					return err
 				}
-			case "editorsettings":
-				// Skip
 			case "tileset":
 				var tileset MapTileset
 				tileset.Map = m
@@ -248,7 +269,9 @@ func (m *Map) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 				}
 				m.AddLayer(&layer)
 			default:
-				logElem(child.Name.Local, start.Name.Local)
+				if err := m.Extra.elem(d, child, start.Name.Local); err != nil {
+					return err
+				}
 			}
 		}
 
@@ -291,7 +314,7 @@ func (m *Map) UnmarshalJSON(data []byte) error {
 			if value, err := jsonProp[float64](d); err != nil {
 				return err
 			} else {
-				m.compressionlevel = int(value)
+				m.CompressionLevel = int(value)
 			}
 		case "width":
 			if value, err := jsonProp[float64](d); err != nil {
@@ -430,17 +453,433 @@ func (m *Map) UnmarshalJSON(data []byte) error {
 				return err
 			}
 		default:
-			jsonSkip(d)
+			if err := m.Extra.prop(d, name, "map"); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// MarshalXML implements the xml.Marshaler interface.
+func (m *Map) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return m.encodeXML(nil, e, start)
+}
+
+// encodeXML implements the xmlEncoding interface.
+func (m *Map) encodeXML(enc *encoder, e *xml.Encoder, start xml.StartElement) error {
+	version := m.Version
+	if version == "" {
+		version = formatVersion
+	}
+
+	start = xml.StartElement{Name: xml.Name{Local: "map"}}
+	start.Attr = append(start.Attr, xmlStr("version", version))
+	if m.TiledVersion != "" {
+		start.Attr = append(start.Attr, xmlStr("tiledversion", m.TiledVersion))
+	}
+	if m.Class != "" {
+		start.Attr = append(start.Attr, xmlStr("class", m.Class))
+	}
+	start.Attr = append(start.Attr,
+		xmlStr("orientation", m.Orientation.String()),
+		xmlStr("renderorder", m.RenderOrder.String()),
+	)
+	if m.CompressionLevel != -1 {
+		start.Attr = append(start.Attr, xmlInt("compressionlevel", m.CompressionLevel))
+	}
+	start.Attr = append(start.Attr,
+		xmlInt("width", m.Size.Width),
+		xmlInt("height", m.Size.Height),
+		xmlInt("tilewidth", m.TileSize.Width),
+		xmlInt("tileheight", m.TileSize.Height),
+	)
+	if m.Orientation == Hexagonal {
+		start.Attr = append(start.Attr, xmlInt("hexsidelength", m.HexSideLength))
+	}
+	if m.Orientation == Staggered || m.Orientation == Hexagonal {
+		start.Attr = append(start.Attr,
+			xmlStr("staggeraxis", m.StaggerAxis.String()),
+			xmlStr("staggerindex", m.StaggerIndex.String()),
+		)
+	}
+	if m.ParallaxOrigin.X != 0 || m.ParallaxOrigin.Y != 0 {
+		start.Attr = append(start.Attr,
+			xmlFloat32("parallaxoriginx", m.ParallaxOrigin.X),
+			xmlFloat32("parallaxoriginy", m.ParallaxOrigin.Y),
+		)
+	}
+	if m.BackgroundColor != 0 {
+		start.Attr = append(start.Attr, xmlStr("backgroundcolor", m.BackgroundColor.hex()))
+	}
+	start.Attr = append(start.Attr,
+		xmlBool("infinite", m.Infinite),
+		xmlInt("nextlayerid", m.NextLayerId),
+		xmlInt("nextobjectid", m.NextObjectId),
+	)
+	start.Attr = m.Extra.xmlAttrs(start.Attr)
+
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := m.Extra.marshalXML(e); err != nil {
+		return err
+	}
+	if err := e.EncodeElement(enc.xml(m.Properties.owned(&m.Extra)), start); err != nil {
+		return err
+	}
+	for _, tileset := range m.Tilesets {
+		if err := e.EncodeElement(enc.xml(tileset), start); err != nil {
+			return err
+		}
+	}
+	for layer := m.Head(); layer != nil; layer = layer.Next() {
+		if err := e.EncodeElement(enc.xml(layer), start); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (m *Map) MarshalJSON() ([]byte, error) {
+	return m.encodeJSON(nil)
+}
+
+// encodeJSON implements the jsonEncoding interface.
+func (m *Map) encodeJSON(enc *encoder) ([]byte, error) {
+	attrs := map[string]any{
+		"type":             "map",
+		"version":          formatVersion,
+		"orientation":      m.Orientation,
+		"renderorder":      m.RenderOrder,
+		"compressionlevel": m.CompressionLevel,
+		"width":            m.Size.Width,
+		"height":           m.Size.Height,
+		"tilewidth":        m.TileSize.Width,
+		"tileheight":       m.TileSize.Height,
+		"infinite":         m.Infinite,
+		"nextlayerid":      m.NextLayerId,
+		"nextobjectid":     m.NextObjectId,
+		"tilesets":         jsonValues(enc, m.Tilesets),
+		"layers":           jsonLayers(enc, m.Head()),
+	}
+	if m.Version != "" {
+		attrs["version"] = m.Version
+	}
+	if m.TiledVersion != "" {
+		attrs["tiledversion"] = m.TiledVersion
+	}
+	if m.Class != "" {
+		attrs["class"] = m.Class
+	}
+	if m.Orientation == Hexagonal {
+		attrs["hexsidelength"] = m.HexSideLength
+	}
+	if m.Orientation == Staggered || m.Orientation == Hexagonal {
+		attrs["staggeraxis"] = m.StaggerAxis
+		attrs["staggerindex"] = m.StaggerIndex
+	}
+	if m.ParallaxOrigin.X != 0 || m.ParallaxOrigin.Y != 0 {
+		attrs["parallaxoriginx"] = jsonFloat(m.ParallaxOrigin.X)
+		attrs["parallaxoriginy"] = jsonFloat(m.ParallaxOrigin.Y)
+	}
+	if m.BackgroundColor != 0 {
+		attrs["backgroundcolor"] = m.BackgroundColor.hex()
+	}
+	if len(m.Properties) > 0 {
+		attrs["properties"] = enc.json(&m.Properties)
+	}
+	m.Extra.jsonAttrs(attrs)
+	return json.Marshal(attrs)
+}
+
+// AddLayer appends a new layer to the map. When the layer is already within a container, it is
+// moved.
+//
+// Layers without an ID are assigned the next available one, as are layers being moved from a
+// different map.
+func (m *Map) AddLayer(layer Layer) error {
+	return insertLayer(m, layer, nil)
+}
+
+// InsertBefore inserts a layer into the map before the mark layer, which must be a top-level
+// layer of the map. When the layer is already within a container, it is moved.
+func (m *Map) InsertBefore(layer, mark Layer) error {
+	if mark == nil {
+		return ErrNotChild
+	}
+	return insertLayer(m, layer, mark)
+}
+
+// InsertAfter inserts a layer into the map after the mark layer, which must be a top-level
+// layer of the map. When the layer is already within a container, it is moved.
+func (m *Map) InsertAfter(layer, mark Layer) error {
+	if mark == nil || mark.Container() != Container(m) {
+		return ErrNotChild
+	}
+	return insertLayer(m, layer, mark.Next())
+}
+
+// RemoveLayer removes a top-level layer from the map.
+func (m *Map) RemoveLayer(layer Layer) error {
+	return removeLayer(m, layer)
+}
+
+// MoveLayer moves a top-level layer of the map to the given index, where 0 is the head.
+func (m *Map) MoveLayer(layer Layer, index int) error {
+	return moveLayer(m, layer, index)
+}
+
+// claimLayerIDs assigns the next available ID to a layer and its children that are without
+// one, or to all of them when reassign is set, and ensures NextLayerId remains greater than
+// all of their IDs. The objects of object layers are assigned IDs from NextObjectId the same
+// way.
+func (m *Map) claimLayerIDs(layer Layer, reassign bool) {
+	base := layer.base()
+	if base.ID <= 0 || reassign {
+		base.ID = max(m.NextLayerId, 1)
+	}
+	m.NextLayerId = max(m.NextLayerId, base.ID+1)
+
+	switch value := layer.(type) {
+	case *ObjectLayer:
+		for i := range value.Objects {
+			obj := &value.Objects[i]
+			if obj.ID <= 0 || reassign {
+				obj.ID = max(m.NextObjectId, 1)
+			}
+			m.NextObjectId = max(m.NextObjectId, obj.ID+1)
+		}
+	case *GroupLayer:
+		for child := value.Head(); child != nil; child = child.Next() {
+			m.claimLayerIDs(child, reassign)
+		}
+	}
+}
+
+// nextObjectID returns the next available object ID, and advances NextObjectId.
+func (m *Map) nextObjectID() int {
+	id := max(m.NextObjectId, 1)
+	m.NextObjectId = id + 1
+	return id
+}
+
+// FindObjectByID searches all object layers of the map for the object with the given ID,
+// returning it along with the layer it is within, or nil when it is not found.
+func (m *Map) FindObjectByID(id int) (*Object, *ObjectLayer) {
+	var obj *Object
+	var owner *ObjectLayer
+	walkLayers(m, func(layer Layer) {
+		if objects, ok := layer.(*ObjectLayer); ok && obj == nil {
+			if obj = objects.Object(id); obj != nil {
+				owner = objects
+			}
+		}
+	})
+	return obj, owner
+}
+
+// RemoveObject removes the object with the given ID from the object layer it is within,
+// returning false when it is not found. Object properties that refer to the object are cleared.
+func (m *Map) RemoveObject(id int) bool {
+	if _, layer := m.FindObjectByID(id); layer != nil {
+		return layer.RemoveObject(id)
+	}
+	return false
+}
+
+// unlinkObject clears all object properties within the map that refer to the object with the
+// given ID.
+func (m *Map) unlinkObject(id int) {
+	m.Properties.unlinkObject(id)
+	walkLayers(m, func(layer Layer) {
+		if objects, ok := layer.(*ObjectLayer); ok {
+			objects.unlinkObject(id)
+		} else {
+			layer.base().Properties.unlinkObject(id)
+		}
+	})
+}
+
+// AddTileset adds a tileset to the map, assigning it the first global tile ID that follows
+// those of the existing tilesets. Tilesets without a source are embedded within the map. When
+// the tileset is already used by the map, the existing one is returned.
+func (m *Map) AddTileset(tileset *Tileset) *MapTileset {
+	if ts := m.mapTileset(tileset); ts != nil {
+		return ts
+	}
+
+	first := TileID(1)
+	if n := len(m.Tilesets); n > 0 {
+		last := m.Tilesets[n-1]
+		first = last.FirstGID + last.idCount()
+	}
+
+	ts := &MapTileset{FirstGID: first, Map: m}
+	ts.setTileset(tileset)
+	m.Tilesets = append(m.Tilesets, ts)
+	return ts
+}
+
+// RemoveTileset removes a tileset from the map. The tiles of the map that use it are replaced
+// with the tiles given by remap, which maps local IDs of the removed tileset to tiles of the
+// other tilesets of the map, or cleared when they have no mapping. The remap may be nil.
+//
+// The global tile IDs of the remaining tilesets are recomputed, and all tile layers and tile
+// objects are rewritten to match, preserving their flip/rotate flags.
+func (m *Map) RemoveTileset(tileset *MapTileset, remap map[TileID]*Tile) error {
+	i := slices.Index(m.Tilesets, tileset)
+	if i < 0 {
+		return ErrTilesetNotFound
+	}
+
+	prev := slices.Clone(m.Tilesets)
+	m.Tilesets = slices.Delete(m.Tilesets, i, i+1)
+	m.remapTiles(prev, func(ts *MapTileset, id TileID) (*MapTileset, TileID) {
+		if ts != tileset {
+			return ts, id
+		}
+		if tile, ok := remap[id]; ok && tile != nil {
+			return m.mapTileset(tile.Tileset), tile.ID
+		}
+		return nil, 0
+	})
+
+	tileset.Map = nil
+	return nil
+}
+
+// ReplaceTileset replaces a tileset of the map with another, as with the "Replace Tileset"
+// command of the Tiled editor. Tiles of the map that use the old tileset are changed to the
+// tile of the new tileset given by idMap, which maps old local IDs to new ones, or to the tile
+// with the same ID when they have no mapping. The idMap may be nil. Tiles that do not exist
+// within the new tileset are cleared.
+//
+// The global tile IDs of the tilesets are recomputed, and all tile layers and tile objects are
+// rewritten to match, preserving their flip/rotate flags.
+func (m *Map) ReplaceTileset(old *MapTileset, tileset *Tileset, idMap map[TileID]TileID) error {
+	if !slices.Contains(m.Tilesets, old) {
+		return ErrTilesetNotFound
+	}
+
+	prev := slices.Clone(m.Tilesets)
+	old.setTileset(tileset)
+	count := tileset.idCount()
+	m.remapTiles(prev, func(ts *MapTileset, id TileID) (*MapTileset, TileID) {
+		if ts != old {
+			return ts, id
+		}
+		if value, ok := idMap[id]; ok {
+			id = value
+		}
+		if id >= count {
+			return nil, 0
+		}
+		return ts, id
+	})
+	return nil
+}
+
+// importGID returns the global tile ID within the map of a tile from the tileset of another
+// document, such as a template, preserving its flip/rotate flags. The tileset is added to the map
+// when neither it nor one loaded from the same source is used by the map.
+func (m *Map) importGID(ts *MapTileset, gid TileID) TileID {
+	target := m.mapTileset(ts.Tileset)
+	if target == nil && ts.Source != "" {
+		i := slices.IndexFunc(m.Tilesets, func(other *MapTileset) bool { return other.Source == ts.Source })
+		if i >= 0 {
+			target = m.Tilesets[i]
 		}
 	}
+	if target == nil {
+		target = m.AddTileset(ts.Tileset)
+	}
+	return (target.FirstGID + gid&ClearMask - ts.FirstGID) | gid&^ClearMask
+}
 
+// mapTileset returns the map tileset that uses the given tileset, or nil when it is not used by
+// the map.
+func (m *Map) mapTileset(tileset *Tileset) *MapTileset {
+	for _, ts := range m.Tilesets {
+		if ts.Tileset == tileset {
+			return ts
+		}
+	}
 	return nil
 }
 
-// AddLayer appends a new layer to the map.
-func (m *Map) AddLayer(layer Layer) {
-	m.container.AddLayer(layer)
-	m.head.setParent(m)
+// remapTiles recomputes the first global tile ID of each tileset after the tilesets of the map
+// have been changed from prev, and rewrites every global tile ID within the map to match.
+//
+// The lookup function receives the tileset and local ID of each tile under the previous layout,
+// and returns the tileset and local ID that replaces it, or a nil tileset to clear it.
+func (m *Map) remapTiles(prev []*MapTileset, lookup func(ts *MapTileset, id TileID) (*MapTileset, TileID)) {
+	firsts := make([]TileID, len(prev))
+	for i, ts := range prev {
+		firsts[i] = ts.FirstGID
+	}
+
+	first := TileID(1)
+	for _, ts := range m.Tilesets {
+		ts.FirstGID = first
+		first += ts.idCount()
+	}
+
+	cache := make(map[TileID]TileID)
+	m.rewriteGIDs(func(gid TileID) TileID {
+		clean := gid & ClearMask
+		if clean == 0 {
+			return gid
+		}
+		value, ok := cache[clean]
+		if !ok {
+			for i := len(prev) - 1; i >= 0; i-- {
+				if firsts[i] <= clean {
+					if ts, id := lookup(prev[i], clean-firsts[i]); ts != nil {
+						value = ts.FirstGID + id
+					}
+					break
+				}
+			}
+			cache[clean] = value
+		}
+		if value == 0 {
+			return 0
+		}
+		return value | (gid &^ ClearMask)
+	})
+
+	// Tile objects may now be aligned and sized by a different tileset
+	m.reindexObjects()
+}
+
+// rewriteGIDs replaces every global tile ID within the tile layers and tile objects of the map
+// with the result of the given function. Objects that inherit their tile from a template are
+// left unchanged.
+func (m *Map) rewriteGIDs(fn func(gid TileID) TileID) {
+	walkLayers(m, func(layer Layer) {
+		switch value := layer.(type) {
+		case *TileLayer:
+			for i, gid := range value.Tiles {
+				value.Tiles[i] = fn(gid)
+			}
+			for i := range value.Chunks {
+				chunk := &value.Chunks[i]
+				for j, gid := range chunk.Tiles {
+					chunk.Tiles[j] = fn(gid)
+				}
+			}
+		case *ObjectLayer:
+			for i := range value.Objects {
+				obj := &value.Objects[i]
+				if obj.GID != 0 && (obj.Template == nil || obj.flags&flagGID != 0) {
+					obj.GID = fn(obj.GID)
+				}
+			}
+		}
+	})
 }
 
 // Tileset returns the child Tileset and local ID from the given global tile ID.
@@ -460,6 +899,15 @@ func (m *Map) Tileset(gid TileID) (*Tileset, TileID) {
 	return nil, 0
 }
 
+// GID returns the global tile ID of a tile within the map, or 0 when the tileset of the tile is
+// not used by the map.
+func (m *Map) GID(tile *Tile) TileID {
+	if ts := m.mapTileset(tile.Tileset); ts != nil {
+		return ts.FirstGID + tile.ID
+	}
+	return 0
+}
+
 // ReadMap reads a tilemap from a file, using the specified format. When the format is
 // FormatUnknown, it will attempt to be detected based on extension and file heuristics.
 //
@@ -496,4 +944,46 @@ func ReadMap(path string, format Format, cache *Cache) (*Map, error) {
 	return &tilemap, nil
 }
 
+// WriteMap writes a tilemap to a file, using the specified format. When the format is
+// FormatUnknown, it will be detected based on the file extension.
+func WriteMap(path string, format Format, tilemap *Map) error {
+	return writeFile(path, format, tilemap, tilemap.Source)
+}
+
+// Clone creates a deep copy of the Map, including all of its layers and tilesets. The layers of
+// the copy are linked together in the same order, and refer to the copy as their parent.
+func (m *Map) Clone() *Map {
+	return m.clone(false)
+}
+
+// CloneShared creates a deep copy of the Map the same as Clone, except tilesets that were loaded
+// from an external file and are held by the Cache are shared with the original rather than
+// copied. This is considerably cheaper when the tilesets are treated as immutable.
+func (m *Map) CloneShared() *Map {
+	return m.clone(true)
+}
+
+// clone creates a deep copy of the map, optionally sharing cached tilesets.
+func (m *Map) clone(share bool) *Map {
+	dup := *m
+	dup.container = container{}
+	dup.Properties = m.Properties.clone()
+	dup.Extra = m.Extra.clone()
+
+	dup.Tilesets = make([]*MapTileset, len(m.Tilesets))
+	for i, ts := range m.Tilesets {
+		mts := *ts
+		mts.Map = &dup
+		if !share || !ts.cached() {
+			mts.Tileset = ts.Tileset.Clone()
+		}
+		dup.Tilesets[i] = &mts
+	}
+
+	for layer := m.Head(); layer != nil; layer = layer.Next() {
+		dup.AddLayer(cloneLayer(layer))
+	}
+	return &dup
+}
+
 // vim: ts=4
@@ -0,0 +1,252 @@
+package tmx
+
+import (
+	"errors"
+	"maps"
+	"slices"
+	"testing"
+)
+
+func TestNewMap(t *testing.T) {
+	m := NewMap(Isometric, Size{Width: 8, Height: 4}, Size{Width: 32, Height: 16})
+	if m.Orientation != Isometric || m.Size != (Size{8, 4}) || m.TileSize != (Size{32, 16}) {
+		t.Errorf("map is %v %v %v", m.Orientation, m.Size, m.TileSize)
+	}
+	if m.Version != formatVersion || m.NextLayerId != 1 || m.NextObjectId != 1 || m.CompressionLevel != -1 ||
+		m.Infinite || m.Properties == nil || m.cache == nil {
+		t.Errorf("map has unexpected defaults: %+v", m)
+	}
+
+	// Layers are assigned IDs as they are added
+	ground := NewTileLayer("ground", m.Size)
+	objects := NewObjectLayer("objects")
+	group := NewGroupLayer("group")
+	m.AddLayer(ground)
+	m.AddLayer(group)
+	group.AddLayer(objects)
+	if ground.ID != 1 || group.ID != 2 || objects.ID != 3 || m.NextLayerId != 4 {
+		t.Errorf("layer IDs are %d, %d, %d with next %d", ground.ID, group.ID, objects.ID, m.NextLayerId)
+	}
+	if ground.cache != m.cache {
+		t.Errorf("layer does not share the cache of the map")
+	}
+
+	// The map is written and read the same as one read from a file
+	m.Properties["title"] = Property{Name: "title", Type: TypeString, Value: "new"}
+	for _, format := range []Format{FormatXML, FormatJSON} {
+		got, _ := roundTrip(t, m, format)
+		compareMaps(t, m, got)
+	}
+}
+
+func TestNewLayers(t *testing.T) {
+	image := &Image{Source: "sky.png", Size: Size{Width: 64, Height: 32}}
+	layers := []struct {
+		layer Layer
+		kind  LayerType
+		name  string
+	}{
+		{NewTileLayer("tiles", Size{Width: 3, Height: 2}), LayerTile, "tiles"},
+		{NewObjectLayer("objects"), LayerObject, "objects"},
+		{NewImageLayer("image", image), LayerImage, "image"},
+		{NewGroupLayer("group"), LayerGroup, "group"},
+	}
+	for _, test := range layers {
+		base := test.layer.base()
+		if test.layer.Type() != test.kind || base.Name != test.name || base.ID != 0 {
+			t.Errorf("%s: layer is %v %q with ID %d", test.name, test.layer.Type(), base.Name, base.ID)
+		}
+		if base.Opacity != 1 || !base.Visible || base.Parallax != (Vec2{1, 1}) || base.Offset != (Vec2{}) {
+			t.Errorf("%s: layer has unexpected defaults: %+v", test.name, base)
+		}
+		if test.layer.Map() != nil || test.layer.Container() != nil {
+			t.Errorf("%s: new layer is within a container", test.name)
+		}
+	}
+
+	tiles := layers[0].layer.(*TileLayer)
+	if tiles.Size != (Size{3, 2}) || len(tiles.Tiles) != 6 || tiles.Encoding != EncodingCSV {
+		t.Errorf("tile layer is %v with %d tiles, encoded as %v", tiles.Size, len(tiles.Tiles), tiles.Encoding)
+	}
+	if objects := layers[1].layer.(*ObjectLayer); objects.DrawOrder != DrawTopDown || len(objects.Objects) != 0 {
+		t.Errorf("object layer has draw order %v and %d objects", objects.DrawOrder, len(objects.Objects))
+	}
+	if layer := layers[2].layer.(*ImageLayer); layer.Image != image {
+		t.Errorf("image is %v", layer.Image)
+	}
+	if group := layers[3].layer.(*GroupLayer); group.Len() != 0 || group.Head() != nil {
+		t.Errorf("group has %d layers", group.Len())
+	}
+}
+
+func TestNewTileset(t *testing.T) {
+	tests := []struct {
+		name            string
+		image           *Image
+		spacing, margin int
+		columns, count  int
+	}{
+		{"plain", &Image{Size: Size{Width: 64, Height: 48}}, 0, 0, 4, 12},
+		{"spaced", &Image{Size: Size{Width: 72, Height: 54}}, 2, 1, 4, 12},
+		{"partial", &Image{Size: Size{Width: 40, Height: 20}}, 0, 0, 2, 2},
+		{"small", &Image{Size: Size{Width: 8, Height: 8}}, 0, 0, 0, 0},
+		{"collection", nil, 0, 0, 0, 0},
+	}
+	for _, test := range tests {
+		ts := NewTileset(test.name, Size{Width: 16, Height: 16}, test.image, test.spacing, test.margin)
+		if ts.Name != test.name || ts.Version != formatVersion || ts.Image != test.image ||
+			ts.Spacing != test.spacing || ts.Margin != test.margin {
+			t.Errorf("%s: tileset is %+v", test.name, ts)
+		}
+		if ts.Columns != test.columns || ts.Count != test.count {
+			t.Errorf("%s: tileset has %d columns and %d tiles, want %d and %d", test.name,
+				ts.Columns, ts.Count, test.columns, test.count)
+		}
+	}
+}
+
+func TestAddTileset(t *testing.T) {
+	m := NewMap(Orthogonal, Size{Width: 4, Height: 4}, Size{Width: 16, Height: 16})
+	image := &Image{Source: "terrain.png", Size: Size{Width: 64, Height: 32}}
+	m.AddTileset(NewTileset("first", Size{Width: 16, Height: 16}, image, 0, 0))
+	second := m.AddTileset(NewTileset("second", Size{Width: 16, Height: 16}, image, 0, 0))
+
+	// Image collections occupy IDs up to their highest tile
+	collection := NewTileset("collection", Size{Width: 16, Height: 16}, nil, 0, 0)
+	collection.Tiles = []Tile{{ID: 0, Tileset: collection}, {ID: 5, Tileset: collection}}
+	collection.Count = len(collection.Tiles)
+	third := m.AddTileset(collection)
+	m.AddTileset(NewTileset("last", Size{Width: 16, Height: 16}, image, 0, 0))
+
+	for i, want := range []TileID{1, 9, 17, 23} {
+		if ts := m.Tilesets[i]; ts.FirstGID != want || ts.Map != m || !ts.Embedded {
+			t.Errorf("%s: first GID is %d, want %d", ts.Name, ts.FirstGID, want)
+		}
+	}
+	if ts, id := m.Tileset(10); ts != second.Tileset || id != 1 {
+		t.Errorf("GID 10 is tile %d of %v", id, ts)
+	}
+	if ts, id := m.Tileset(22); ts != third.Tileset || id != 5 {
+		t.Errorf("GID 22 is tile %d of %v", id, ts)
+	}
+	if tile := third.Tile(5); tile == nil || tile.ID != 5 {
+		t.Errorf("tile 5 is %v", tile)
+	}
+	if tile := third.Tile(3); tile != nil {
+		t.Errorf("undefined tile is %v", tile)
+	}
+}
+
+// tilesetMap returns a map using three tilesets of four tiles each, with a tile layer and an
+// object using tiles of each.
+func tilesetMap() *Map {
+	m := NewMap(Orthogonal, Size{Width: 7, Height: 1}, Size{Width: 16, Height: 16})
+	for _, name := range []string{"a", "b", "c"} {
+		image := &Image{Source: name + ".png", Size: Size{Width: 32, Height: 32}}
+		m.AddTileset(NewTileset(name, Size{Width: 16, Height: 16}, image, 0, 0))
+	}
+	layer := NewTileLayer("ground", m.Size)
+	copy(layer.Tiles, []TileID{1, 5, 6 | FlipH, 8, 10 | FlipD | FlipV, 0, 12 | FlipH})
+	m.AddLayer(layer)
+	objects := NewObjectLayer("objects")
+	m.AddLayer(objects)
+	objects.AddObject(Object{Name: "b", GID: 7 | FlipV})
+	objects.AddObject(Object{Name: "c", GID: 11 | FlipH})
+	return m
+}
+
+// checkGIDs reports when the tiles of the layer and objects of tilesetMap are not as expected.
+func checkGIDs(t *testing.T, m *Map, tiles []TileID, objects []TileID) {
+	t.Helper()
+
+	if got := m.TileLayers[0].Tiles; !slices.Equal(got, tiles) {
+		t.Errorf("tiles are %v, want %v", got, tiles)
+	}
+	for i, obj := range m.ObjectLayers[0].Objects {
+		if obj.GID != objects[i] {
+			t.Errorf("object %q has GID %d, want %d", obj.Name, obj.GID, objects[i])
+		}
+	}
+}
+
+func TestRemoveTileset(t *testing.T) {
+	m := tilesetMap()
+	a, b, c := m.Tilesets[0], m.Tilesets[1], m.Tilesets[2]
+	if err := m.RemoveTileset(b, map[TileID]*Tile{1: a.Tile(2), 2: c.Tile(3)}); err != nil {
+		t.Fatal(err)
+	}
+	if len(m.Tilesets) != 2 || m.Tilesets[1] != c || c.FirstGID != 5 || b.Map != nil {
+		t.Fatalf("tilesets are %v, with first GID %d", m.Tilesets, c.FirstGID)
+	}
+
+	// Mapped tiles move to other tilesets, unmapped ones are cleared, and later tilesets are
+	// renumbered
+	checkGIDs(t, m, []TileID{1, 0, 3 | FlipH, 0, 6 | FlipD | FlipV, 0, 8 | FlipH}, []TileID{8 | FlipV, 7 | FlipH})
+
+	if err := m.RemoveTileset(b, nil); !errors.Is(err, ErrTilesetNotFound) {
+		t.Errorf("error is %v, want %v", err, ErrTilesetNotFound)
+	}
+
+	// Removing the first tileset without a remap clears its tiles
+	if err := m.RemoveTileset(a, nil); err != nil {
+		t.Fatal(err)
+	}
+	checkGIDs(t, m, []TileID{0, 0, 0, 0, 2 | FlipD | FlipV, 0, 4 | FlipH}, []TileID{4 | FlipV, 3 | FlipH})
+}
+
+func TestReplaceTileset(t *testing.T) {
+	m := tilesetMap()
+	b, c := m.Tilesets[1], m.Tilesets[2]
+	image := &Image{Source: "small.png", Size: Size{Width: 32, Height: 16}}
+	small := NewTileset("small", Size{Width: 16, Height: 16}, image, 0, 0)
+	if err := m.ReplaceTileset(b, small, map[TileID]TileID{0: 1, 2: 0}); err != nil {
+		t.Fatal(err)
+	}
+	if b.Tileset != small || b.FirstGID != 5 || c.FirstGID != 7 || !b.Embedded {
+		t.Fatalf("tilesets are %v, with first GIDs %d and %d", m.Tilesets, b.FirstGID, c.FirstGID)
+	}
+
+	// Tiles use the mapped ID, or the same ID, and are cleared when it is not in the new tileset
+	checkGIDs(t, m, []TileID{1, 6, 6 | FlipH, 0, 8 | FlipD | FlipV, 0, 10 | FlipH}, []TileID{5 | FlipV, 9 | FlipH})
+
+	other := &MapTileset{Tileset: small}
+	if err := m.ReplaceTileset(other, small, nil); !errors.Is(err, ErrTilesetNotFound) {
+		t.Errorf("error is %v, want %v", err, ErrTilesetNotFound)
+	}
+
+	// Adding a tileset that is already used returns the existing one
+	if ts := m.AddTileset(small); ts != b || len(m.Tilesets) != 3 {
+		t.Errorf("tileset was added again")
+	}
+}
+
+func TestRemapTemplates(t *testing.T) {
+	files := maps.Clone(templateFiles)
+	files["level.tmx"] = `<?xml version="1.0" encoding="UTF-8"?>
+<map version="1.10" orientation="orthogonal" width="2" height="2" tilewidth="16" tileheight="16" nextlayerid="2" nextobjectid="3">
+ <tileset firstgid="1" name="first" tilewidth="16" tileheight="16" tilecount="4" columns="2">
+  <image source="first.png" width="32" height="32"/>
+ </tileset>
+ <tileset firstgid="5" source="terrain.tsx"/>
+ <objectgroup id="1" name="objects">
+  <object id="1" template="chest.tx" x="16" y="16"/>
+  <object id="2" template="chest.tx" gid="2147483656" x="32" y="0"/>
+ </objectgroup>
+</map>
+`
+	m, _ := readTemplateMap(t, files)
+	if err := m.RemoveTileset(m.Tilesets[0], nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// Inherited tiles refer to the tileset of the template, and are unchanged
+	objects := m.ObjectLayers[0].Objects
+	if objects[0].GID != 3 {
+		t.Errorf("inherited GID is %d, want 3", objects[0].GID)
+	}
+	if objects[1].GID != 4|FlipH {
+		t.Errorf("overridden GID is %d, want %d", objects[1].GID, 4|FlipH)
+	}
+}
+
+// vim: ts=4
@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
+	"reflect"
+	"slices"
 	"strconv"
 	"strings"
 )
@@ -43,8 +45,12 @@ type Object struct {
 	// from a template object, as it would otherwise be impossible to determine if a value of
 	// 0, false, "", etc. should be inherited, or it is merely a default.
 	flags setFlags
+	// Extra contains unrecognized data that was retained while reading, see PreserveUnknown.
+	Extra Extra
 	// cache is a reference to the parent map's Cache.
 	cache *Cache
+	// layer is a reference to the object layer the object is within, or nil when it is not.
+	layer *ObjectLayer
 }
 
 // String implements the Stringer interface.
@@ -52,6 +58,252 @@ func (obj *Object) String() string {
 	return fmt.Sprintf(`Object("%s")`, obj.Name)
 }
 
+// MarshalXML implements the xml.Marshaler interface.
+func (obj *Object) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return obj.marshalXML(nil, e, false)
+}
+
+// encodeXML implements the xmlEncoding interface.
+func (obj *Object) encodeXML(enc *encoder, e *xml.Encoder, start xml.StartElement) error {
+	return obj.marshalXML(enc, e, false)
+}
+
+// marshalXML writes the object as an XML element within the document of the encoder. When the
+// object is the base object of a Template, the ID and location are omitted, as they are always
+// defined by the instance.
+//
+// Objects that use a Template only write the values they explicitly set, or that differ from
+// those of the template.
+func (obj *Object) marshalXML(enc *encoder, e *xml.Encoder, base bool) error {
+	tmp := obj.templateObject()
+	start := xml.StartElement{Name: xml.Name{Local: "object"}}
+	if !base {
+		start.Attr = append(start.Attr, xmlInt("id", obj.ID))
+	}
+	if obj.Template != nil {
+		start.Attr = append(start.Attr, xmlStr("template", enc.refPath(obj.Template.Source)))
+	}
+	if obj.writes(flagName, obj.Name == "", obj.Name == tmp.Name) {
+		start.Attr = append(start.Attr, xmlStr("name", obj.Name))
+	}
+	if obj.writes(flagClass, obj.Class == "", obj.Class == tmp.Class) {
+		start.Attr = append(start.Attr, xmlStr("type", obj.Class))
+	}
+	if obj.writes(flagGID, obj.GID == 0, obj.GID == tmp.GID) {
+		start.Attr = append(start.Attr, xmlID("gid", obj.GID))
+	}
+	if !base {
+		start.Attr = append(start.Attr,
+			xmlFloat32("x", obj.Location.X),
+			xmlFloat32("y", obj.Location.Y),
+		)
+	}
+	if obj.writes(flagWidth, obj.Size.X == 0, obj.Size.X == tmp.Size.X) {
+		start.Attr = append(start.Attr, xmlFloat32("width", obj.Size.X))
+	}
+	if obj.writes(flagHeight, obj.Size.Y == 0, obj.Size.Y == tmp.Size.Y) {
+		start.Attr = append(start.Attr, xmlFloat32("height", obj.Size.Y))
+	}
+	if obj.writes(flagRotation, obj.Rotation == 0, obj.Rotation == tmp.Rotation) {
+		start.Attr = append(start.Attr, xmlFloat32("rotation", obj.Rotation))
+	}
+	if obj.writes(flagVisible, obj.Visible, obj.Visible == tmp.Visible) {
+		start.Attr = append(start.Attr, xmlBool("visible", obj.Visible))
+	}
+	start.Attr = obj.Extra.xmlAttrs(start.Attr)
+
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := obj.Extra.marshalXML(e); err != nil {
+		return err
+	}
+	props := obj.ownProperties()
+	if err := e.EncodeElement(enc.xml(props.owned(&obj.Extra)), start); err != nil {
+		return err
+	}
+
+	var err error
+	if obj.writesShape(tmp) {
+		shape := func(name string, attrs ...xml.Attr) error {
+			return xmlEmpty(e, name, obj.Extra.Children[name].xmlAttrs(attrs)...)
+		}
+		switch obj.Type {
+		case ObjectEllipse:
+			err = shape("ellipse")
+		case ObjectPoint:
+			err = shape("point")
+		case ObjectPolygon:
+			err = shape("polygon", xmlStr("points", formatPoints(obj.Points)))
+		case ObjectPolyline:
+			err = shape("polyline", xmlStr("points", formatPoints(obj.Points)))
+		}
+	}
+	if obj.Type == ObjectText && obj.Text != nil && obj.writesText(tmp) {
+		err = obj.Text.marshalXML(e, tmp.Text)
+	}
+	if err != nil {
+		return err
+	}
+
+	return e.EncodeToken(start.End())
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (obj *Object) MarshalJSON() ([]byte, error) {
+	return obj.encodeJSON(nil)
+}
+
+// encodeJSON implements the jsonEncoding interface.
+func (obj *Object) encodeJSON(enc *encoder) ([]byte, error) {
+	return json.Marshal(obj.jsonAttrs(enc, false))
+}
+
+// jsonObjects returns the objects as they are written in the JSON format within the document of
+// the encoder.
+func jsonObjects(enc *encoder, objects []Object) []jsonValue {
+	values := make([]jsonValue, len(objects))
+	for i := range objects {
+		values[i] = enc.json(&objects[i])
+	}
+	return values
+}
+
+// jsonAttrs returns the fields of the object as they are written in the JSON format within the
+// document of the encoder. When the object is the base object of a Template, the ID and location
+// are omitted.
+//
+// Objects that use a Template only write the values they explicitly set, or that differ from
+// those of the template.
+func (obj *Object) jsonAttrs(enc *encoder, base bool) map[string]any {
+	tmp := obj.templateObject()
+	attrs := make(map[string]any)
+	if obj.writes(flagName, false, obj.Name == tmp.Name) {
+		attrs["name"] = obj.Name
+	}
+	if obj.writes(flagClass, false, obj.Class == tmp.Class) {
+		attrs["type"] = obj.Class
+	}
+	if obj.writes(flagWidth, false, obj.Size.X == tmp.Size.X) {
+		attrs["width"] = jsonFloat(obj.Size.X)
+	}
+	if obj.writes(flagHeight, false, obj.Size.Y == tmp.Size.Y) {
+		attrs["height"] = jsonFloat(obj.Size.Y)
+	}
+	if obj.writes(flagRotation, false, obj.Rotation == tmp.Rotation) {
+		attrs["rotation"] = jsonFloat(obj.Rotation)
+	}
+	if obj.writes(flagVisible, false, obj.Visible == tmp.Visible) {
+		attrs["visible"] = obj.Visible
+	}
+	if !base {
+		attrs["id"] = obj.ID
+		attrs["x"] = jsonFloat(obj.Location.X)
+		attrs["y"] = jsonFloat(obj.Location.Y)
+	}
+	if obj.Template != nil {
+		attrs["template"] = enc.refPath(obj.Template.Source)
+	}
+	if obj.writes(flagGID, obj.GID == 0, obj.GID == tmp.GID) {
+		attrs["gid"] = obj.GID
+	}
+	if props := obj.ownProperties(); len(props) > 0 {
+		attrs["properties"] = enc.json(&props)
+	}
+
+	if obj.writesShape(tmp) {
+		switch obj.Type {
+		case ObjectEllipse:
+			attrs["ellipse"] = true
+		case ObjectPoint:
+			attrs["point"] = true
+		case ObjectPolygon:
+			attrs["polygon"] = obj.Points
+		case ObjectPolyline:
+			attrs["polyline"] = obj.Points
+		}
+	}
+	if obj.Type == ObjectText && obj.Text != nil && obj.writesText(tmp) {
+		attrs["text"] = obj.Text.jsonAttrs(tmp.Text)
+	}
+	obj.Extra.jsonAttrs(attrs)
+	return attrs
+}
+
+// templateObject returns the object definition of the template the object uses, or an object
+// with default values when it does not use one.
+func (obj *Object) templateObject() *Object {
+	if obj.Template != nil {
+		return &obj.Template.Object
+	}
+	return &Object{Visible: true}
+}
+
+// writes tests whether a field of the object is written, where isDefault indicates the field
+// has its default value, and isInherited indicates it has the same value as the template.
+//
+// Objects without a template write values that differ from the default, while those with a
+// template write values that were explicitly set or differ from the template.
+func (obj *Object) writes(flag setFlags, isDefault, isInherited bool) bool {
+	if obj.Template == nil {
+		return !isDefault
+	}
+	return obj.flags&flag != 0 || !isInherited
+}
+
+// writesShape tests whether the shape of the object is written.
+func (obj *Object) writesShape(tmp *Object) bool {
+	inherited := obj.Type == tmp.Type && slices.Equal(obj.Points, tmp.Points)
+	return obj.writes(flagKind|flagPoints, false, inherited)
+}
+
+// writesText tests whether the text definition of a text object is written.
+func (obj *Object) writesText(tmp *Object) bool {
+	if obj.Template == nil || tmp.Text == nil {
+		return true
+	}
+	return obj.Text.flags != 0 || !obj.Text.equal(tmp.Text)
+}
+
+// ownProperties returns the properties of the object that are not inherited from its template.
+func (obj *Object) ownProperties() Properties {
+	if obj.Template == nil || len(obj.Template.Properties) == 0 {
+		return obj.Properties
+	}
+
+	props := make(Properties, len(obj.Properties))
+	for name, prop := range obj.Properties {
+		if base, ok := obj.Template.Properties[name]; !ok || !reflect.DeepEqual(prop, base) {
+			props[name] = prop
+		}
+	}
+	return props
+}
+
+// DetachTemplate removes the reference to the template used by the object, with all of the
+// values it inherits from the template becoming values of its own, as with the "Detach"
+// command of the Tiled editor. Has no effect when the object does not use a template.
+//
+// A tile inherited from the template refers to the tileset of the template, and is rewritten to
+// the same tile within the map the object is within, adding the tileset to the map when it does
+// not use it yet. The tile is left unchanged when the object is not within a map.
+func (obj *Object) DetachTemplate() {
+	if obj.Template == nil {
+		return
+	}
+
+	if obj.GID != 0 && obj.flags&flagGID == 0 && obj.Template.Tileset != nil {
+		if m := obj.tilemap(); m != nil {
+			obj.GID = m.importGID(obj.Template.Tileset, obj.GID)
+		}
+	}
+	obj.flags |= obj.Template.flags
+	if obj.Text != nil && obj.Template.Text != nil {
+		obj.Text.flags |= obj.Template.Text.flags
+	}
+	obj.Template = nil
+}
+
 // UnmarshalXML implements the xml.Unmarshaler interface.
 func (obj *Object) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	obj.Visible = true
@@ -126,7 +378,7 @@ func (obj *Object) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 				return err
 			}
 		default:
-			logAttr(attr.Name.Local, start.Name.Local)
+			obj.Extra.attr(attr, start.Name.Local)
 		}
 	}
 
@@ -142,23 +394,25 @@ func (obj *Object) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 
 			case "properties":
 				obj.Properties = make(Properties)
-				if err := obj.Properties.UnmarshalXML(d, next); err != nil {
+				if err := obj.Properties.decodeXML(d, next, &obj.Extra); err != nil {
 					return err
 				}
 			case "point":
 				obj.Type = ObjectPoint
 				obj.flags |= flagKind
+				obj.shapeAttrs(next)
 			case "ellipse":
 				obj.Type = ObjectEllipse
 				obj.flags |= flagKind
+				obj.shapeAttrs(next)
 			case "polygon":
-				if obj.Points, err = parsePoints(next); err != nil {
+				if obj.Points, err = parsePoints(next, &obj.Extra); err != nil {
 					return err
 				}
 				obj.Type = ObjectPolygon
 				obj.flags |= flagPoints | flagKind
 			case "polyline":
-				if obj.Points, err = parsePoints(next); err != nil {
+				if obj.Points, err = parsePoints(next, &obj.Extra); err != nil {
 					return err
 				}
 				obj.Type = ObjectPolyline
@@ -171,9 +425,11 @@ func (obj *Object) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 				obj.Type = ObjectText
 				obj.Text = &text
 				// Merge the flags from the text object
-				obj.flags |= text.flags
+				obj.flags |= text.flags | flagKind
 			default:
-				logElem(next.Name.Local, start.Name.Local)
+				if err := obj.Extra.elem(d, next, start.Name.Local); err != nil {
+					return err
+				}
 			}
 		}
 
@@ -221,12 +477,14 @@ func (obj *Object) UnmarshalJSON(data []byte) error {
 				return err
 			}
 			obj.Type = ObjectPolyline
+			obj.flags |= flagPoints | flagKind
 			continue
 		case "polygon":
 			if err := d.Decode(&obj.Points); err != nil {
 				return err
 			}
 			obj.Type = ObjectPolygon
+			obj.flags |= flagPoints | flagKind
 			continue
 		case "text":
 			var text Text
@@ -235,13 +493,19 @@ func (obj *Object) UnmarshalJSON(data []byte) error {
 			}
 			obj.Text = &text
 			obj.Type = ObjectText
-			obj.flags |= text.flags
+			obj.flags |= text.flags | flagKind
 			continue
-		default:
+		case "id", "name", "gid", "x", "y", "width", "height", "rotation", "type", "class",
+			"visible", "template", "point", "ellipse":
 			// Set the next token for all other properties
 			if token, err = d.Token(); err != nil {
 				return err
 			}
+		default:
+			if err := obj.Extra.prop(d, name, "object"); err != nil {
+				return err
+			}
+			continue
 		}
 
 		switch name {
@@ -283,8 +547,10 @@ func (obj *Object) UnmarshalJSON(data []byte) error {
 			}
 		case "point":
 			obj.Type = ObjectPoint
+			obj.flags |= flagKind
 		case "ellipse":
 			obj.Type = ObjectEllipse
+			obj.flags |= flagKind
 		}
 	}
 
@@ -330,14 +596,17 @@ func (obj *Object) inherit() {
 		obj.Visible = tmp.Visible
 	}
 	if obj.override(flagPoints) {
-		obj.Points = make([]Vec2, 0, len(tmp.Points))
-		copy(obj.Points, tmp.Points)
+		obj.Points = slices.Clone(tmp.Points)
 	}
 
 	if tmp.Text != nil {
 
 		if obj.Text == nil {
-			obj.Text = &Text{}
+			// Inherit the entire definition, none of which was explicitly set by the object
+			text := *tmp.Text
+			text.flags = 0
+			text.Extra = Extra{}
+			obj.Text = &text
 		}
 
 		if obj.override(flagFont) {
@@ -396,7 +665,7 @@ func (obj *Object) inherit() {
 			obj.Text.Align |= tmp.Text.Align & clearHorizontal
 		}
 		if obj.override(flagText) {
-			obj.Text = tmp.Text
+			obj.Text.Value = tmp.Text.Value
 		}
 	}
 
@@ -423,12 +692,21 @@ func (obj *Object) override(flag setFlags) bool {
 	return false
 }
 
-func parsePoints(element xml.StartElement) ([]Vec2, error) {
+// shapeAttrs handles the attributes of an element that defines the shape of the object.
+func (obj *Object) shapeAttrs(element xml.StartElement) {
+	for _, attr := range element.Attr {
+		obj.Extra.child(element.Name.Local).attr(attr, element.Name.Local)
+	}
+}
+
+// parsePoints reads the points of a "polygon" or "polyline" element, retaining its unrecognized
+// attributes within the Extra of the object.
+func parsePoints(element xml.StartElement, owner *Extra) ([]Vec2, error) {
 	var points []Vec2
 	for _, attr := range element.Attr {
 
 		if attr.Name.Local != "points" {
-			logAttr(attr.Name.Local, element.Name.Local)
+			owner.child(element.Name.Local).attr(attr, element.Name.Local)
 			continue
 		}
 
@@ -460,21 +738,31 @@ func parsePoints(element xml.StartElement) ([]Vec2, error) {
 	return points, nil
 }
 
-// Clone creates a deep copy of the Object.
+// formatPoints formats a list of points as they are written in the TMX format.
+func formatPoints(points []Vec2) string {
+	var sb strings.Builder
+	for i, point := range points {
+		if i > 0 {
+			sb.WriteRune(' ')
+		}
+		sb.WriteString(formatFloat(point.X))
+		sb.WriteRune(',')
+		sb.WriteString(formatFloat(point.Y))
+	}
+	return sb.String()
+}
+
+// Clone creates a deep copy of the Object. The copy uses the same Template as the original, and
+// is not within any layer.
 func (obj *Object) Clone() *Object {
 	dup := *obj
+	dup.layer = nil
 	if obj.Text != nil {
-		text := *obj.Text
-		dup.Text = &text
-	}
-	if len(obj.Points) > 0 {
-		dup.Points = make([]Vec2, 0, len(obj.Points))
-		copy(dup.Points, obj.Points)
-	}
-
-	if obj.Properties != nil {
-		dup.Properties = obj.Properties.Clone()
+		dup.Text = obj.Text.Clone()
 	}
+	dup.Points = slices.Clone(obj.Points)
+	dup.Properties = obj.Properties.clone()
+	dup.Extra = obj.Extra.clone()
 	return &dup
 }
 
@@ -1,10 +1,16 @@
 package tmx
 
 import (
+	"encoding/json"
 	"encoding/xml"
+	"errors"
+	"slices"
 	"strconv"
 )
 
+// ErrObjectNotFound is returned when an object with a given ID cannot be found.
+var ErrObjectNotFound = errors.New("object not found")
+
 // ObjectLayer is a map layer that contains map objects.
 type ObjectLayer struct {
 	baseLayer
@@ -14,11 +20,70 @@ type ObjectLayer struct {
 	DrawOrder DrawOrder
 	// Objects is the collection of objects to be rendered in this layer.
 	Objects []Object
+	// indexes are the spatial indexes that contain the objects of the layer.
+	indexes []*ObjectIndex
+}
+
+// NewObjectLayer creates a new empty object layer with the given name. The layer is assigned an
+// ID when it is added to a map.
+func NewObjectLayer(name string) *ObjectLayer {
+	var layer ObjectLayer
+	layer.initDefaults(LayerObject)
+	layer.Name = name
+	return &layer
+}
+
+// MarshalXML implements the xml.Marshaler interface.
+func (layer *ObjectLayer) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return layer.encodeXML(nil, e, start)
+}
+
+// encodeXML implements the xmlEncoding interface.
+func (layer *ObjectLayer) encodeXML(enc *encoder, e *xml.Encoder, start xml.StartElement) error {
+	start = xml.StartElement{Name: xml.Name{Local: "objectgroup"}, Attr: layer.xmlAttrs()}
+	if layer.Color != 0 {
+		start.Attr = append(start.Attr, xmlStr("color", layer.Color.hex()))
+	}
+	if layer.DrawOrder == DrawIndex {
+		start.Attr = append(start.Attr, xmlStr("draworder", layer.DrawOrder.String()))
+	}
+
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := layer.Extra.marshalXML(e); err != nil {
+		return err
+	}
+	if err := e.EncodeElement(enc.xml(layer.Properties.owned(&layer.Extra)), start); err != nil {
+		return err
+	}
+	for i := range layer.Objects {
+		if err := e.EncodeElement(enc.xml(&layer.Objects[i]), start); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (layer *ObjectLayer) MarshalJSON() ([]byte, error) {
+	return layer.encodeJSON(nil)
+}
+
+// encodeJSON implements the jsonEncoding interface.
+func (layer *ObjectLayer) encodeJSON(enc *encoder) ([]byte, error) {
+	attrs := layer.jsonAttrs(enc, LayerObject)
+	attrs["draworder"] = layer.DrawOrder
+	attrs["objects"] = jsonObjects(enc, layer.Objects)
+	if layer.Color != 0 {
+		attrs["color"] = layer.Color.hex()
+	}
+	return json.Marshal(attrs)
 }
 
 // UnmarshalXML implements the xml.Unmarshaler interface.
 func (layer *ObjectLayer) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
-	layer.initDefaults(LayerImage)
+	layer.initDefaults(LayerObject)
 
 	for _, attr := range start.Attr {
 		if handled, err := layer.xmlAttr(attr); err != nil {
@@ -65,7 +130,7 @@ func (layer *ObjectLayer) UnmarshalXML(d *xml.Decoder, start xml.StartElement) e
 				return err
 			}
 		default:
-			logAttr(attr.Value, start.Name.Local)
+			layer.Extra.attr(attr, start.Name.Local)
 		}
 	}
 
@@ -80,18 +145,21 @@ func (layer *ObjectLayer) UnmarshalXML(d *xml.Decoder, start xml.StartElement) e
 			switch next.Name.Local {
 			case "properties":
 				layer.Properties = make(Properties)
-				if err := layer.Properties.UnmarshalXML(d, next); err != nil {
+				if err := layer.Properties.decodeXML(d, next, &layer.Extra); err != nil {
 					return err
 				}
 			case "object":
 				var obj Object
 				obj.cache = layer.cache
+				obj.layer = layer
 				if err := obj.UnmarshalXML(d, next); err != nil {
 					return err
 				}
 				layer.Objects = append(layer.Objects, obj)
 			default:
-				logElem(next.Name.Local, start.Name.Local)
+				if err := layer.Extra.elem(d, next, start.Name.Local); err != nil {
+					return err
+				}
 			}
 		}
 
@@ -101,4 +169,98 @@ func (layer *ObjectLayer) UnmarshalXML(d *xml.Decoder, start xml.StartElement) e
 	return nil
 }
 
+// AddObject appends a copy of an object to the layer and returns it. The object is assigned the
+// next available ID of the parent map, or when the layer is not within a map, when the layer is
+// added to one.
+//
+// The returned pointer refers to an element of Objects, and is only valid until it is modified.
+func (layer *ObjectLayer) AddObject(obj Object) *Object {
+	obj.ID = 0
+	obj.layer = layer
+	if layer.parent != nil {
+		obj.ID = layer.parent.nextObjectID()
+	}
+	layer.Objects = append(layer.Objects, obj)
+	layer.objectInserted(len(layer.Objects) - 1)
+	return &layer.Objects[len(layer.Objects)-1]
+}
+
+// Object returns the object within the layer with the given ID, or nil when it is not found.
+func (layer *ObjectLayer) Object(id int) *Object {
+	if i := layer.objectIndex(id); i >= 0 {
+		return &layer.Objects[i]
+	}
+	return nil
+}
+
+// RemoveObject removes the object with the given ID from the layer, returning false when it is
+// not found. Object properties within the parent map that refer to the object are cleared.
+func (layer *ObjectLayer) RemoveObject(id int) bool {
+	i := layer.objectIndex(id)
+	if i < 0 {
+		return false
+	}
+
+	layer.Objects = slices.Delete(layer.Objects, i, i+1)
+	layer.objectRemoved(i)
+	if layer.parent != nil {
+		layer.parent.unlinkObject(id)
+	} else {
+		layer.unlinkObject(id)
+	}
+	return true
+}
+
+// DuplicateObject creates a copy of the object with the given ID, and inserts it into the layer
+// directly after the original. The copy is assigned the next available ID of the parent map.
+// Returns nil when the object is not found.
+//
+// The returned pointer refers to an element of Objects, and is only valid until it is modified.
+func (layer *ObjectLayer) DuplicateObject(id int) *Object {
+	i := layer.objectIndex(id)
+	if i < 0 {
+		return nil
+	}
+
+	dup := layer.Objects[i].Clone()
+	dup.ID = 0
+	dup.layer = layer
+	if layer.parent != nil {
+		dup.ID = layer.parent.nextObjectID()
+	}
+	layer.Objects = slices.Insert(layer.Objects, i+1, *dup)
+	layer.objectInserted(i + 1)
+	return &layer.Objects[i+1]
+}
+
+// objectIndex returns the index of the object with the given ID, or -1 when it is not found.
+func (layer *ObjectLayer) objectIndex(id int) int {
+	if id <= 0 {
+		return -1
+	}
+	return slices.IndexFunc(layer.Objects, func(obj Object) bool { return obj.ID == id })
+}
+
+// unlinkObject clears the object properties of the layer and its objects that refer to the
+// object with the given ID.
+func (layer *ObjectLayer) unlinkObject(id int) {
+	layer.Properties.unlinkObject(id)
+	for i := range layer.Objects {
+		layer.Objects[i].Properties.unlinkObject(id)
+	}
+}
+
+// Clone creates a deep copy of the ObjectLayer. The copy is not within any map or group.
+func (layer *ObjectLayer) Clone() *ObjectLayer {
+	dup := *layer
+	dup.baseLayer = layer.baseLayer.clone()
+	dup.indexes = nil
+	dup.Objects = make([]Object, len(layer.Objects))
+	for i := range layer.Objects {
+		dup.Objects[i] = *layer.Objects[i].Clone()
+		dup.Objects[i].layer = &dup
+	}
+	return &dup
+}
+
 // vim: ts=4
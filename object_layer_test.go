@@ -0,0 +1,174 @@
+package tmx
+
+import (
+	"testing"
+)
+
+// objectIDs returns the IDs of every object within a map, reporting any that are not unique or
+// not less than NextObjectId.
+func objectIDs(t *testing.T, m *Map) []int {
+	t.Helper()
+
+	var ids []int
+	seen := make(map[int]bool)
+	walkLayers(m, func(layer Layer) {
+		if objects, ok := layer.(*ObjectLayer); ok {
+			for _, obj := range objects.Objects {
+				if seen[obj.ID] || obj.ID <= 0 || obj.ID >= m.NextObjectId {
+					t.Errorf("object %q has invalid ID %d, with next %d", obj.Name, obj.ID, m.NextObjectId)
+				}
+				seen[obj.ID] = true
+				ids = append(ids, obj.ID)
+			}
+		}
+	})
+	return ids
+}
+
+func TestAddObject(t *testing.T) {
+	m := NewMap(Orthogonal, Size{Width: 4, Height: 4}, Size{Width: 16, Height: 16})
+	first := NewObjectLayer("first")
+	m.AddLayer(first)
+
+	// IDs are allocated from the map, ignoring the ID of the given object
+	a := first.AddObject(Object{Name: "a"})
+	b := first.AddObject(Object{ID: a.ID, Name: "b"})
+	if a := first.Object(1); a == nil || a.Name != "a" || b.ID != 2 || m.NextObjectId != 3 {
+		t.Errorf("IDs are %v and %d, with next %d", a, b.ID, m.NextObjectId)
+	}
+
+	// Layers in nested groups share the IDs of the map
+	nested := NewObjectLayer("nested")
+	group := NewGroupLayer("group")
+	m.AddLayer(group)
+	group.AddLayer(nested)
+	if c := nested.AddObject(Object{Name: "c"}); c.ID != 3 {
+		t.Errorf("ID is %d, want 3", c.ID)
+	}
+
+	// Objects of layers that are not within a map are assigned IDs when the layer is added
+	detached := NewObjectLayer("detached")
+	d := detached.AddObject(Object{Name: "d"})
+	detached.AddObject(Object{Name: "e"})
+	if d.ID != 0 {
+		t.Errorf("ID is %d before being added to a map", d.ID)
+	}
+	m.AddLayer(detached)
+	if detached.Objects[0].ID != 4 || detached.Objects[1].ID != 5 || m.NextObjectId != 6 {
+		t.Errorf("IDs are %d and %d, with next %d", detached.Objects[0].ID, detached.Objects[1].ID, m.NextObjectId)
+	}
+
+	// Layers moved from another map are assigned new IDs that do not collide
+	other := NewMap(Orthogonal, Size{Width: 4, Height: 4}, Size{Width: 16, Height: 16})
+	moved := NewObjectLayer("moved")
+	other.AddLayer(moved)
+	moved.AddObject(Object{Name: "f"})
+	moved.AddObject(Object{Name: "g"})
+	if moved.Objects[0].ID != 1 {
+		t.Fatalf("ID is %d", moved.Objects[0].ID)
+	}
+	m.AddLayer(moved)
+	if moved.Objects[0].ID != 6 || moved.Objects[1].ID != 7 || m.NextObjectId != 8 {
+		t.Errorf("IDs are %d and %d, with next %d", moved.Objects[0].ID, moved.Objects[1].ID, m.NextObjectId)
+	}
+	if ids := objectIDs(t, m); len(ids) != 7 {
+		t.Errorf("map has objects %v", ids)
+	}
+}
+
+func TestDuplicateObject(t *testing.T) {
+	m := NewMap(Orthogonal, Size{Width: 4, Height: 4}, Size{Width: 16, Height: 16})
+	layer := NewObjectLayer("objects")
+	m.AddLayer(layer)
+	layer.AddObject(Object{Name: "first"})
+	layer.AddObject(Object{Name: "zone", Type: ObjectPolygon, Points: []Vec2{{0, 0}, {8, 0}, {0, 8}},
+		Properties: Properties{"hp": {Name: "hp", Type: TypeInt, Value: 3}}})
+	layer.AddObject(Object{Name: "last"})
+
+	dup := layer.DuplicateObject(2)
+	if dup == nil || dup.ID != 4 || dup.Name != "zone" || m.NextObjectId != 5 || dup.layer != layer {
+		t.Fatalf("duplicate is %+v", dup)
+	}
+	names := []string{}
+	for _, obj := range layer.Objects {
+		names = append(names, obj.Name)
+	}
+	if len(names) != 4 || layer.Objects[2].ID != 4 || layer.Objects[3].Name != "last" {
+		t.Errorf("objects are %v", names)
+	}
+
+	// The duplicate does not share data with the original
+	dup.Points[0] = Vec2{-1, -1}
+	dup.Properties["hp"] = Property{Name: "hp", Type: TypeInt, Value: 10}
+	if orig := layer.Object(2); orig.Points[0] != (Vec2{}) || orig.Properties["hp"].Value != 3 {
+		t.Errorf("original was changed: %+v", orig)
+	}
+
+	if layer.DuplicateObject(99) != nil || layer.DuplicateObject(0) != nil {
+		t.Errorf("duplicated a missing object")
+	}
+	objectIDs(t, m)
+}
+
+func TestRemoveObject(t *testing.T) {
+	m := NewMap(Orthogonal, Size{Width: 4, Height: 4}, Size{Width: 16, Height: 16})
+	first, nested := NewObjectLayer("first"), NewObjectLayer("nested")
+	group := NewGroupLayer("group")
+	m.AddLayer(first)
+	m.AddLayer(group)
+	group.AddLayer(nested)
+
+	target := first.AddObject(Object{Name: "target"}).ID
+	link := func(id int) Properties {
+		return Properties{
+			"target": {Name: "target", Type: TypeObject, Value: id},
+			"nested": {Name: "nested", Type: TypeClass, Class: "c", Value: Properties{
+				"target": {Name: "target", Type: TypeObject, Value: id},
+			}},
+		}
+	}
+	other := first.AddObject(Object{Name: "other"}).ID
+	nested.AddObject(Object{Name: "linked", Properties: link(target)})
+	nested.AddObject(Object{Name: "unrelated", Properties: link(other)})
+	m.Properties = link(target)
+	group.Properties = link(target)
+
+	if obj, layer := m.FindObjectByID(3); obj == nil || obj.Name != "linked" || layer != nested {
+		t.Errorf("found %v in %v", obj, layer)
+	}
+	if obj, layer := m.FindObjectByID(99); obj != nil || layer != nil {
+		t.Errorf("found %v in %v", obj, layer)
+	}
+
+	if !m.RemoveObject(target) {
+		t.Fatal("object was not removed")
+	}
+	if obj, _ := m.FindObjectByID(target); obj != nil || len(first.Objects) != 1 {
+		t.Errorf("object was not removed")
+	}
+	if m.RemoveObject(target) || m.RemoveObject(0) {
+		t.Errorf("removed a missing object")
+	}
+
+	// Properties referring to the removed object are cleared, and others are kept
+	for name, props := range map[string]Properties{
+		"map": m.Properties, "group": group.Properties, "linked": nested.Objects[0].Properties,
+	} {
+		if props["target"].Value != 0 || props["nested"].Value.(Properties)["target"].Value != 0 {
+			t.Errorf("%s: properties still refer to the object: %v", name, props)
+		}
+	}
+	if props := nested.Objects[1].Properties; props["target"].Value != other ||
+		props["nested"].Value.(Properties)["target"].Value != other {
+		t.Errorf("unrelated properties were changed: %v", props)
+	}
+
+	// Layers that are not within a map only clear their own properties
+	detached := NewObjectLayer("detached")
+	detached.Objects = []Object{{ID: 1}, {ID: 2, Properties: link(1)}}
+	if !detached.RemoveObject(1) || len(detached.Objects) != 1 || detached.Objects[0].Properties["target"].Value != 0 {
+		t.Errorf("objects are %+v", detached.Objects)
+	}
+}
+
+// vim: ts=4
@@ -127,6 +127,117 @@ func DetectContents(reader io.ReadSeeker) Format {
 	return FormatUnknown
 }
 
+// RelativePaths is a global configuration that determines how paths to referenced files, such
+// as tilesets, templates, images, and file properties, are written when saving to a file.
+//
+// By default, each path is rewritten to be relative to the location of the file being written,
+// allowing documents to be saved to a different location than they were read from without
+// breaking their references. When set to false, paths are written as they are stored.
+//
+// As the location of the output is unknown to Encode, it instead writes paths relative to the
+// file the document was read from.
+var RelativePaths = true
+
+// PathRewrite provides a mechanism for users to supply their own logic for how paths to
+// referenced files are written when saving to a file, such as mapping them into an asset bundle
+// namespace. When assigned, it is used instead of the behavior specified by RelativePaths.
+//
+// The function is supplied the absolute path of the referenced file and the absolute path of the
+// directory of the file being written, and returns the value to be written.
+var PathRewrite func(path, dir string) string
+
+// encoder contains the state of a document being written, which is passed to the functions that
+// marshal each of its values. A nil encoder writes paths as they are stored, as when marshaling a
+// value directly.
+//
+// RelativePaths and PathRewrite are captured when a document starts being written, so changing
+// them does not affect documents that are already being written.
+type encoder struct {
+	// src is the directory of the file the document was read from, which relative paths within
+	// it are resolved against. An empty string indicates it is unknown.
+	src string
+	// dst is the directory of the file the document is being written to.
+	dst string
+	// relative is the value of RelativePaths when the document started being written.
+	relative bool
+	// rewrite is the value of PathRewrite when the document started being written.
+	rewrite func(path, dir string) string
+}
+
+// newEncoder returns an encoder for a document being written to the dst directory, where src is
+// the path of the file it was read from, or an empty string when it is unknown.
+func newEncoder(src, dst string) *encoder {
+	enc := &encoder{dst: dst, relative: RelativePaths, rewrite: PathRewrite}
+	if src != "" {
+		enc.src = filepath.Dir(src)
+	}
+	return enc
+}
+
+// nested returns an encoder for a document that is written within the current one, such as an
+// embedded external tileset, where src is the path of the file it was read from. When src is
+// empty, the current source directory is used.
+func (enc *encoder) nested(src string) *encoder {
+	if enc == nil || src == "" {
+		return enc
+	}
+	dup := *enc
+	dup.src = filepath.Dir(src)
+	return &dup
+}
+
+// sourcePath returns the path of the file a document was read from, or an empty string if it
+// is unknown.
+func sourcePath(obj any) string {
+	switch value := obj.(type) {
+	case *Map:
+		return value.Source
+	case *Tileset:
+		return value.Source
+	case *Template:
+		return value.Source
+	default:
+		return ""
+	}
+}
+
+// refPath returns a path referenced by the document being written, as specified by PathRewrite
+// and RelativePaths.
+func (enc *encoder) refPath(path string) string {
+	if path == "" || enc == nil || (enc.rewrite == nil && !enc.relative) {
+		return path
+	}
+
+	abs := path
+	if !filepath.IsAbs(abs) {
+		if enc.src == "" {
+			return path
+		}
+		abs = filepath.Join(enc.src, abs)
+	}
+
+	if enc.rewrite != nil {
+		return enc.rewrite(abs, enc.dst)
+	}
+	if rel, err := filepath.Rel(enc.dst, abs); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return path
+}
+
+// rebasePath returns a relative path that is relative to the file at the src path so that it
+// is instead relative to the file at the dst path. Absolute paths are returned as-is.
+func rebasePath(path, src, dst string) string {
+	if path == "" || src == "" || filepath.IsAbs(path) {
+		return path
+	}
+	abs := filepath.Join(filepath.Dir(src), path)
+	if rel, err := filepath.Rel(filepath.Dir(dst), abs); err == nil {
+		return filepath.ToSlash(rel)
+	}
+	return abs
+}
+
 // getStream finds the given path, returning a reader object, its resolved path, and
 // detected TMX format.
 func getStream(abs string) (reader io.ReadCloser, ft Format, err error) {
@@ -0,0 +1,199 @@
+package tmx
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// setPathOptions changes RelativePaths and PathRewrite for the duration of a test.
+func setPathOptions(t *testing.T, relative bool, rewrite func(path, dir string) string) {
+	prevRelative, prevRewrite := RelativePaths, PathRewrite
+	RelativePaths, PathRewrite = relative, rewrite
+	t.Cleanup(func() { RelativePaths, PathRewrite = prevRelative, prevRewrite })
+}
+
+func TestRelativePaths(t *testing.T) {
+	setPathOptions(t, true, nil)
+	dir := t.TempDir()
+	writeFiles(t, dir, externalFiles)
+	src := filepath.Join(dir, "level.tmx")
+	m, err := ReadMap(src, FormatUnknown, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	obj := &m.ObjectLayers[0].Objects[0]
+
+	// Each document is written to a nested directory, with references made relative to it
+	tests := []struct {
+		name  string
+		write func(path string) error
+		want  []string
+	}{
+		{"out/maps/level.tmx", func(path string) error { return WriteMap(path, FormatUnknown, m) },
+			[]string{`source="../../tiles/terrain.tsx"`, `template="../../templates/chest.tx"`}},
+		{"level.tmx", func(path string) error { return WriteMap(path, FormatUnknown, m) },
+			[]string{`source="tiles/terrain.tsx"`, `template="templates/chest.tx"`}},
+		{"out/level.tmj", func(path string) error { return WriteMap(path, FormatUnknown, m) },
+			[]string{`"source": "../tiles/terrain.tsx"`, `"template": "../templates/chest.tx"`}},
+		{"out/tiles/terrain.tsx", func(path string) error { return WriteTileset(path, FormatUnknown, m.Tilesets[0].Tileset) },
+			[]string{`<image source="../../tiles/terrain.png"`}},
+		{"out/chest.tx", func(path string) error { return WriteTemplate(path, FormatUnknown, obj.Template) },
+			[]string{`source="../tiles/terrain.tsx"`}},
+	}
+	for _, test := range tests {
+		path := filepath.Join(dir, filepath.FromSlash(test.name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := test.write(path); err != nil {
+			t.Fatal(err)
+		}
+		doc := readFile(t, path)
+		for _, want := range test.want {
+			if !strings.Contains(doc, want) {
+				t.Errorf("%s does not contain %s:\n%s", test.name, want, doc)
+			}
+		}
+	}
+
+	// The relocated map still refers to the same files
+	got, err := ReadMap(filepath.Join(dir, "out", "maps", "level.tmx"), FormatUnknown, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Tilesets) != 1 || got.Tilesets[0].Name != "terrain" {
+		t.Errorf("tilesets are %v", got.Tilesets)
+	}
+	if tmpl := got.ObjectLayers[0].Objects[0].Template; tmpl == nil || tmpl.Object.Name != "chest" {
+		t.Errorf("template is %+v", tmpl)
+	}
+}
+
+func TestRelativePathsConcurrent(t *testing.T) {
+	setPathOptions(t, true, nil)
+	dir := t.TempDir()
+	writeFiles(t, dir, externalFiles)
+	m, err := ReadMap(filepath.Join(dir, "level.tmx"), FormatUnknown, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Documents written at the same time to different directories are each relative to their own
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		name := filepath.Join(strings.Repeat("sub/", i), "level.tmx")
+		path := filepath.Join(dir, "out", filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		want := `source="` + strings.Repeat("../", i+1) + `tiles/terrain.tsx"`
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := WriteMap(path, FormatUnknown, m); err != nil {
+				t.Error(err)
+				return
+			}
+			if data, err := os.ReadFile(path); err != nil {
+				t.Error(err)
+			} else if doc := string(data); !strings.Contains(doc, want) {
+				t.Errorf("%s does not contain %s:\n%s", name, want, doc)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestPathRewrite(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, externalFiles)
+	m, err := ReadMap(filepath.Join(dir, "level.tmx"), FormatUnknown, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		relative bool
+		rewrite  func(path, dir string) string
+		want     []string
+	}{
+		{false, nil, []string{`source="` + filepath.Join(dir, "tiles", "terrain.tsx") + `"`,
+			`template="` + filepath.Join(dir, "templates", "chest.tx") + `"`}},
+		{true, func(path, _ string) string { return "bundle:" + filepath.Base(path) },
+			[]string{`source="bundle:terrain.tsx"`, `template="bundle:chest.tx"`}},
+	}
+	for _, test := range tests {
+		setPathOptions(t, test.relative, test.rewrite)
+		path := filepath.Join(dir, "level.tmx")
+		if err := WriteMap(path, FormatUnknown, m); err != nil {
+			t.Fatal(err)
+		}
+		doc := readFile(t, path)
+		for _, want := range test.want {
+			if !strings.Contains(doc, want) {
+				t.Errorf("does not contain %s:\n%s", want, doc)
+			}
+		}
+	}
+}
+
+func TestRelativePathsNested(t *testing.T) {
+	setPathOptions(t, true, nil)
+	dir := t.TempDir()
+	writeFiles(t, dir, externalFiles)
+	m, err := ReadMap(filepath.Join(dir, "level.tmx"), FormatUnknown, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Paths within the embedded tileset are relative to the file it was read from
+	m.Tilesets[0].Embedded = true
+	template := m.ObjectLayers[0].Objects[0].Template
+
+	tests := []struct {
+		name  string
+		write func(path string) error
+		want  []string
+	}{
+		{"out/maps/level.tmx", func(path string) error { return WriteMap(path, FormatUnknown, m) },
+			[]string{`<image source="../../tiles/terrain.png"`, `template="../../templates/chest.tx"`}},
+		{"out/maps/level.tmj", func(path string) error { return WriteMap(path, FormatUnknown, m) },
+			[]string{`"image": "../../tiles/terrain.png"`, `"template": "../../templates/chest.tx"`}},
+		{"out/templates/deep/chest.tx", func(path string) error { return WriteTemplate(path, FormatUnknown, template) },
+			[]string{`source="../../../tiles/terrain.tsx"`}},
+		{"out/templates/deep/chest.tj", func(path string) error { return WriteTemplate(path, FormatUnknown, template) },
+			[]string{`"source": "../../../tiles/terrain.tsx"`}},
+	}
+	for _, test := range tests {
+		path := filepath.Join(dir, filepath.FromSlash(test.name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := test.write(path); err != nil {
+			t.Fatal(err)
+		}
+		doc := readFile(t, path)
+		for _, want := range test.want {
+			if !strings.Contains(doc, want) {
+				t.Errorf("%s does not contain %s:\n%s", test.name, want, doc)
+			}
+		}
+	}
+}
+
+func TestPathOptionsCaptured(t *testing.T) {
+	setPathOptions(t, true, nil)
+	dir := t.TempDir()
+	enc := newEncoder(filepath.Join(dir, "level.tmx"), filepath.Join(dir, "out"))
+
+	// Changing the options does not affect a document that is already being written
+	setPathOptions(t, false, func(path, dir string) string { return "bundle:" + path })
+	if got := enc.nested(filepath.Join(dir, "tiles", "terrain.tsx")).refPath("terrain.png"); got != "../tiles/terrain.png" {
+		t.Errorf("path is %s, want ../tiles/terrain.png", got)
+	}
+}
+
+// vim: ts=4
@@ -3,6 +3,7 @@ package tmx
 import (
 	"encoding/json"
 	"encoding/xml"
+	"sort"
 	"strings"
 )
 
@@ -12,6 +13,19 @@ type Properties map[string]Property
 
 // UnmarshalXML implements the xml.Unmarshaler interface.
 func (p *Properties) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	return p.decodeXML(d, start, nil)
+}
+
+// decodeXML reads the properties from a "properties" element, retaining unrecognized data of the
+// element within the Extra of the type that owns the properties, which may be nil.
+func (p *Properties) decodeXML(d *xml.Decoder, start xml.StartElement, owner *Extra) error {
+	if *p == nil {
+		*p = make(Properties)
+	}
+	for _, attr := range start.Attr {
+		owner.child(start.Name.Local).attr(attr, start.Name.Local)
+	}
+
 	token, err := d.Token()
 	for token != start.End() {
 		if err != nil {
@@ -19,7 +33,9 @@ func (p *Properties) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error
 		}
 		if child, ok := token.(xml.StartElement); ok {
 			if child.Name.Local != "property" {
-				logElem(child.Name.Local, start.Name.Local)
+				if err = owner.child(start.Name.Local).elem(d, child, start.Name.Local); err != nil {
+					return err
+				}
 			} else {
 				var prop Property
 				if err = prop.UnmarshalXML(d, child); err != nil {
@@ -34,6 +50,89 @@ func (p *Properties) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error
 	return nil
 }
 
+// MarshalXML implements the xml.Marshaler interface.
+//
+// Properties are written in alphabetical order by name. Nothing is written when empty.
+func (p *Properties) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return p.encodeXML(nil, e, start)
+}
+
+// encodeXML implements the xmlEncoding interface.
+func (p *Properties) encodeXML(enc *encoder, e *xml.Encoder, start xml.StartElement) error {
+	return p.marshalXML(enc, e, nil)
+}
+
+// marshalXML writes the properties as a "properties" element, along with the data retained
+// from the element within the Extra of the type that owns the properties, which may be nil.
+func (p *Properties) marshalXML(enc *encoder, e *xml.Encoder, owner *Extra) error {
+	var extra *Extra
+	if owner != nil {
+		extra = owner.Children["properties"]
+	}
+	if len(*p) == 0 && (extra == nil || extra.IsEmpty()) {
+		return nil
+	}
+
+	start := xml.StartElement{Name: xml.Name{Local: "properties"}}
+	start.Attr = extra.xmlAttrs(start.Attr)
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := extra.marshalXML(e); err != nil {
+		return err
+	}
+	for _, name := range p.names() {
+		if err := (*p)[name].marshalXML(enc, e, "value"); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// ownedProperties pairs properties with the Extra of the type that owns them, so that they are
+// written along with the data retained from their element.
+type ownedProperties struct {
+	props *Properties
+	owner *Extra
+}
+
+// encodeXML implements the xmlEncoding interface.
+func (p ownedProperties) encodeXML(enc *encoder, e *xml.Encoder, start xml.StartElement) error {
+	return p.props.marshalXML(enc, e, p.owner)
+}
+
+// owned returns a value that writes the properties along with the data retained from their
+// element within the Extra of the type that owns them.
+func (p *Properties) owned(owner *Extra) ownedProperties {
+	return ownedProperties{props: p, owner: owner}
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+//
+// Properties are written as an array, in alphabetical order by name.
+func (p *Properties) MarshalJSON() ([]byte, error) {
+	return p.encodeJSON(nil)
+}
+
+// encodeJSON implements the jsonEncoding interface.
+func (p *Properties) encodeJSON(enc *encoder) ([]byte, error) {
+	props := make([]jsonValue, 0, len(*p))
+	for _, name := range p.names() {
+		props = append(props, enc.json((*p)[name]))
+	}
+	return json.Marshal(props)
+}
+
+// marshalValues returns the values of the properties keyed by name, as custom class values are
+// written in the JSON format.
+func (p Properties) marshalValues(enc *encoder) map[string]any {
+	values := make(map[string]any, len(p))
+	for name, prop := range p {
+		values[name] = prop.marshalValue(enc)
+	}
+	return values
+}
+
 // UnmarshalJSON implements the json.Unmarshaler interface
 func (p *Properties) UnmarshalJSON(data []byte) error {
 	var props []Property
@@ -66,6 +165,47 @@ func (p Properties) String() string {
 	return sb.String()
 }
 
+// rebase updates the relative paths of file properties from being relative to the file at the
+// src path to being relative to the file at the dst path.
+func (p Properties) rebase(src, dst string) {
+	for name, prop := range p {
+		switch value := prop.Value.(type) {
+		case string:
+			if prop.Type == TypeFile {
+				prop.Value = rebasePath(value, src, dst)
+				p[name] = prop
+			}
+		case Properties:
+			value.rebase(src, dst)
+		}
+	}
+}
+
+// unlinkObject clears the values of object properties that refer to the object with the given ID.
+func (p Properties) unlinkObject(id int) {
+	for name, prop := range p {
+		switch value := prop.Value.(type) {
+		case int:
+			if prop.Type == TypeObject && value == id {
+				prop.Value = 0
+				p[name] = prop
+			}
+		case Properties:
+			value.unlinkObject(id)
+		}
+	}
+}
+
+// names returns the names of all properties in alphabetical order.
+func (p Properties) names() []string {
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 func propValue[T any](p Properties, name string) (value T, ok bool) {
 	if p == nil {
 		return
@@ -197,4 +337,12 @@ func (p Properties) Merge(other Properties, deep bool) {
 	}
 }
 
+// clone creates a deep copy of the properties, or returns nil when they are nil.
+func (p Properties) clone() Properties {
+	if p == nil {
+		return nil
+	}
+	return p.Clone()
+}
+
 // vim: ts=4
@@ -20,6 +20,8 @@ type Property struct {
 	Class string
 	// Value is the untyped value of the property.
 	Value interface{}
+	// Extra contains unrecognized data that was retained while reading, see PreserveUnknown.
+	Extra Extra
 }
 
 // String implements the Stringer interface.
@@ -37,6 +39,115 @@ func (p Property) String() string {
 	return fmt.Sprint(p.Value)
 }
 
+// MarshalXML implements the xml.Marshaler interface.
+func (p Property) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return p.marshalXML(nil, e, "value")
+}
+
+// marshalXML writes the property within the document of the encoder, using the given attribute
+// name for its value.
+func (p Property) marshalXML(enc *encoder, e *xml.Encoder, valueAttr string) error {
+	start := xml.StartElement{Name: xml.Name{Local: "property"}}
+	start.Attr = append(start.Attr, xmlStr("name", p.Name))
+	if p.Type != TypeString && p.Type.IsValid() {
+		start.Attr = append(start.Attr, xmlStr("type", p.Type.String()))
+	}
+	if p.Class != "" {
+		start.Attr = append(start.Attr, xmlStr("propertytype", p.Class))
+	}
+
+	class, isClass := p.Value.(Properties)
+	if !isClass {
+		value := p.valueString()
+		if p.Type == TypeFile {
+			value = enc.refPath(value)
+		}
+		start.Attr = append(start.Attr, xmlStr(valueAttr, value))
+	}
+	start.Attr = p.Extra.xmlAttrs(start.Attr)
+
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := p.Extra.marshalXML(e); err != nil {
+		return err
+	}
+	if isClass {
+		if err := e.EncodeElement(enc.xml(class.owned(&p.Extra)), start); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (p Property) MarshalJSON() ([]byte, error) {
+	return p.encodeJSON(nil)
+}
+
+// encodeJSON implements the jsonEncoding interface.
+func (p Property) encodeJSON(enc *encoder) ([]byte, error) {
+	obj := map[string]any{
+		"name":  p.Name,
+		"type":  TypeString,
+		"value": p.marshalValue(enc),
+	}
+	if p.Type.IsValid() {
+		obj["type"] = p.Type
+	}
+	if p.Class != "" {
+		obj["propertytype"] = p.Class
+	}
+	p.Extra.jsonAttrs(obj)
+	return json.Marshal(obj)
+}
+
+// marshalValue returns the value of the property as it is written in the JSON format within the
+// document of the encoder.
+func (p Property) marshalValue(enc *encoder) any {
+	switch value := p.Value.(type) {
+	case nil:
+		return ""
+	case Properties:
+		return value.marshalValues(enc)
+	case Color:
+		return value.String()
+	case float64:
+		return jsonFloat(value)
+	case float32:
+		return jsonFloat(value)
+	case string:
+		if p.Type == TypeFile {
+			return enc.refPath(value)
+		}
+		return value
+	default:
+		return value
+	}
+}
+
+// valueString returns the value of the property formatted as a string.
+func (p Property) valueString() string {
+	switch value := p.Value.(type) {
+	case nil:
+		return ""
+	case string:
+		return value
+	case bool:
+		return strconv.FormatBool(value)
+	case int:
+		return strconv.Itoa(value)
+	case float64:
+		return formatFloat(value)
+	case float32:
+		return formatFloat(value)
+	case Color:
+		return value.String()
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
 // UnmarshalXML implements the xml.Unmarshaler interface.
 func (p *Property) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	for _, attr := range start.Attr {
@@ -83,7 +194,7 @@ func (p *Property) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 				p.Value = attr.Value
 			}
 		default:
-			logAttr(attr.Name.Local, start.Name.Local)
+			p.Extra.attr(attr, start.Name.Local)
 		}
 	}
 
@@ -95,7 +206,9 @@ func (p *Property) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 
 		if child, ok := token.(xml.StartElement); ok {
 			if child.Name.Local != "properties" {
-				logElem(child.Name.Local, start.Name.Local)
+				if err = p.Extra.elem(d, child, start.Name.Local); err != nil {
+					return err
+				}
 			} else {
 				var props Properties
 				// Initialize to default class if defined...
@@ -109,7 +222,7 @@ func (p *Property) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 					props = make(Properties)
 				}
 				// Default values will get overwritten if defined
-				if err = props.UnmarshalXML(d, child); err != nil {
+				if err = props.decodeXML(d, child, &p.Extra); err != nil {
 					return err
 				}
 				p.Value = props
@@ -165,8 +278,9 @@ func (p *Property) UnmarshalJSON(data []byte) error {
 				p.Value = value
 			}
 		default:
-			logProp(name, "property")
-			jsonSkip(d)
+			if err := p.Extra.prop(d, name, "property"); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -259,6 +373,7 @@ func (p Property) jsonClass(d *json.Decoder, class string) (Properties, error) {
 			}
 		} else {
 			// Get value without a known type
+			prop.Name = name
 			prop.Type = -1
 			prop.Value, err = prop.jsonValue(d)
 			if err != nil {
@@ -309,6 +424,7 @@ func (p Property) Clone() Property {
 	if class, ok := p.Value.(Properties); ok {
 		dup.Value = class.Clone()
 	}
+	dup.Extra = p.Extra.clone()
 	return dup
 }
 
@@ -0,0 +1,100 @@
+package tmx
+
+// Resize changes the size of the map in tile units, as with the "Resize Map" command of the Tiled
+// editor. The offset is the position in tile units within the resized map where the previous
+// top-left corner of the map is moved to, which can be negative to crop the map.
+//
+// The tiles of every tile layer are moved by the offset, with tiles falling outside the new
+// bounds being discarded. Objects and image layers are moved by the equivalent distance in pixel
+// units for the orientation of the map. The tiles of infinite maps are moved, but never
+// discarded.
+func (m *Map) Resize(newSize Size, offset Point) {
+	walkLayers(m, func(layer Layer) {
+		if tiles, ok := layer.(*TileLayer); ok {
+			tiles.resize(newSize, offset)
+		}
+	})
+	m.moveObjects(newSize, offset)
+	m.Size = newSize
+	m.reindexObjects()
+}
+
+// moveObjects moves the objects and image layers of the map by the pixel equivalent of the given
+// offset in tile units, for a map that is being resized to the given size.
+func (m *Map) moveObjects(newSize Size, offset Point) {
+	shift := m.objectOffset(offset)
+	imageShift := shift
+	if m.Orientation == Isometric {
+		// Isometric objects are in projected coordinates, but image layers are in screen space,
+		// where the origin also moves horizontally with the height of the map
+		imageShift = Vec2{
+			X: float32((offset.X-offset.Y+newSize.Height-m.Size.Height)*m.TileSize.Width) / 2,
+			Y: float32((offset.X+offset.Y)*m.TileSize.Height) / 2,
+		}
+	}
+
+	walkLayers(m, func(layer Layer) {
+		switch value := layer.(type) {
+		case *ObjectLayer:
+			for i := range value.Objects {
+				obj := &value.Objects[i]
+				obj.Location.X += shift.X
+				obj.Location.Y += shift.Y
+			}
+		case *ImageLayer:
+			value.Offset.X += imageShift.X
+			value.Offset.Y += imageShift.Y
+		}
+	})
+}
+
+// resize changes the size of a tile layer, moving its tiles by the given offset.
+func (layer *TileLayer) resize(newSize Size, offset Point) {
+	if layer.infinite() {
+		chunks := layer.Chunks
+		layer.Chunks = nil
+		for i := range chunks {
+			chunk := &chunks[i]
+			for j, gid := range chunk.Tiles {
+				if gid != 0 {
+					x, y := chunk.X+j%chunk.Width, chunk.Y+j/chunk.Width
+					layer.SetGID(x+offset.X, y+offset.Y, gid)
+				}
+			}
+		}
+		return
+	}
+
+	tiles := make([]TileID, newSize.Area())
+	area := Rect{Point: offset, Size: layer.Size}.Intersect(Rect{Size: newSize})
+	for y := area.Top(); y < area.Bottom(); y++ {
+		for x := area.Left(); x < area.Right(); x++ {
+			tiles[x+(y*newSize.Width)] = layer.Tiles[(x-offset.X)+((y-offset.Y)*layer.Width)]
+		}
+	}
+	layer.Tiles = tiles
+	layer.Size = newSize
+}
+
+// objectOffset returns the distance in the pixel units used by object positions that is
+// equivalent to the given offset in tile units.
+func (m *Map) objectOffset(offset Point) Vec2 {
+	switch m.Orientation {
+	case Isometric:
+		// Objects on isometric maps use the tile height for both axes
+		return Vec2{
+			X: float32(offset.X * m.TileSize.Height),
+			Y: float32(offset.Y * m.TileSize.Height),
+		}
+	case Staggered, Hexagonal:
+		p0, p1 := m.TileToPixel(Point{}), m.TileToPixel(offset)
+		return Vec2{X: p1.X - p0.X, Y: p1.Y - p0.Y}
+	default:
+		return Vec2{
+			X: float32(offset.X * m.TileSize.Width),
+			Y: float32(offset.Y * m.TileSize.Height),
+		}
+	}
+}
+
+// vim: ts=4
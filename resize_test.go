@@ -0,0 +1,125 @@
+package tmx
+
+import (
+	"fmt"
+	"testing"
+)
+
+// numberedLayer adds a tile layer to a map where each tile has a unique ID.
+func numberedLayer(t *testing.T, m *Map) *TileLayer {
+	t.Helper()
+
+	layer := NewTileLayer("tiles", m.Size)
+	m.AddLayer(layer)
+	for i := range layer.Tiles {
+		layer.Tiles[i] = TileID(i + 1)
+	}
+	return layer
+}
+
+func TestResizeTiles(t *testing.T) {
+	tests := []struct {
+		size   Size
+		offset Point
+	}{
+		{Size{Width: 4, Height: 3}, Point{}},
+		{Size{Width: 6, Height: 5}, Point{}},
+		{Size{Width: 6, Height: 5}, Point{X: 2, Y: 1}},
+		{Size{Width: 2, Height: 2}, Point{}},
+		{Size{Width: 2, Height: 2}, Point{X: -1, Y: -1}},
+		{Size{Width: 3, Height: 4}, Point{X: -2, Y: 2}},
+		{Size{Width: 4, Height: 3}, Point{X: 10, Y: 10}},
+	}
+
+	for _, test := range tests {
+		m := NewMap(Orthogonal, Size{Width: 4, Height: 3}, Size{Width: 16, Height: 16})
+		layer := numberedLayer(t, m)
+		old := NewTileLayer("old", layer.Size)
+		copy(old.Tiles, layer.Tiles)
+
+		m.Resize(test.size, test.offset)
+		name := fmt.Sprintf("%v/%v", test.size, test.offset)
+		if m.Size != test.size || layer.Size != test.size || len(layer.Tiles) != test.size.Area() {
+			t.Errorf("%s: map is %v and layer %v with %d tiles", name, m.Size, layer.Size, len(layer.Tiles))
+			continue
+		}
+
+		for y := 0; y < test.size.Height; y++ {
+			for x := 0; x < test.size.Width; x++ {
+				want := old.GetGID(x-test.offset.X, y-test.offset.Y)
+				if got := layer.GetGID(x, y); got != want {
+					t.Errorf("%s: tile at %d,%d is %d, want %d", name, x, y, got, want)
+				}
+			}
+		}
+	}
+}
+
+func TestResizeObjects(t *testing.T) {
+	tests := []struct {
+		orientation Orientation
+		tileSize    Size
+		object      Vec2
+		image       Vec2
+	}{
+		{Orthogonal, Size{Width: 16, Height: 16}, Vec2{X: 32, Y: 16}, Vec2{X: 32, Y: 16}},
+		// Objects use the tile height for both axes, while image layers are in screen space
+		{Isometric, Size{Width: 32, Height: 16}, Vec2{X: 32, Y: 16}, Vec2{X: 32, Y: 24}},
+		{Staggered, Size{Width: 32, Height: 16}, Vec2{X: 80, Y: 8}, Vec2{X: 80, Y: 8}},
+	}
+
+	for _, test := range tests {
+		m := NewMap(test.orientation, Size{Width: 4, Height: 4}, test.tileSize)
+		m.StaggerAxis, m.StaggerIndex = StaggerY, StaggerOdd
+		objects := NewObjectLayer("objects")
+		images := NewImageLayer("image", &Image{Source: "image.png"})
+		m.AddLayer(objects)
+		m.AddLayer(images)
+		obj := objects.AddObject(Object{Location: Vec2{X: 5, Y: 6}})
+
+		m.Resize(Size{Width: 6, Height: 5}, Point{X: 2, Y: 1})
+		want := Vec2{X: 5 + test.object.X, Y: 6 + test.object.Y}
+		if obj.Location != want {
+			t.Errorf("%v: object moved to %v, want %v", test.orientation, obj.Location, want)
+		}
+		if images.Offset != test.image {
+			t.Errorf("%v: image layer moved to %v, want %v", test.orientation, images.Offset, test.image)
+		}
+	}
+}
+
+func TestResizeInfinite(t *testing.T) {
+	// Tiles of infinite maps are moved, but never discarded
+	m := NewMap(Orthogonal, Size{Width: 4, Height: 4}, Size{Width: 16, Height: 16})
+	m.Infinite = true
+	layer := NewTileLayer("tiles", Size{})
+	m.AddLayer(layer)
+	tiles := map[Point]TileID{{X: 0, Y: 0}: 1, {X: -20, Y: 5}: 2, {X: 40, Y: -3}: 3}
+	for p, gid := range tiles {
+		if err := layer.SetGID(p.X, p.Y, gid); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	offset := Point{X: -3, Y: 7}
+	m.Resize(Size{Width: 2, Height: 2}, offset)
+	for p, gid := range tiles {
+		if got := layer.GetGID(p.X+offset.X, p.Y+offset.Y); got != gid {
+			t.Errorf("tile from %v is %d, want %d", p, got, gid)
+		}
+	}
+
+	count := 0
+	for i := range layer.Chunks {
+		for _, gid := range layer.Chunks[i].Tiles {
+			if gid != 0 {
+				count++
+			}
+		}
+	}
+	if count != len(tiles) {
+		t.Errorf("layer has %d tiles, want %d", count, len(tiles))
+	}
+}
+
+// vim: ts=4
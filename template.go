@@ -6,6 +6,7 @@ import (
 	"encoding/xml"
 	"fmt"
 	"io"
+	"os"
 	"path/filepath"
 )
 
@@ -19,12 +20,61 @@ type Template struct {
 	Tileset *MapTileset
 	// Object is the object definition.
 	Object
+	// Extra contains unrecognized data that was retained while reading, see PreserveUnknown.
+	//
+	// This only applies to the template itself, and shadows that of the object definition.
+	Extra Extra
 	// cache maintains a reference to the parent Map cache.
 	cache *Cache
 }
 
+// MarshalXML implements the xml.Marshaler interface.
+func (t *Template) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return t.encodeXML(nil, e, start)
+}
+
+// encodeXML implements the xmlEncoding interface.
+func (t *Template) encodeXML(enc *encoder, e *xml.Encoder, start xml.StartElement) error {
+	start = xml.StartElement{Name: xml.Name{Local: "template"}, Attr: t.Extra.xmlAttrs(nil)}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := t.Extra.marshalXML(e); err != nil {
+		return err
+	}
+	if t.Tileset != nil {
+		if err := e.EncodeElement(enc.xml(t.Tileset), start); err != nil {
+			return err
+		}
+	}
+	if err := t.Object.marshalXML(enc, e, true); err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (t *Template) MarshalJSON() ([]byte, error) {
+	return t.encodeJSON(nil)
+}
+
+// encodeJSON implements the jsonEncoding interface.
+func (t *Template) encodeJSON(enc *encoder) ([]byte, error) {
+	attrs := map[string]any{"object": t.Object.jsonAttrs(enc, true)}
+	if t.Tileset != nil {
+		attrs["tileset"] = enc.json(t.Tileset)
+	}
+	t.Extra.jsonAttrs(attrs)
+	jsonDefaults(attrs, map[string]any{"type": "template"})
+	return json.Marshal(attrs)
+}
+
 // UnmarshalXML implements the xml.Unmarshaler interface.
 func (t *Template) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	for _, attr := range start.Attr {
+		t.Extra.attr(attr, start.Name.Local)
+	}
+
 	token, err := d.Token()
 	for token != start.End() {
 		if err != nil {
@@ -48,7 +98,9 @@ func (t *Template) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 				}
 				t.Tileset = &ts
 			default:
-				logElem(child.Name.Local, start.Name.Local)
+				if err := t.Extra.elem(d, child, start.Name.Local); err != nil {
+					return err
+				}
 			}
 		}
 
@@ -90,9 +142,14 @@ func (t *Template) UnmarshalJSON(data []byte) error {
 				return err
 			}
 			t.Tileset = &ts
+		case "type":
+			if err := t.Extra.skip(d, name); err != nil {
+				return err
+			}
 		default:
-			logProp(name, "template")
-			jsonSkip(d)
+			if err := t.Extra.prop(d, name, "template"); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -145,6 +202,12 @@ func ReadTemplate(path string, format Format, cache *Cache) (*Template, error) {
 	return &template, nil
 }
 
+// WriteTemplate writes a template to a file, using the specified format. When the format is
+// FormatUnknown, it will be detected based on the file extension.
+func WriteTemplate(path string, format Format, template *Template) error {
+	return writeFile(path, format, template, template.Source)
+}
+
 // Decode reads a TMX object from the current position in the reader using
 // the specified format, storing the result to the given pointer.
 func Decode(r io.Reader, format Format, obj any) error {
@@ -166,4 +229,76 @@ func Decode(r io.Reader, format Format, obj any) error {
 	return nil
 }
 
+// Encode writes a TMX object to the writer using the specified format.
+func Encode(w io.Writer, format Format, obj any) error {
+	var enc *encoder
+	if src := sourcePath(obj); src != "" {
+		enc = newEncoder(src, filepath.Dir(src))
+	}
+	return encode(w, format, obj, enc)
+}
+
+// encode writes a TMX object to the writer using the specified format, as the document of the
+// given encoder.
+func encode(w io.Writer, format Format, obj any, enc *encoder) error {
+	switch format {
+	case FormatXML:
+		if value, ok := obj.(xmlEncoding); ok {
+			obj = enc.xml(value)
+		}
+		if _, err := io.WriteString(w, xml.Header); err != nil {
+			return err
+		}
+		e := xml.NewEncoder(w)
+		e.Indent("", " ")
+		if err := e.Encode(obj); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, "\n"); err != nil {
+			return err
+		}
+	case FormatJSON:
+		if value, ok := obj.(jsonEncoding); ok {
+			obj = enc.json(value)
+		}
+		e := json.NewEncoder(w)
+		e.SetIndent("", "    ")
+		e.SetEscapeHTML(false)
+		if err := e.Encode(obj); err != nil {
+			return err
+		}
+	default:
+		return errInvalidEnum("Format", fmt.Sprintf("Format(%d)", format))
+	}
+
+	return nil
+}
+
+// writeFile creates a file at the given path and encodes the TMX object to it, where src is the
+// path of the file the object was read from (if any). When the format is FormatUnknown, it will
+// be detected based on the file extension.
+func writeFile(path string, format Format, obj any, src string) error {
+	if format == FormatUnknown {
+		format = DetectExt(path)
+	}
+	if format != FormatXML && format != FormatJSON {
+		return errInvalidEnum("Format", fmt.Sprintf("Format(%d)", format))
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	file, err := os.Create(abs)
+	if err != nil {
+		return err
+	}
+
+	if err = encode(file, format, obj, newEncoder(src, filepath.Dir(abs))); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
 // vim: ts=4
@@ -0,0 +1,179 @@
+package tmx
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// templateFiles is a map with two instances of a template, one of which overrides some of its
+// values.
+var templateFiles = map[string]string{
+	"level.tmx": `<?xml version="1.0" encoding="UTF-8"?>
+<map version="1.10" orientation="orthogonal" width="2" height="2" tilewidth="16" tileheight="16" nextlayerid="2" nextobjectid="3">
+ <tileset firstgid="1" source="terrain.tsx"/>
+ <objectgroup id="1" name="objects">
+  <object id="1" template="chest.tx" x="16" y="16"/>
+  <object id="2" template="chest.tx" name="renamed" x="32" y="0">
+   <properties>
+    <property name="loot" value="sword"/>
+   </properties>
+  </object>
+ </objectgroup>
+</map>
+`,
+	"terrain.tsx": `<?xml version="1.0" encoding="UTF-8"?>
+<tileset version="1.10" name="terrain" tilewidth="16" tileheight="16" tilecount="4" columns="2">
+ <image source="terrain.png" width="32" height="32"/>
+</tileset>
+`,
+	"chest.tx": `<?xml version="1.0" encoding="UTF-8"?>
+<template>
+ <tileset firstgid="1" source="terrain.tsx"/>
+ <object name="chest" type="container" gid="3" width="16" height="16">
+  <properties>
+   <property name="locked" type="bool" value="true"/>
+   <property name="loot" value="gold"/>
+  </properties>
+ </object>
+</template>
+`,
+}
+
+// readTemplateMap writes files to a temporary directory and reads the map from it, returning the
+// map and the directory.
+func readTemplateMap(t *testing.T, files map[string]string) (*Map, string) {
+	t.Helper()
+
+	dir := t.TempDir()
+	writeFiles(t, dir, files)
+	m, err := ReadMap(filepath.Join(dir, "level.tmx"), FormatUnknown, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return m, dir
+}
+
+func TestTemplateInstance(t *testing.T) {
+	m, dir := readTemplateMap(t, templateFiles)
+	objects := m.ObjectLayers[0].Objects
+	if obj := objects[0]; obj.Name != "chest" || obj.GID != 3 || obj.Size != (Vec2{16, 16}) ||
+		obj.Properties["loot"].Value != "gold" {
+		t.Fatalf("values were not inherited: %+v", obj)
+	}
+	if obj := objects[1]; obj.Name != "renamed" || obj.Class != "container" ||
+		obj.Properties["loot"].Value != "sword" || obj.Properties["locked"].Value != true {
+		t.Fatalf("values were not overridden: %+v", obj)
+	}
+
+	tests := []struct {
+		format    Format
+		name      string
+		want      []string
+		inherited []string
+	}{
+		{FormatXML, "copy.tmx", []string{`<object id="1" template="chest.tx" x="16" y="16"></object>`,
+			`<object id="2" template="chest.tx" name="renamed" x="32" y="0">`, `<property name="loot" value="sword">`},
+			[]string{`"chest"`, `"container"`, `"locked"`, `"gold"`}},
+		{FormatJSON, "copy.tmj", []string{`{"id":1,"template":"chest.tx","x":16,"y":16}`,
+			`{"id":2,"name":"renamed","properties":[`, `"value":"sword"`},
+			[]string{`"chest"`, `"container"`, `"locked"`, `"gold"`}},
+	}
+	for _, test := range tests {
+		doc := encodeString(t, test.format, m)
+		search := doc
+		if test.format == FormatJSON {
+			search = compactJSON(strings.ReplaceAll(doc, `": `, `":`))
+		}
+		for _, want := range test.want {
+			if !strings.Contains(search, want) {
+				t.Errorf("%v: %s was not written:\n%s", test.format, want, doc)
+			}
+		}
+		for _, value := range test.inherited {
+			if strings.Contains(search, value) {
+				t.Errorf("%v: inherited %s was written:\n%s", test.format, value, doc)
+			}
+		}
+
+		// The instances are read back the same
+		path := filepath.Join(dir, test.name)
+		if err := WriteMap(path, test.format, m); err != nil {
+			t.Fatal(err)
+		}
+		got, err := ReadMap(path, FormatUnknown, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		compareObjects(t, m.ObjectLayers[0], got.ObjectLayers[0])
+	}
+}
+
+func TestDetachTemplate(t *testing.T) {
+	m, _ := readTemplateMap(t, templateFiles)
+	obj := &m.ObjectLayers[0].Objects[0]
+	obj.DetachTemplate()
+	if obj.Template != nil || obj.Name != "chest" || obj.GID != 3 || obj.Properties["loot"].Value != "gold" {
+		t.Fatalf("detached object is %+v", obj)
+	}
+
+	// The inherited values are now written as values of the object
+	doc := encodeString(t, FormatXML, m)
+	for _, want := range []string{`<object id="1" name="chest" type="container" gid="3" x="16" y="16" width="16" height="16">`,
+		`<property name="locked" type="bool" value="true">`, `<property name="loot" value="gold">`} {
+		if !strings.Contains(doc, want) {
+			t.Errorf("%s was not written:\n%s", want, doc)
+		}
+	}
+	if strings.Count(doc, `template=`) != 1 {
+		t.Errorf("template was written for the detached object:\n%s", doc)
+	}
+
+	// Detaching an object without a template has no effect
+	obj.DetachTemplate()
+	if obj.Name != "chest" {
+		t.Errorf("name is %q", obj.Name)
+	}
+}
+
+func TestDetachTemplateRemap(t *testing.T) {
+	tests := []struct {
+		name     string
+		tilesets string
+		want     TileID
+		count    int
+	}{
+		{"later tileset", `<tileset firstgid="1" source="other.tsx"/>
+ <tileset firstgid="5" source="terrain.tsx"/>`, 7, 2},
+		{"unused tileset", `<tileset firstgid="1" source="other.tsx"/>`, 7, 2},
+		{"same tileset", `<tileset firstgid="1" source="terrain.tsx"/>`, 3, 1},
+	}
+
+	for _, test := range tests {
+		files := make(map[string]string, len(templateFiles)+1)
+		for name, content := range templateFiles {
+			files[name] = content
+		}
+		files["other.tsx"] = strings.Replace(files["terrain.tsx"], `name="terrain"`, `name="other"`, 1)
+		files["level.tmx"] = strings.Replace(files["level.tmx"], `<tileset firstgid="1" source="terrain.tsx"/>`,
+			test.tilesets, 1)
+		files["chest.tx"] = strings.Replace(files["chest.tx"], `gid="3"`, `gid="2147483651"`, 1)
+
+		m, _ := readTemplateMap(t, files)
+		obj := &m.ObjectLayers[0].Objects[0]
+		obj.DetachTemplate()
+
+		// The tile refers to the same tile of the map, keeping its flags
+		if obj.GID != test.want|FlipH {
+			t.Errorf("%s: GID is %#x, want %#x", test.name, obj.GID, test.want|FlipH)
+		}
+		if len(m.Tilesets) != test.count {
+			t.Fatalf("%s: map has %d tilesets, want %d", test.name, len(m.Tilesets), test.count)
+		}
+		if ts := m.Tilesets[len(m.Tilesets)-1]; ts.Name != "terrain" || (test.count > 1 && ts.FirstGID != 5) {
+			t.Errorf("%s: tileset %q has first GID %d", test.name, ts.Name, ts.FirstGID)
+		}
+	}
+}
+
+// vim: ts=4
@@ -23,10 +23,180 @@ type Text struct {
 	WordWrap bool
 	// Align describes how the alignment of the rendered text.
 	Align Align
+	// Extra contains unrecognized data that was retained while reading, see PreserveUnknown.
+	Extra Extra
 	// flags are used internally to track which fields were explicitly defined.
 	flags setFlags
 }
 
+// hAlign returns the name of the horizontal alignment, as used by the TMX format.
+func (obj *Text) hAlign() string {
+	switch {
+	case obj.Align&AlignJustify != 0:
+		return "justify"
+	case obj.Align&AlignCenterH == AlignCenterH:
+		return "center"
+	case obj.Align&AlignRight != 0:
+		return "right"
+	default:
+		return "left"
+	}
+}
+
+// vAlign returns the name of the vertical alignment, as used by the TMX format.
+func (obj *Text) vAlign() string {
+	switch {
+	case obj.Align&AlignCenterV == AlignCenterV:
+		return "center"
+	case obj.Align&AlignBottom != 0:
+		return "bottom"
+	default:
+		return "top"
+	}
+}
+
+// MarshalXML implements the xml.Marshaler interface.
+//
+// Only values that differ from the defaults are written.
+func (obj *Text) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return obj.marshalXML(e, nil)
+}
+
+// marshalXML writes the text definition as an XML element. When a base definition from a
+// template is given, only values that were explicitly set or differ from it are written.
+func (obj *Text) marshalXML(e *xml.Encoder, base *Text) error {
+	ref := obj.base(base)
+	start := xml.StartElement{Name: xml.Name{Local: "text"}}
+	if obj.writes(base, flagFont, obj.FontFamily == ref.FontFamily) {
+		start.Attr = append(start.Attr, xmlStr("fontfamily", obj.FontFamily))
+	}
+	if obj.writes(base, flagFontSize, obj.PixelSize == ref.PixelSize) {
+		start.Attr = append(start.Attr, xmlInt("pixelsize", obj.PixelSize))
+	}
+	if obj.writes(base, flagTextWrap, obj.WordWrap == ref.WordWrap) {
+		start.Attr = append(start.Attr, xmlBool("wrap", obj.WordWrap))
+	}
+	if obj.writes(base, flagTextColor, obj.Color == ref.Color) {
+		start.Attr = append(start.Attr, xmlStr("color", obj.Color.hex()))
+	}
+	for _, style := range textStyles {
+		if obj.writes(base, style.flag, obj.Style&style.style == ref.Style&style.style) {
+			start.Attr = append(start.Attr, xmlBool(style.name, obj.Style&style.style != 0))
+		}
+	}
+	if align := obj.hAlign(); obj.writes(base, flagHAlign, align == ref.hAlign()) {
+		start.Attr = append(start.Attr, xmlStr("halign", align))
+	}
+	if align := obj.vAlign(); obj.writes(base, flagVAlign, align == ref.vAlign()) {
+		start.Attr = append(start.Attr, xmlStr("valign", align))
+	}
+	start.Attr = obj.Extra.xmlAttrs(start.Attr)
+
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := obj.Extra.marshalXML(e); err != nil {
+		return err
+	}
+	if base == nil || obj.writes(base, flagText, obj.Value == base.Value) {
+		if err := e.EncodeToken(xml.CharData(obj.Value)); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+//
+// Only values that differ from the defaults are written.
+func (obj *Text) MarshalJSON() ([]byte, error) {
+	return json.Marshal(obj.jsonAttrs(nil))
+}
+
+// jsonAttrs returns the fields of the text definition as they are written in the JSON format.
+// When a base definition from a template is given, only values that were explicitly set or
+// differ from it are written.
+func (obj *Text) jsonAttrs(base *Text) map[string]any {
+	ref := obj.base(base)
+	text := make(map[string]any)
+	if base == nil || obj.writes(base, flagText, obj.Value == base.Value) {
+		text["text"] = obj.Value
+	}
+	if obj.writes(base, flagFont, obj.FontFamily == ref.FontFamily) {
+		text["fontfamily"] = obj.FontFamily
+	}
+	if obj.writes(base, flagFontSize, obj.PixelSize == ref.PixelSize) {
+		text["pixelsize"] = obj.PixelSize
+	}
+	if obj.writes(base, flagTextWrap, obj.WordWrap == ref.WordWrap) {
+		text["wrap"] = obj.WordWrap
+	}
+	if obj.writes(base, flagTextColor, obj.Color == ref.Color) {
+		text["color"] = obj.Color.hex()
+	}
+	for _, style := range textStyles {
+		if obj.writes(base, style.flag, obj.Style&style.style == ref.Style&style.style) {
+			text[style.name] = obj.Style&style.style != 0
+		}
+	}
+	if align := obj.hAlign(); obj.writes(base, flagHAlign, align == ref.hAlign()) {
+		text["halign"] = align
+	}
+	if align := obj.vAlign(); obj.writes(base, flagVAlign, align == ref.vAlign()) {
+		text["valign"] = align
+	}
+	obj.Extra.jsonAttrs(text)
+	return text
+}
+
+// textStyles describes how each font style is written.
+var textStyles = []struct {
+	name  string
+	style FontStyle
+	flag  setFlags
+}{
+	{"bold", StyleBold, flagBold},
+	{"italic", StyleItalic, flagItalic},
+	{"underline", StyleUnderline, flagUnderline},
+	{"strikeout", StyleStrikeout, flagStrikeout},
+	{"kerning", StyleKerning, flagKerning},
+}
+
+// defaultText is a text definition with the default values.
+var defaultText = Text{
+	FontFamily: "sans-serif",
+	PixelSize:  16,
+	Color:      0xFF000000,
+	Style:      StyleKerning,
+	Align:      AlignLeft | AlignTop,
+}
+
+// base returns the definition that values are compared against to determine if they are
+// written, which is the given base definition from a template, or the default values.
+func (obj *Text) base(base *Text) *Text {
+	if base != nil {
+		return base
+	}
+	return &defaultText
+}
+
+// writes tests whether a value of the text definition is written, where isBase indicates it has
+// the same value as the definition it is compared against. When there is a base definition from
+// a template, values that were explicitly set are always written.
+func (obj *Text) writes(base *Text, flag setFlags, isBase bool) bool {
+	if base != nil && obj.flags&flag != 0 {
+		return true
+	}
+	return !isBase
+}
+
+// equal tests whether the values of two text definitions are the same.
+func (obj *Text) equal(other *Text) bool {
+	return obj.FontFamily == other.FontFamily && obj.Value == other.Value &&
+		obj.PixelSize == other.PixelSize && obj.Color == other.Color &&
+		obj.Style == other.Style && obj.WordWrap == other.WordWrap && obj.Align == other.Align
+}
+
 // UnmarshalXML implements the xml.Unmarshaler interface.
 func (obj *Text) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	obj.FontFamily = "sans-serif"
@@ -125,7 +295,7 @@ func (obj *Text) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 				if value == AlignCenter {
 					value = AlignCenterH
 				}
-				hAlign |= value
+				hAlign = value
 				obj.flags |= flagHAlign
 			}
 		case "valign":
@@ -135,11 +305,11 @@ func (obj *Text) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 				if value == AlignCenter {
 					value = AlignCenterV
 				}
-				vAlign |= value
+				vAlign = value
 				obj.flags |= flagVAlign
 			}
 		default:
-			logAttr(attr.Name.Local, start.Name.Local)
+			obj.Extra.attr(attr, start.Name.Local)
 		}
 	}
 
@@ -150,7 +320,9 @@ func (obj *Text) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 		}
 
 		if next, ok := token.(xml.StartElement); ok {
-			logElem(next.Name.Local, start.Name.Local)
+			if err := obj.Extra.elem(d, next, start.Name.Local); err != nil {
+				return err
+			}
 		} else if data, ok := token.(xml.CharData); ok {
 			obj.Value = string(data)
 			obj.flags |= flagText
@@ -162,6 +334,7 @@ func (obj *Text) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	return nil
 }
 
+// UnmarshalJSON implements the json.Unmarshaler interface.
 func (obj *Text) UnmarshalJSON(data []byte) error {
 	obj.FontFamily = "sans-serif"
 	obj.PixelSize = 16
@@ -192,8 +365,17 @@ func (obj *Text) UnmarshalJSON(data []byte) error {
 		}
 
 		name := token.(string)
-		if token, err = d.Token(); err != nil {
-			return err
+		switch name {
+		case "pixelsize", "text", "fontfamily", "wrap", "bold", "italic", "underline",
+			"strikeout", "kerning", "color", "halign", "valign":
+			if token, err = d.Token(); err != nil {
+				return err
+			}
+		default:
+			if err := obj.Extra.prop(d, name, "text"); err != nil {
+				return err
+			}
+			continue
 		}
 
 		switch name {
@@ -225,9 +407,9 @@ func (obj *Text) UnmarshalJSON(data []byte) error {
 			obj.flags |= flagItalic
 		case "underline":
 			if token.(bool) {
-				obj.Style |= StyleItalic
+				obj.Style |= StyleUnderline
 			} else {
-				obj.Style &= ^StyleItalic
+				obj.Style &= ^StyleUnderline
 			}
 			obj.flags |= flagUnderline
 		case "strikeout":
@@ -258,7 +440,7 @@ func (obj *Text) UnmarshalJSON(data []byte) error {
 				if value == AlignCenter {
 					value = AlignCenterH
 				}
-				hAlign |= value
+				hAlign = value
 				obj.flags |= flagHAlign
 			}
 		case "valign":
@@ -268,7 +450,7 @@ func (obj *Text) UnmarshalJSON(data []byte) error {
 				if value == AlignCenter {
 					value = AlignCenterV
 				}
-				vAlign |= value
+				vAlign = value
 				obj.flags |= flagVAlign
 			}
 		}
@@ -278,4 +460,11 @@ func (obj *Text) UnmarshalJSON(data []byte) error {
 	return nil
 }
 
+// Clone creates a deep copy of the Text.
+func (t *Text) Clone() *Text {
+	dup := *t
+	dup.Extra = t.Extra.clone()
+	return &dup
+}
+
 // vim: ts=4
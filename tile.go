@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/json"
 	"encoding/xml"
+	"slices"
 	"strconv"
 )
 
@@ -38,6 +39,129 @@ type Tile struct {
 	UV1 Vec2
 	// Tileset is a reference to the parent tilset.
 	Tileset *Tileset
+	// Extra contains unrecognized data that was retained while reading, see PreserveUnknown.
+	Extra Extra
+}
+
+// isDefault tests whether the tile defines any data beyond its ID, and whether it needs to be
+// written when the parent tileset is serialized.
+func (t *Tile) isDefault() bool {
+	return t.Class == "" && t.Probability == 0 && len(t.Properties) == 0 && t.Image == nil &&
+		len(t.Animation) == 0 && t.Collision == nil && t.Extra.IsEmpty()
+}
+
+// hasSubRect tests whether the tile uses a sub-rectangle of its image, as opposed to the
+// image in its entirety.
+func (t *Tile) hasSubRect() bool {
+	if t.Image == nil || t.Image.Width == 0 || t.Image.Height == 0 {
+		return false
+	}
+	return t.X != 0 || t.Y != 0 || t.Width != t.Image.Width || t.Height != t.Image.Height
+}
+
+// MarshalXML implements the xml.Marshaler interface.
+func (t *Tile) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return t.encodeXML(nil, e, start)
+}
+
+// encodeXML implements the xmlEncoding interface.
+func (t *Tile) encodeXML(enc *encoder, e *xml.Encoder, start xml.StartElement) error {
+	start = xml.StartElement{Name: xml.Name{Local: "tile"}}
+	start.Attr = append(start.Attr, xmlID("id", t.ID))
+	if t.Class != "" {
+		start.Attr = append(start.Attr, xmlStr("type", t.Class))
+	}
+	if t.Probability != 0 {
+		start.Attr = append(start.Attr, xmlFloat("probability", t.Probability))
+	}
+	if t.hasSubRect() {
+		start.Attr = append(start.Attr,
+			xmlInt("x", t.X),
+			xmlInt("y", t.Y),
+			xmlInt("width", t.Width),
+			xmlInt("height", t.Height),
+		)
+	}
+	start.Attr = t.Extra.xmlAttrs(start.Attr)
+
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := t.Extra.marshalXML(e); err != nil {
+		return err
+	}
+	if err := e.EncodeElement(enc.xml(t.Properties.owned(&t.Extra)), start); err != nil {
+		return err
+	}
+	if t.Image != nil {
+		if err := e.EncodeElement(enc.xml(t.Image), start); err != nil {
+			return err
+		}
+	}
+	if t.Collision != nil {
+		if err := e.EncodeElement(enc.xml(t.Collision), start); err != nil {
+			return err
+		}
+	}
+	if extra := t.Extra.Children["animation"]; len(t.Animation) > 0 || (extra != nil && !extra.IsEmpty()) {
+		animation := xml.StartElement{Name: xml.Name{Local: "animation"}}
+		animation.Attr = extra.xmlAttrs(animation.Attr)
+		if err := e.EncodeToken(animation); err != nil {
+			return err
+		}
+		if err := extra.marshalXML(e); err != nil {
+			return err
+		}
+		for _, frame := range t.Animation {
+			if err := e.EncodeElement(frame, animation); err != nil {
+				return err
+			}
+		}
+		if err := e.EncodeToken(animation.End()); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (t *Tile) MarshalJSON() ([]byte, error) {
+	return t.encodeJSON(nil)
+}
+
+// encodeJSON implements the jsonEncoding interface.
+func (t *Tile) encodeJSON(enc *encoder) ([]byte, error) {
+	attrs := map[string]any{"id": t.ID}
+	if t.Class != "" {
+		attrs["type"] = t.Class
+	}
+	if t.Probability != 0 {
+		attrs["probability"] = jsonFloat(t.Probability)
+	}
+	if t.hasSubRect() {
+		attrs["x"] = t.X
+		attrs["y"] = t.Y
+		attrs["width"] = t.Width
+		attrs["height"] = t.Height
+	}
+	if len(t.Properties) > 0 {
+		attrs["properties"] = enc.json(&t.Properties)
+	}
+	if t.Image != nil {
+		attrs["image"] = enc.refPath(t.Image.Source)
+		if t.Image.Width != 0 && t.Image.Height != 0 {
+			attrs["imagewidth"] = t.Image.Width
+			attrs["imageheight"] = t.Image.Height
+		}
+	}
+	if t.Collision != nil {
+		attrs["objectgroup"] = enc.json(t.Collision)
+	}
+	if len(t.Animation) > 0 {
+		attrs["animation"] = t.Animation
+	}
+	t.Extra.jsonAttrs(attrs)
+	return json.Marshal(attrs)
 }
 
 // UnmarshalXML implements the xml.Unmarshaler interface.
@@ -84,8 +208,9 @@ func (t *Tile) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 			}
 		case "terrain":
 			logTerrain()
+			t.Extra.attr(attr, start.Name.Local)
 		default:
-			logAttr(attr.Name.Local, start.Name.Local)
+			t.Extra.attr(attr, start.Name.Local)
 		}
 	}
 
@@ -99,7 +224,7 @@ func (t *Tile) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 			switch child.Name.Local {
 			case "properties":
 				t.Properties = make(Properties)
-				if err := t.Properties.UnmarshalXML(d, child); err != nil {
+				if err := t.Properties.decodeXML(d, child, &t.Extra); err != nil {
 					return err
 				}
 			case "image":
@@ -120,7 +245,9 @@ func (t *Tile) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 					return err
 				}
 			default:
-				logElem(child.Name.Local, start.Name.Local)
+				if err := t.Extra.elem(d, child, start.Name.Local); err != nil {
+					return err
+				}
 			}
 		}
 
@@ -235,17 +362,25 @@ func (t *Tile) UnmarshalJSON(data []byte) error {
 			t.Properties = props
 		case "terrain":
 			logTerrain()
-			jsonSkip(d)
+			if err := t.Extra.prop(d, name, "tile"); err != nil {
+				return err
+			}
 		default:
-			logProp(name, "tile")
-			jsonSkip(d)
+			if err := t.Extra.prop(d, name, "tile"); err != nil {
+				return err
+			}
 		}
 	}
 
 	return nil
 }
 
+// readFramesXML reads the frames of the animation of the tile from an "animation" element.
 func (t *Tile) readFramesXML(d *xml.Decoder, start xml.StartElement) error {
+	for _, attr := range start.Attr {
+		t.Extra.child(start.Name.Local).attr(attr, start.Name.Local)
+	}
+
 	token, err := d.Token()
 	for token != start.End() {
 		if err != nil {
@@ -254,7 +389,9 @@ func (t *Tile) readFramesXML(d *xml.Decoder, start xml.StartElement) error {
 
 		if child, ok := token.(xml.StartElement); ok {
 			if child.Name.Local != "frame" {
-				logElem(child.Name.Local, start.Name.Local)
+				if err := t.Extra.child(start.Name.Local).elem(d, child, start.Name.Local); err != nil {
+					return err
+				}
 			} else {
 				var frame Frame
 				if err := frame.UnmarshalXML(d, child); err != nil {
@@ -269,4 +406,22 @@ func (t *Tile) readFramesXML(d *xml.Decoder, start xml.StartElement) error {
 	return nil
 }
 
+// Clone creates a deep copy of the Tile. The copy still refers to the same parent Tileset.
+func (t *Tile) Clone() *Tile {
+	dup := *t
+	dup.Properties = t.Properties.clone()
+	if t.Image != nil {
+		dup.Image = t.Image.Clone()
+	}
+	dup.Animation = slices.Clone(t.Animation)
+	for i := range dup.Animation {
+		dup.Animation[i].Extra = dup.Animation[i].Extra.clone()
+	}
+	if t.Collision != nil {
+		dup.Collision = t.Collision.Clone()
+	}
+	dup.Extra = t.Extra.clone()
+	return &dup
+}
+
 // vim: ts=4
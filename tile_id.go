@@ -41,6 +41,19 @@ const (
 	InvalidID TileID = 0xFFFFFFFF
 )
 
+// MarshalText implements the encoding.TextMarshaler interface.
+func (id TileID) MarshalText() ([]byte, error) {
+	if id == InvalidID {
+		return []byte("-1"), nil
+	}
+	return []byte(strconv.FormatUint(uint64(id), 10)), nil
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (id TileID) MarshalJSON() ([]byte, error) {
+	return id.MarshalText()
+}
+
 // UnmarshalJSON implements the json.Unmarshaler interface.
 func (id *TileID) UnmarshalJSON(data []byte) error {
 	text := string(data)
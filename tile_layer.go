@@ -1,23 +1,53 @@
 package tmx
 
-import "encoding/xml"
+import (
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"slices"
+)
+
+var (
+	// ErrOutOfBounds is returned when a position is outside the bounds of a finite tile layer.
+	ErrOutOfBounds = errors.New("position is outside the bounds of the layer")
+	// ErrTileNotInMap is returned when a tile is placed on a layer whose map does not use the
+	// tileset of the tile.
+	ErrTileNotInMap = errors.New("tileset of the tile is not used by the map")
+	// ErrTileCount is returned when the number of tiles of a layer or chunk does not match its
+	// size.
+	ErrTileCount = errors.New("number of tiles does not match the size")
+	// ErrChunkOverlap is returned when chunks of an infinite tile layer overlap each other.
+	ErrChunkOverlap = errors.New("chunks of the layer overlap")
+)
+
+// defaultChunkSize is the size of the chunks that are allocated for infinite maps when it is not
+// otherwise known, matching the default of the Tiled editor.
+var defaultChunkSize = Size{Width: 16, Height: 16}
 
 // TileLayer describes a map layer that is composed of tile data from a Tileset.
 type TileLayer struct {
 	baseLayer
 	// TileLayer contains the tile/chunk data for the layer.
 	TileData
-	// ChunkSize is the total size of the layer in tile units before it "wraps".
+	// ChunkSize is the size of an individual chunk in tile units, used when new chunks are
+	// allocated. When zero, the size of the existing chunks is used, or 16x16 when there are
+	// none.
 	//
 	// Only valid for infinite maps.
 	ChunkSize Size
+	chunks    chunkIndex
+}
 
-	// chunkRows is the number of rows of chunks.
-	chunkRows int
-	// chunkCols is the number of columns of chunks.
-	chunkCols int
-	// chunkSz is the size of an individual chunk.
-	chunkSz Size
+// NewTileLayer creates a new tile layer with the given name and size in tile units, with all
+// tiles initially empty. The layer is assigned an ID when it is added to a map.
+func NewTileLayer(name string, size Size) *TileLayer {
+	var layer TileLayer
+	layer.initDefaults(LayerTile)
+	layer.Name = name
+	layer.Size = size
+	layer.Encoding = EncodingCSV
+	layer.Tiles = make([]TileID, size.Width*size.Height)
+	return &layer
 }
 
 // GetGID returns a the global tile ID for the specified map coordinates.
@@ -26,14 +56,19 @@ type TileLayer struct {
 // otherwise it must be within the bounds of the map. A zero value will be returned
 // for positions outside the map bounds or when no tile is defined at the given position.
 func (layer *TileLayer) GetGID(x, y int) TileID {
-	if len(layer.Chunks) > 0 {
-		chunk, x, y := layer.ChunkAt(x, y)
-		return chunk.Tiles[x+(y*chunk.Width)]
+	if layer.infinite() {
+		if chunk, cx, cy := layer.ChunkAt(x, y); chunk != nil && len(chunk.Tiles) == chunk.Area() {
+			return chunk.Tiles[cx+(cy*chunk.Width)]
+		}
+		return 0
 	} else if x < 0 || x >= layer.Width || y < 0 || y >= layer.Height {
 		return 0
 	}
 
-	return layer.Tiles[x+(y*layer.Width)]
+	if i := x + (y * layer.Width); i < len(layer.Tiles) {
+		return layer.Tiles[i]
+	}
+	return 0
 }
 
 // TileAt returns the tile and the GID (with flip/rotate bits still set) at the
@@ -44,35 +79,327 @@ func (layer *TileLayer) GetGID(x, y int) TileID {
 // for positions outside the map bounds or when no tile is defined at the given position.
 func (layer *TileLayer) TileAt(x, y int) (*Tile, TileID) {
 	if gid := layer.GetGID(x, y); gid != 0 {
-		if ts, id := layer.parent.Tileset(gid); id > 0 {
-			return &ts.Tiles[id], gid
+		if layer.parent == nil {
+			return nil, 0
+		}
+		if ts, id := layer.parent.Tileset(gid); ts != nil {
+			if tile := ts.Tile(id); tile != nil {
+				return tile, gid
+			}
 		}
 	}
 	return nil, 0
 }
 
-// ChunkAt returns the chunk the Chunk and localized coordinates for the
-// given position. The given values can be positive or negative.
+// ChunkAt returns the chunk containing the given position, and the position localized to the
+// chunk. The given values can be positive or negative.
 //
-// Only valid for infinte maps, otherwise returns nil.
+// Only valid for infinte maps, otherwise returns nil. Also returns nil when no chunk has been
+// allocated for the position.
 func (layer *TileLayer) ChunkAt(x, y int) (*Chunk, int, int) {
-	if len(layer.Chunks) == 0 {
-		return nil, 0, 0
+	if i := layer.chunks.find(layer.Chunks, Point{X: x, Y: y}); i >= 0 {
+		chunk := &layer.Chunks[i]
+		return chunk, x - chunk.X, y - chunk.Y
+	}
+	return nil, 0, 0
+}
+
+// SetGID sets the global tile ID at the specified map coordinates, which may include flip/rotate
+// bits. A value of 0 clears the tile.
+//
+// For infinite maps, the position is unrestricted and a new chunk is allocated when it is not
+// within an existing one. Otherwise ErrOutOfBounds is returned for positions outside the bounds
+// of the layer. ErrTileCount is returned when the number of tiles of the layer or chunk does not
+// match its size.
+func (layer *TileLayer) SetGID(x, y int, gid TileID) error {
+	if !layer.infinite() {
+		if x < 0 || x >= layer.Width || y < 0 || y >= layer.Height {
+			return ErrOutOfBounds
+		}
+		if len(layer.Tiles) != layer.Area() {
+			return ErrTileCount
+		}
+		layer.Tiles[x+(y*layer.Width)] = gid
+		return nil
+	}
+
+	chunk, cx, cy := layer.ChunkAt(x, y)
+	if chunk == nil {
+		if gid == 0 {
+			return nil
+		}
+		chunk, cx, cy = layer.allocChunk(x, y)
+	} else if len(chunk.Tiles) != chunk.Area() {
+		return ErrTileCount
+	}
+	chunk.Tiles[cx+(cy*chunk.Width)] = gid
+	return nil
+}
+
+// SetTile places a tile at the specified map coordinates, with the given flip/rotate flags. The
+// tileset of the tile must be used by the parent map of the layer, otherwise ErrTileNotInMap is
+// returned. A nil tile clears the position.
+func (layer *TileLayer) SetTile(x, y int, tile *Tile, flags TileID) error {
+	if tile == nil {
+		return layer.SetGID(x, y, 0)
+	}
+	if layer.parent == nil {
+		return ErrTileNotInMap
+	}
+	gid := layer.parent.GID(tile)
+	if gid == 0 {
+		return ErrTileNotInMap
+	}
+	return layer.SetGID(x, y, gid|(flags&^ClearMask))
+}
+
+// Clear removes the tile at the specified map coordinates.
+func (layer *TileLayer) Clear(x, y int) error {
+	return layer.SetGID(x, y, 0)
+}
+
+// Fill sets every tile within the given area to the global tile ID. For finite maps, the area
+// is clipped to the bounds of the layer.
+func (layer *TileLayer) Fill(area Rect, gid TileID) error {
+	area = layer.clip(area.Canon())
+	for y := area.Top(); y < area.Bottom(); y++ {
+		for x := area.Left(); x < area.Right(); x++ {
+			if err := layer.SetGID(x, y, gid); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// CopyRegion returns a copy of the tiles within the given area as a chunk, which can later be
+// placed with PasteRegion. Positions without a tile are empty. An area with a negative width or
+// height extends to the left or above its position.
+func (layer *TileLayer) CopyRegion(area Rect) *Chunk {
+	area = area.Canon()
+	region := &Chunk{Rect: area, Tiles: make([]TileID, area.Area())}
+	for y := 0; y < area.Height; y++ {
+		for x := 0; x < area.Width; x++ {
+			region.Tiles[x+(y*area.Width)] = layer.GetGID(area.X+x, area.Y+y)
+		}
+	}
+	return region
+}
+
+// PasteRegion places the tiles of a region with its top-left corner at the specified map
+// coordinates. Empty tiles in the region are skipped, as when stamping tiles in Tiled, so an area
+// can be cleared first with Fill to replace it entirely. For finite maps, tiles outside the
+// bounds of the layer are discarded.
+func (layer *TileLayer) PasteRegion(region *Chunk, x, y int) error {
+	area := layer.clip(Rect{Point: Point{X: x, Y: y}, Size: region.Size})
+	for py := area.Top(); py < area.Bottom(); py++ {
+		for px := area.Left(); px < area.Right(); px++ {
+			if gid := region.Tiles[(px-x)+((py-y)*region.Width)]; gid != 0 {
+				if err := layer.SetGID(px, py, gid); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// MergeLayers composites the tiles of the source layers onto the destination layer, where each
+// source is drawn over those before it, and non-empty tiles replace those beneath them.
+//
+// For finite maps, ErrOutOfBounds is returned without changing the destination when a source has
+// tiles outside the bounds of the destination.
+func MergeLayers(dst *TileLayer, src ...*TileLayer) error {
+	if !dst.infinite() {
+		bounds := Rect{Size: dst.Size}
+		for _, layer := range src {
+			inside := true
+			layer.eachTile(func(x, y int, _ TileID) {
+				inside = inside && bounds.Contains(Point{X: x, Y: y})
+			})
+			if !inside {
+				return ErrOutOfBounds
+			}
+		}
+	}
+
+	for _, layer := range src {
+		if layer == dst {
+			continue
+		}
+		var err error
+		layer.eachTile(func(x, y int, gid TileID) {
+			if err == nil {
+				err = dst.SetGID(x, y, gid)
+			}
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// eachTile calls fn with the position and global tile ID of every non-empty tile of the layer.
+func (layer *TileLayer) eachTile(fn func(x, y int, gid TileID)) {
+	for i, gid := range layer.Tiles {
+		if gid != 0 && layer.Width > 0 {
+			fn(i%layer.Width, i/layer.Width, gid)
+		}
+	}
+	for i := range layer.Chunks {
+		chunk := &layer.Chunks[i]
+		for j, gid := range chunk.Tiles {
+			if gid != 0 {
+				fn(chunk.X+j%chunk.Width, chunk.Y+j/chunk.Width, gid)
+			}
+		}
+	}
+}
+
+// infinite tests whether the tiles of the layer are stored in chunks.
+func (layer *TileLayer) infinite() bool {
+	return len(layer.Chunks) > 0 || (layer.parent != nil && layer.parent.Infinite)
+}
+
+// drawsLike tests whether two tile layers are drawn the same way apart from their tiles, so that
+// they can be merged without changing how the map is rendered.
+func (layer *TileLayer) drawsLike(other *TileLayer) bool {
+	return layer.Opacity == other.Opacity && layer.TintColor == other.TintColor &&
+		layer.Offset == other.Offset && layer.Parallax == other.Parallax && layer.Rect == other.Rect
+}
+
+// clip restricts an area to the bounds of the layer for finite maps.
+func (layer *TileLayer) clip(area Rect) Rect {
+	if layer.infinite() {
+		return area
+	}
+	return area.Intersect(Rect{Size: layer.Size})
+}
+
+// allocChunk creates a new empty chunk containing the given position, which must not be within
+// an existing chunk, and returns it with the position localized to it. The chunk is aligned to
+// the chunk size of the layer, and clipped so that it does not overlap existing chunks that are
+// not aligned to it.
+func (layer *TileLayer) allocChunk(x, y int) (*Chunk, int, int) {
+	size := layer.ChunkSize
+	if size.Width <= 0 || size.Height <= 0 {
+		size = defaultChunkSize
+		if len(layer.Chunks) > 0 {
+			size = layer.Chunks[0].Size
+		}
+	}
+
+	origin := Point{X: floorDiv(x, size.Width) * size.Width, Y: floorDiv(y, size.Height) * size.Height}
+	area := Rect{Point: origin, Size: size}
+	for i := range layer.Chunks {
+		area = clipChunk(area, layer.Chunks[i].Rect, Point{X: x, Y: y})
+	}
+
+	current := layer.chunks.current(layer.Chunks)
+	layer.Chunks = append(layer.Chunks, Chunk{
+		Rect:  area,
+		Tiles: make([]TileID, area.Area()),
+	})
+	if current {
+		layer.chunks.added(layer.Chunks)
+	}
+	chunk := &layer.Chunks[len(layer.Chunks)-1]
+	return chunk, x - chunk.X, y - chunk.Y
+}
+
+// freeChunk removes the chunk containing the given position when all of its tiles are empty.
+func (layer *TileLayer) freeChunk(x, y int) {
+	i := layer.chunks.find(layer.Chunks, Point{X: x, Y: y})
+	if i < 0 || slices.ContainsFunc(layer.Chunks[i].Tiles, func(gid TileID) bool { return gid != 0 }) {
+		return
+	}
+	layer.Chunks = slices.Delete(layer.Chunks, i, i+1)
+}
+
+// clipChunk returns the largest part of an area that contains the given position and does not
+// overlap another area, which must not contain the position.
+func clipChunk(area, other Rect, p Point) Rect {
+	if area.Intersect(other).Area() == 0 {
+		return area
+	}
+
+	// Each side of the other area that the position is beyond is a candidate to clip at
+	var best Rect
+	clip := func(left, top, right, bottom int) {
+		candidate := Rect{Point: Point{X: left, Y: top}, Size: Size{Width: right - left, Height: bottom - top}}
+		if candidate.Area() > best.Area() {
+			best = candidate
+		}
 	}
+	if p.X < other.Left() {
+		clip(area.Left(), area.Top(), other.Left(), area.Bottom())
+	}
+	if p.X >= other.Right() {
+		clip(other.Right(), area.Top(), area.Right(), area.Bottom())
+	}
+	if p.Y < other.Top() {
+		clip(area.Left(), area.Top(), area.Right(), other.Top())
+	}
+	if p.Y >= other.Bottom() {
+		clip(area.Left(), other.Bottom(), area.Right(), area.Bottom())
+	}
+	return best
+}
+
+// floorDiv returns the quotient of a and b rounded towards negative infinity.
+func floorDiv(a, b int) int {
+	q := a / b
+	if a%b != 0 && (a < 0) != (b < 0) {
+		q--
+	}
+	return q
+}
 
-	// Normalize coordinates to the chunk boundaries
-	x %= layer.ChunkSize.Width
-	y %= layer.ChunkSize.Height
-	if x < 0 {
-		x += layer.ChunkSize.Width
+// MarshalXML implements the xml.Marshaler interface.
+func (layer *TileLayer) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return layer.encodeXML(nil, e, start)
+}
+
+// encodeXML implements the xmlEncoding interface.
+func (layer *TileLayer) encodeXML(enc *encoder, e *xml.Encoder, start xml.StartElement) error {
+	start = xml.StartElement{Name: xml.Name{Local: "layer"}, Attr: layer.xmlAttrs()}
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := layer.Extra.marshalXML(e); err != nil {
+		return err
+	}
+	if err := e.EncodeElement(enc.xml(layer.Properties.owned(&layer.Extra)), start); err != nil {
+		return err
 	}
-	if y < 0 {
-		y += layer.ChunkSize.Height
+	if err := layer.TileData.marshalXML(e, layer.Width, layer.compressionLevel()); err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (layer *TileLayer) MarshalJSON() ([]byte, error) {
+	return layer.encodeJSON(nil)
+}
+
+// encodeJSON implements the jsonEncoding interface.
+func (layer *TileLayer) encodeJSON(enc *encoder) ([]byte, error) {
+	attrs := layer.jsonAttrs(enc, LayerTile)
+	if err := layer.TileData.jsonData(attrs, layer.compressionLevel()); err != nil {
+		return nil, err
 	}
+	return json.Marshal(attrs)
+}
 
-	// Calculate chunk index
-	i := (x / layer.ChunkSize.Width) + ((y * layer.ChunkSize.Height) * layer.chunkCols)
-	return &layer.Chunks[i], x % layer.chunkSz.Width, y % layer.chunkSz.Height
+// compressionLevel returns the compression level of the parent map, or -1 for the default
+// level when the layer does not belong to a map.
+func (layer *TileLayer) compressionLevel() int {
+	if layer.parent == nil {
+		return -1
+	}
+	return layer.parent.CompressionLevel
 }
 
 // UnmarshalXML implements the xml.Unmarshaler interface.
@@ -85,7 +412,7 @@ func (layer *TileLayer) UnmarshalXML(d *xml.Decoder, start xml.StartElement) err
 		} else if handled {
 			continue
 		}
-		logAttr(attr.Name.Local, start.Name.Local)
+		layer.Extra.attr(attr, start.Name.Local)
 	}
 
 	token, err := d.Token()
@@ -104,7 +431,9 @@ func (layer *TileLayer) UnmarshalXML(d *xml.Decoder, start xml.StartElement) err
 						return err
 					}
 				default:
-					logElem(child.Name.Local, start.Name.Local)
+					if err := layer.Extra.elem(d, child, start.Name.Local); err != nil {
+						return err
+					}
 				}
 			}
 		}
@@ -115,16 +444,16 @@ func (layer *TileLayer) UnmarshalXML(d *xml.Decoder, start xml.StartElement) err
 		return err
 	}
 
-	if len(layer.Chunks) > 0 {
-		last := layer.Chunks[len(layer.Chunks)-1]
-		layer.chunkSz = last.Size
-		layer.ChunkSize = Size{Width: last.Right(), Height: last.Bottom()}
-
-		layer.chunkCols = layer.ChunkSize.Width / layer.chunkSz.Width
-		layer.chunkRows = layer.ChunkSize.Height / layer.chunkSz.Height
-	}
-
 	return nil
 }
 
+// Clone creates a deep copy of the TileLayer. The copy is not within any map or group.
+func (layer *TileLayer) Clone() *TileLayer {
+	dup := *layer
+	dup.baseLayer = layer.baseLayer.clone()
+	dup.TileData = layer.TileData.clone()
+	dup.chunks = chunkIndex{}
+	return &dup
+}
+
 // vim: ts=4
@@ -0,0 +1,470 @@
+package tmx
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// tileMap returns a map with a single tile layer, and a tileset of 16 tiles.
+func tileMap(t *testing.T, infinite bool) (*Map, *TileLayer) {
+	t.Helper()
+
+	m := NewMap(Orthogonal, Size{Width: 4, Height: 3}, Size{Width: 16, Height: 16})
+	m.Infinite = infinite
+	image := &Image{Source: "terrain.png", Size: Size{Width: 64, Height: 64}}
+	m.AddTileset(NewTileset("terrain", Size{Width: 16, Height: 16}, image, 0, 0))
+	layer := NewTileLayer("ground", m.Size)
+	if infinite {
+		layer.Tiles = nil
+	}
+	m.AddLayer(layer)
+	return m, layer
+}
+
+// checkChunks reports chunks that overlap another, or that are not the expected size.
+func checkChunks(t *testing.T, layer *TileLayer) {
+	t.Helper()
+
+	for i, chunk := range layer.Chunks {
+		if len(chunk.Tiles) != chunk.Area() {
+			t.Errorf("chunk %v has %d tiles", chunk.Rect, len(chunk.Tiles))
+		}
+		for _, other := range layer.Chunks[i+1:] {
+			if overlap := chunk.Intersect(other.Rect); overlap.Area() > 0 {
+				t.Errorf("chunks %v and %v overlap", chunk.Rect, other.Rect)
+			}
+		}
+	}
+}
+
+func TestSetGIDFinite(t *testing.T) {
+	_, layer := tileMap(t, false)
+	for _, p := range []Point{{0, 0}, {3, 0}, {0, 2}, {3, 2}} {
+		gid := TileID(p.X+p.Y*4+1) | FlipV
+		if err := layer.SetGID(p.X, p.Y, gid); err != nil {
+			t.Fatalf("%v: %v", p, err)
+		}
+		if got := layer.GetGID(p.X, p.Y); got != gid {
+			t.Errorf("%v: GID is %d, want %d", p, got, gid)
+		}
+	}
+	for _, p := range []Point{{-1, 0}, {0, -1}, {4, 0}, {0, 3}} {
+		if err := layer.SetGID(p.X, p.Y, 1); !errors.Is(err, ErrOutOfBounds) {
+			t.Errorf("%v: error is %v, want %v", p, err, ErrOutOfBounds)
+		}
+		if gid := layer.GetGID(p.X, p.Y); gid != 0 {
+			t.Errorf("%v: GID outside the layer is %d", p, gid)
+		}
+	}
+	if err := layer.Clear(3, 2); err != nil || layer.GetGID(3, 2) != 0 {
+		t.Errorf("tile was not cleared: %v", err)
+	}
+	if len(layer.Chunks) != 0 {
+		t.Errorf("chunks were allocated for a finite layer")
+	}
+}
+
+func TestSetGIDInfinite(t *testing.T) {
+	_, layer := tileMap(t, true)
+
+	// Clearing a position without a chunk does not allocate one
+	if err := layer.SetGID(100, 100, 0); err != nil || len(layer.Chunks) != 0 {
+		t.Fatalf("chunk allocated to clear a tile: %v", err)
+	}
+
+	tests := []struct {
+		p      Point
+		origin Point
+	}{
+		{Point{0, 0}, Point{0, 0}},
+		{Point{15, 15}, Point{0, 0}},
+		{Point{16, 0}, Point{16, 0}},
+		{Point{-1, 0}, Point{-16, 0}},
+		{Point{-16, -16}, Point{-16, -16}},
+		{Point{-17, 5}, Point{-32, 0}},
+		{Point{31, -1}, Point{16, -16}},
+		{Point{1000, -1000}, Point{992, -1008}},
+	}
+	for i, test := range tests {
+		gid := TileID(i+1) | FlipD
+		if err := layer.SetGID(test.p.X, test.p.Y, gid); err != nil {
+			t.Fatalf("%v: %v", test.p, err)
+		}
+		chunk, cx, cy := layer.ChunkAt(test.p.X, test.p.Y)
+		if chunk == nil || chunk.Point != test.origin || chunk.Size != defaultChunkSize {
+			t.Errorf("%v: chunk is %v, want origin %v", test.p, chunk, test.origin)
+			continue
+		}
+		if cx != test.p.X-test.origin.X || cy != test.p.Y-test.origin.Y {
+			t.Errorf("%v: local position is %d,%d", test.p, cx, cy)
+		}
+	}
+	for i, test := range tests {
+		if gid := layer.GetGID(test.p.X, test.p.Y); gid != TileID(i+1)|FlipD {
+			t.Errorf("%v: GID is %d", test.p, gid)
+		}
+	}
+	if len(layer.Chunks) != 7 {
+		t.Errorf("%d chunks were allocated, want 7", len(layer.Chunks))
+	}
+	checkChunks(t, layer)
+
+	// New chunks use the configured size
+	layer.ChunkSize = Size{Width: 8, Height: 4}
+	layer.SetGID(-100, 50, 1)
+	if chunk, _, _ := layer.ChunkAt(-100, 50); chunk == nil || chunk.Rect != (Rect{Point{-104, 48}, Size{8, 4}}) {
+		t.Errorf("chunk is %v", chunk)
+	}
+}
+
+func TestSetGIDUnaligned(t *testing.T) {
+	_, layer := tileMap(t, true)
+	// Chunks read from a file that are not aligned to the chunk size
+	layer.Chunks = []Chunk{
+		{Rect: Rect{Point{8, 8}, Size{16, 16}}, Tiles: make([]TileID, 256)},
+		{Rect: Rect{Point{-5, -3}, Size{4, 4}}, Tiles: make([]TileID, 16)},
+	}
+	layer.Chunks[0].Tiles[0] = 7
+
+	// New chunks are clipped to the gaps between the existing chunks
+	tests := []struct {
+		p    Point
+		want Rect
+	}{
+		{Point{0, 0}, Rect{Point{0, 0}, Size{8, 16}}},
+		{Point{8, 0}, Rect{Point{8, 0}, Size{8, 8}}},
+		{Point{-1, -1}, Rect{Point{-1, -16}, Size{1, 16}}},
+		{Point{-5, -4}, Rect{Point{-16, -16}, Size{15, 13}}},
+		{Point{16, 24}, Rect{Point{16, 24}, Size{16, 8}}},
+	}
+	for i, test := range tests {
+		if err := layer.SetGID(test.p.X, test.p.Y, TileID(i+1)); err != nil {
+			t.Fatalf("%v: %v", test.p, err)
+		}
+		if chunk, _, _ := layer.ChunkAt(test.p.X, test.p.Y); chunk == nil || chunk.Rect != test.want {
+			t.Errorf("%v: chunk is %v, want %v", test.p, chunk, test.want)
+		}
+	}
+	checkChunks(t, layer)
+
+	// An area overlapping every chunk is filled without overlapping chunks
+	area := Rect{Point{-20, -20}, Size{50, 50}}
+	if err := layer.Fill(area, 3); err != nil {
+		t.Fatal(err)
+	}
+	for y := -25; y < 35; y++ {
+		for x := -25; x < 35; x++ {
+			want := TileID(0)
+			if area.Contains(Point{x, y}) {
+				want = 3
+			}
+			if gid := layer.GetGID(x, y); gid != want {
+				t.Errorf("GID at %d,%d is %d, want %d", x, y, gid, want)
+			}
+		}
+	}
+	checkChunks(t, layer)
+}
+
+func TestSetGIDTileCount(t *testing.T) {
+	_, layer := tileMap(t, false)
+	layer.Tiles = layer.Tiles[:5]
+	if err := layer.SetGID(3, 2, 1); !errors.Is(err, ErrTileCount) {
+		t.Errorf("error is %v, want %v", err, ErrTileCount)
+	}
+	if gid := layer.GetGID(3, 2); gid != 0 {
+		t.Errorf("GID beyond the tiles is %d", gid)
+	}
+
+	_, layer = tileMap(t, true)
+	layer.Chunks = []Chunk{{Rect: Rect{Point{0, 0}, Size{16, 16}}, Tiles: make([]TileID, 10)}}
+	if err := layer.SetGID(15, 15, 1); !errors.Is(err, ErrTileCount) {
+		t.Errorf("chunk: error is %v, want %v", err, ErrTileCount)
+	}
+	if gid := layer.GetGID(15, 15); gid != 0 {
+		t.Errorf("chunk: GID beyond the tiles is %d", gid)
+	}
+}
+
+func TestReadOverlappingChunks(t *testing.T) {
+	doc := `<map version="1.10" orientation="orthogonal" width="2" height="2" tilewidth="16" tileheight="16" infinite="1">
+ <layer id="1" name="ground" width="2" height="2">
+  <data encoding="csv">
+   <chunk x="0" y="0" width="2" height="2">1,2,3,4</chunk>
+   <chunk x="1" y="1" width="2" height="2">1,2,3,4</chunk>
+  </data>
+ </layer>
+</map>`
+	var m Map
+	if err := Decode(strings.NewReader(doc), FormatXML, &m); !errors.Is(err, ErrChunkOverlap) {
+		t.Errorf("error is %v, want %v", err, ErrChunkOverlap)
+	}
+}
+
+func TestChunkAt(t *testing.T) {
+	_, layer := tileMap(t, true)
+	for _, p := range []Point{{0, 0}, {-1, -1}, {16, 0}, {-17, 40}} {
+		layer.SetGID(p.X, p.Y, 1)
+	}
+
+	find := func(x, y int) Rect {
+		chunk, cx, cy := layer.ChunkAt(x, y)
+		if chunk == nil {
+			return Rect{}
+		}
+		if cx != x-chunk.X || cy != y-chunk.Y {
+			t.Errorf("position within %v of %d,%d is %d,%d", chunk.Rect, x, y, cx, cy)
+		}
+		return chunk.Rect
+	}
+	tests := []struct {
+		p    Point
+		want Rect
+	}{
+		{Point{5, 5}, Rect{Point{0, 0}, Size{16, 16}}},
+		{Point{-16, -16}, Rect{Point{-16, -16}, Size{16, 16}}},
+		{Point{31, 15}, Rect{Point{16, 0}, Size{16, 16}}},
+		{Point{-17, 40}, Rect{Point{-32, 32}, Size{16, 16}}},
+		{Point{32, 0}, Rect{}},
+		{Point{-1, 16}, Rect{}},
+	}
+	for _, test := range tests {
+		if got := find(test.p.X, test.p.Y); got != test.want {
+			t.Errorf("chunk at %v is %v, want %v", test.p, got, test.want)
+		}
+	}
+
+	// Chunks that are changed or replaced directly are still found
+	layer.Chunks[0].Point = Point{64, 64}
+	if got := find(5, 5); got != (Rect{}) {
+		t.Errorf("chunk moved in place was still found at %v", got)
+	}
+	if got := find(70, 70); got.Point != (Point{64, 64}) {
+		t.Errorf("chunk moved in place was found at %v", got)
+	}
+	layer.Chunks = []Chunk{{Rect: Rect{Point{3, -5}, Size{10, 7}}, Tiles: make([]TileID, 70)}}
+	if got := find(12, 1); got != layer.Chunks[0].Rect {
+		t.Errorf("unaligned chunk was not found: %v", got)
+	}
+	if got := find(13, 1); got != (Rect{}) {
+		t.Errorf("chunk found outside of the unaligned chunk: %v", got)
+	}
+}
+
+func TestSetTile(t *testing.T) {
+	m, layer := tileMap(t, false)
+	tileset := m.Tilesets[0].Tileset
+	if err := layer.SetTile(1, 1, tileset.Tile(5), FlipH|FlipV|6); err != nil {
+		t.Fatal(err)
+	}
+	if tile, gid := layer.TileAt(1, 1); tile != tileset.Tile(5) || gid != 6|FlipH|FlipV {
+		t.Errorf("tile is %v with GID %d", tile, gid)
+	}
+
+	other := NewTileset("other", Size{Width: 16, Height: 16}, &Image{Size: Size{Width: 16, Height: 16}}, 0, 0)
+	if err := layer.SetTile(0, 0, other.Tile(0), 0); !errors.Is(err, ErrTileNotInMap) {
+		t.Errorf("error is %v, want %v", err, ErrTileNotInMap)
+	}
+	if err := NewTileLayer("detached", m.Size).SetTile(0, 0, tileset.Tile(0), 0); !errors.Is(err, ErrTileNotInMap) {
+		t.Errorf("error is %v, want %v", err, ErrTileNotInMap)
+	}
+	if err := layer.SetTile(1, 1, nil, 0); err != nil || layer.GetGID(1, 1) != 0 {
+		t.Errorf("tile was not cleared: %v", err)
+	}
+}
+
+func TestFill(t *testing.T) {
+	tests := []struct {
+		infinite bool
+		area     Rect
+		want     int
+	}{
+		{false, Rect{Point{1, 1}, Size{2, 2}}, 4},
+		{false, Rect{Point{-2, -2}, Size{4, 4}}, 4},
+		{false, Rect{Point{2, 1}, Size{10, 10}}, 4},
+		{false, Rect{Point{5, 5}, Size{2, 2}}, 0},
+		{false, Rect{Point{3, 3}, Size{-2, -2}}, 4},
+		{true, Rect{Point{-3, -2}, Size{6, 4}}, 24},
+		{true, Rect{Point{10, 14}, Size{12, 4}}, 48},
+		{true, Rect{Point{3, 2}, Size{-6, -4}}, 24},
+	}
+	for _, test := range tests {
+		name := fmt.Sprintf("%v/%v", test.infinite, test.area)
+		_, layer := tileMap(t, test.infinite)
+		if err := layer.Fill(test.area, 3|FlipH); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		// Areas with a negative size extend to the left and above their position
+		area := test.area.Canon()
+		var count int
+		for y := -20; y < 40; y++ {
+			for x := -20; x < 40; x++ {
+				gid := layer.GetGID(x, y)
+				inside := area.Contains(Point{x, y}) && (test.infinite || layer.Contains(Point{x, y}))
+				if gid != 0 {
+					count++
+				}
+				if (gid != 0) != inside || (inside && gid != 3|FlipH) {
+					t.Errorf("%s: GID at %d,%d is %d", name, x, y, gid)
+				}
+			}
+		}
+		if count != test.want {
+			t.Errorf("%s: %d tiles were filled, want %d", name, count, test.want)
+		}
+		checkChunks(t, layer)
+	}
+}
+
+func TestCopyRegion(t *testing.T) {
+	for _, infinite := range []bool{false, true} {
+		_, layer := tileMap(t, infinite)
+		for y := 0; y < 3; y++ {
+			for x := 0; x < 4; x++ {
+				layer.SetGID(x, y, TileID(x+y*4+1))
+			}
+		}
+		if infinite {
+			layer.SetGID(-1, -1, 99)
+		}
+
+		// Positions outside the layer are copied as empty tiles
+		region := layer.CopyRegion(Rect{Point{-1, -1}, Size{3, 3}})
+		want := []TileID{0, 0, 0, 0, 1, 2, 0, 5, 6}
+		if infinite {
+			want[0] = 99
+		}
+		if region.Rect != (Rect{Point{-1, -1}, Size{3, 3}}) || fmt.Sprint(region.Tiles) != fmt.Sprint(want) {
+			t.Errorf("%v: region is %v with %v, want %v", infinite, region.Rect, region.Tiles, want)
+		}
+
+		// Areas with a negative size extend to the left and above their position
+		if flipped := layer.CopyRegion(Rect{Point{2, 2}, Size{-3, -3}}); flipped.Rect != region.Rect ||
+			fmt.Sprint(flipped.Tiles) != fmt.Sprint(want) {
+			t.Errorf("%v: region is %v with %v, want %v", infinite, flipped.Rect, flipped.Tiles, want)
+		}
+
+		// The copy is independent of the layer
+		layer.SetGID(0, 0, 50)
+		if region.Tiles[4] != 1 {
+			t.Errorf("%v: region shares tiles with the layer", infinite)
+		}
+	}
+}
+
+func TestPasteRegion(t *testing.T) {
+	region := &Chunk{Rect: Rect{Size: Size{3, 2}}, Tiles: []TileID{1, 0, 2, 0, 3 | FlipH, 4}}
+	tests := []struct {
+		infinite bool
+		p        Point
+	}{
+		{false, Point{0, 0}},
+		{false, Point{2, 2}},
+		{false, Point{-1, -1}},
+		{true, Point{-2, -1}},
+		{true, Point{14, 15}},
+		{true, Point{-17, -17}},
+	}
+	for _, test := range tests {
+		name := fmt.Sprintf("%v/%v", test.infinite, test.p)
+		_, layer := tileMap(t, test.infinite)
+		for y := -20; y < 40; y++ {
+			for x := -20; x < 40; x++ {
+				layer.SetGID(x, y, 9)
+			}
+		}
+		if err := layer.PasteRegion(region, test.p.X, test.p.Y); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		// Empty tiles of the region leave the existing tiles in place
+		for y := -20; y < 40; y++ {
+			for x := -20; x < 40; x++ {
+				want := TileID(9)
+				rx, ry := x-test.p.X, y-test.p.Y
+				if rx >= 0 && rx < 3 && ry >= 0 && ry < 2 && region.Tiles[rx+ry*3] != 0 {
+					want = region.Tiles[rx+ry*3]
+				}
+				if !test.infinite && (x < 0 || x >= 4 || y < 0 || y >= 3) {
+					want = 0
+				}
+				if gid := layer.GetGID(x, y); gid != want {
+					t.Errorf("%s: GID at %d,%d is %d, want %d", name, x, y, gid, want)
+				}
+			}
+		}
+		checkChunks(t, layer)
+	}
+}
+
+func TestMergeLayers(t *testing.T) {
+	m, dst := tileMap(t, false)
+	copy(dst.Tiles, []TileID{1, 1, 1, 1, 1, 1, 1, 1, 0, 0, 0, 0})
+	middle, top := NewTileLayer("middle", m.Size), NewTileLayer("top", m.Size)
+	copy(middle.Tiles, []TileID{0, 2, 2, 0, 0, 0, 0, 0, 0, 2, 0, 0})
+	copy(top.Tiles, []TileID{0, 0, 3 | FlipH, 3, 0, 0, 0, 0, 0, 0, 0, 3})
+	m.AddLayer(middle)
+	m.AddLayer(top)
+
+	// Later layers are drawn over earlier ones, and empty tiles leave those beneath them
+	if err := MergeLayers(dst, middle, top, dst); err != nil {
+		t.Fatal(err)
+	}
+	want := []TileID{1, 2, 3 | FlipH, 3, 1, 1, 1, 1, 0, 2, 0, 3}
+	if fmt.Sprint(dst.Tiles) != fmt.Sprint(want) {
+		t.Errorf("tiles are %v, want %v", dst.Tiles, want)
+	}
+	if middle.Tiles[1] != 2 || top.Tiles[2] != 3|FlipH || m.Len() != 3 {
+		t.Errorf("sources were changed")
+	}
+
+	// Tiles outside the bounds of a finite layer are rejected without changing it
+	large := NewTileLayer("large", Size{Width: 6, Height: 6})
+	large.SetGID(5, 5, 4)
+	large.SetGID(0, 2, 4)
+	if err := MergeLayers(dst, large); err != ErrOutOfBounds {
+		t.Errorf("merging a larger layer returned %v", err)
+	}
+	if fmt.Sprint(dst.Tiles) != fmt.Sprint(want) {
+		t.Errorf("tiles are %v, want %v", dst.Tiles, want)
+	}
+
+	// A larger layer with tiles only within the bounds is merged
+	large.SetGID(5, 5, 0)
+	if err := MergeLayers(dst, large); err != nil {
+		t.Fatal(err)
+	}
+	if dst.GetGID(0, 2) != 4 || len(dst.Tiles) != 12 {
+		t.Errorf("tiles are %v", dst.Tiles)
+	}
+}
+
+func TestMergeLayersInfinite(t *testing.T) {
+	_, dst := tileMap(t, true)
+	dst.SetGID(-20, -20, 1)
+	dst.SetGID(5, 5, 1)
+
+	// Sources of any size are merged into the chunks of the destination
+	src := NewTileLayer("src", Size{Width: 2, Height: 2})
+	src.Tiles = []TileID{0, 2, 3, 0}
+	chunked := NewTileLayer("chunked", Size{})
+	chunked.Tiles = nil
+	chunked.Chunks = []Chunk{{Rect: Rect{Point{-32, 16}, Size{16, 16}}, Tiles: make([]TileID, 256)}}
+	chunked.Chunks[0].Tiles[17] = 4
+	if err := MergeLayers(dst, src, chunked); err != nil {
+		t.Fatal(err)
+	}
+
+	tiles := map[Point]TileID{{-20, -20}: 1, {5, 5}: 1, {1, 0}: 2, {0, 1}: 3, {-31, 17}: 4}
+	for p, gid := range tiles {
+		if got := dst.GetGID(p.X, p.Y); got != gid {
+			t.Errorf("tile at %v is %d, want %d", p, got, gid)
+		}
+	}
+	checkChunks(t, dst)
+}
+
+// vim: ts=4
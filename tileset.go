@@ -2,11 +2,13 @@ package tmx
 
 import (
 	"bytes"
+	"cmp"
 	"encoding/json"
 	"encoding/xml"
 	"fmt"
 	"log"
 	"path/filepath"
+	"slices"
 	"strconv"
 )
 
@@ -79,6 +81,8 @@ type Tileset struct {
 	// Tiled editor. Defaults to full transparency, and is typically of little relevance in
 	// regards to tilemap rendering.
 	BackgroundColor Color
+	// Extra contains unrecognized data that was retained while reading, see PreserveUnknown.
+	Extra Extra
 	// cache is a resource cache that maintains references to shared objects.
 	cache *Cache
 }
@@ -90,18 +94,328 @@ type MapTileset struct {
 	FirstGID TileID
 	// Map is the parent tilemap this tileset is being used in.
 	Map *Map
+	// Embedded determines whether the tileset definition is written within the map, rather
+	// than as a reference to its source file. Tilesets without a source are always embedded.
+	Embedded bool
 	// Tileset is the actual tileset implementation, and is unbound by the map-specific fields,
 	// allowing it to be cached and reused with different maps.
 	*Tileset
+	// Reference contains unrecognized data of the reference to an external tileset that was
+	// retained while reading, see PreserveUnknown.
+	Reference Extra
 	// cache is a resource cache that maintains references to shared objects.
 	cache *Cache
 }
 
+// NewTileset creates a new tileset with the given name and tile size in pixels, with its tiles
+// cut from the given image using the specified spacing and margin between them. The image must
+// define its size to determine the number of tiles. When the image is nil, an empty image
+// collection tileset is created.
+func NewTileset(name string, tileSize Size, image *Image, spacing, margin int) *Tileset {
+	ts := &Tileset{
+		Version:  formatVersion,
+		Name:     name,
+		TileSize: tileSize,
+		Spacing:  spacing,
+		Margin:   margin,
+		Image:    image,
+	}
+	if image != nil && tileSize.Width > 0 && tileSize.Height > 0 {
+		ts.Columns = max((image.Width-2*margin+spacing)/(tileSize.Width+spacing), 0)
+		rows := max((image.Height-2*margin+spacing)/(tileSize.Height+spacing), 0)
+		ts.Count = ts.Columns * rows
+	}
+	ts.postProcess()
+	return ts
+}
+
+// Tile returns the tile with the given local ID, or nil when the tileset does not contain it.
+func (ts *Tileset) Tile(id TileID) *Tile {
+	if i := int(id); i < len(ts.Tiles) && ts.Tiles[i].ID == id {
+		return &ts.Tiles[i]
+	}
+	i, found := slices.BinarySearchFunc(ts.Tiles, id, func(tile Tile, id TileID) int {
+		return cmp.Compare(tile.ID, id)
+	})
+	if found {
+		return &ts.Tiles[i]
+	}
+	return nil
+}
+
+// idCount returns the number of global tile IDs occupied by the tileset, which for image
+// collections can be greater than the number of tiles.
+func (ts *Tileset) idCount() TileID {
+	count := TileID(ts.Count)
+	if n := len(ts.Tiles); n > 0 {
+		count = max(count, ts.Tiles[n-1].ID+1)
+	}
+	return count
+}
+
 // String implements the Stringer interface.
 func (ts *Tileset) String() string {
 	return fmt.Sprintf(`Tileset("%s")`, ts.Name)
 }
 
+// MarshalXML implements the xml.Marshaler interface.
+func (ts *Tileset) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return ts.encodeXML(nil, e, start)
+}
+
+// encodeXML implements the xmlEncoding interface.
+func (ts *Tileset) encodeXML(enc *encoder, e *xml.Encoder, start xml.StartElement) error {
+	version := ts.Version
+	if version == "" {
+		version = formatVersion
+	}
+
+	start = xml.StartElement{Name: xml.Name{Local: "tileset"}}
+	start.Attr = append(start.Attr, xmlStr("version", version))
+	if ts.TiledVersion != "" {
+		start.Attr = append(start.Attr, xmlStr("tiledversion", ts.TiledVersion))
+	}
+	return ts.marshalXML(enc, e, start)
+}
+
+// marshalXML writes the tileset definition within the document of the encoder using the given
+// start element, which may already contain attributes specific to where the tileset is being
+// written.
+func (ts *Tileset) marshalXML(enc *encoder, e *xml.Encoder, start xml.StartElement) error {
+	start.Attr = append(start.Attr, xmlStr("name", ts.Name))
+	if ts.Class != "" {
+		start.Attr = append(start.Attr, xmlStr("class", ts.Class))
+	}
+	start.Attr = append(start.Attr,
+		xmlInt("tilewidth", ts.TileSize.Width),
+		xmlInt("tileheight", ts.TileSize.Height),
+	)
+	if ts.Spacing != 0 {
+		start.Attr = append(start.Attr, xmlInt("spacing", ts.Spacing))
+	}
+	if ts.Margin != 0 {
+		start.Attr = append(start.Attr, xmlInt("margin", ts.Margin))
+	}
+	start.Attr = append(start.Attr,
+		xmlInt("tilecount", ts.Count),
+		xmlInt("columns", ts.Columns),
+	)
+	if ts.ObjectAlign != AlignUnspecified {
+		start.Attr = append(start.Attr, xmlStr("objectalignment", ts.ObjectAlign.String()))
+	}
+	if ts.RenderSize != RenderTile {
+		start.Attr = append(start.Attr, xmlStr("tilerendersize", ts.RenderSize.String()))
+	}
+	if ts.FillMode != FillStretch {
+		start.Attr = append(start.Attr, xmlStr("fillmode", ts.FillMode.String()))
+	}
+	if ts.BackgroundColor != 0 {
+		start.Attr = append(start.Attr, xmlStr("backgroundcolor", ts.BackgroundColor.hex()))
+	}
+	start.Attr = ts.Extra.xmlAttrs(start.Attr)
+
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := ts.Extra.marshalXML(e); err != nil {
+		return err
+	}
+	if ts.Offset.X != 0 || ts.Offset.Y != 0 {
+		offset := []xml.Attr{xmlInt("x", ts.Offset.X), xmlInt("y", ts.Offset.Y)}
+		if err := xmlEmpty(e, "tileoffset", offset...); err != nil {
+			return err
+		}
+	}
+	if ts.Grid != nil {
+		if err := e.EncodeElement(ts.Grid, start); err != nil {
+			return err
+		}
+	}
+	if err := e.EncodeElement(enc.xml(ts.Properties.owned(&ts.Extra)), start); err != nil {
+		return err
+	}
+	if ts.Image != nil {
+		if err := e.EncodeElement(enc.xml(ts.Image), start); err != nil {
+			return err
+		}
+	}
+	if ts.Transforms != nil {
+		if err := e.EncodeElement(ts.Transforms, start); err != nil {
+			return err
+		}
+	}
+	for i := range ts.Tiles {
+		if tile := &ts.Tiles[i]; !tile.isDefault() {
+			if err := e.EncodeElement(enc.xml(tile), start); err != nil {
+				return err
+			}
+		}
+	}
+	if extra := ts.Extra.Children["wangsets"]; len(ts.WangSets) > 0 || (extra != nil && !extra.IsEmpty()) {
+		wangsets := xml.StartElement{Name: xml.Name{Local: "wangsets"}, Attr: extra.xmlAttrs(nil)}
+		if err := e.EncodeToken(wangsets); err != nil {
+			return err
+		}
+		if err := extra.marshalXML(e); err != nil {
+			return err
+		}
+		for i := range ts.WangSets {
+			if err := e.EncodeElement(enc.xml(&ts.WangSets[i]), wangsets); err != nil {
+				return err
+			}
+		}
+		if err := e.EncodeToken(wangsets.End()); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// MarshalXML implements the xml.Marshaler interface.
+//
+// Tilesets that were loaded from an external file are written as a reference to the file,
+// otherwise the tileset definition is embedded.
+func (ts *MapTileset) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return ts.encodeXML(nil, e, start)
+}
+
+// encodeXML implements the xmlEncoding interface.
+func (ts *MapTileset) encodeXML(enc *encoder, e *xml.Encoder, start xml.StartElement) error {
+	if ts.Tileset == nil {
+		return errFormat("tileset with first GID of %d has no definition", ts.FirstGID)
+	}
+
+	start = xml.StartElement{Name: xml.Name{Local: "tileset"}}
+	start.Attr = append(start.Attr, xmlID("firstgid", ts.FirstGID))
+	if ts.Source == "" || ts.Embedded {
+		// Paths within an external tileset are relative to its own file
+		return ts.Tileset.marshalXML(enc.nested(ts.Source), e, start)
+	}
+
+	start.Attr = append(start.Attr, xmlStr("source", enc.refPath(ts.Source)))
+	start.Attr = ts.Reference.xmlAttrs(start.Attr)
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := ts.Reference.marshalXML(e); err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (ts *Tileset) MarshalJSON() ([]byte, error) {
+	return ts.encodeJSON(nil)
+}
+
+// encodeJSON implements the jsonEncoding interface.
+func (ts *Tileset) encodeJSON(enc *encoder) ([]byte, error) {
+	attrs := ts.jsonAttrs(enc)
+	jsonDefaults(attrs, map[string]any{"type": "tileset"})
+	attrs["version"] = formatVersion
+	if ts.Version != "" {
+		attrs["version"] = ts.Version
+	}
+	if ts.TiledVersion != "" {
+		attrs["tiledversion"] = ts.TiledVersion
+	}
+	return json.Marshal(attrs)
+}
+
+// jsonAttrs returns the fields of the tileset definition as they are written in the JSON
+// format within the document of the encoder, excluding those specific to where the tileset is
+// being written.
+func (ts *Tileset) jsonAttrs(enc *encoder) map[string]any {
+	attrs := map[string]any{
+		"name":       ts.Name,
+		"tilewidth":  ts.TileSize.Width,
+		"tileheight": ts.TileSize.Height,
+		"spacing":    ts.Spacing,
+		"margin":     ts.Margin,
+		"tilecount":  ts.Count,
+		"columns":    ts.Columns,
+	}
+	if ts.Class != "" {
+		attrs["class"] = ts.Class
+	}
+	if ts.ObjectAlign != AlignUnspecified {
+		attrs["objectalignment"] = ts.ObjectAlign
+	}
+	if ts.RenderSize != RenderTile {
+		attrs["tilerendersize"] = ts.RenderSize
+	}
+	if ts.FillMode != FillStretch {
+		attrs["fillmode"] = ts.FillMode
+	}
+	if ts.BackgroundColor != 0 {
+		attrs["backgroundcolor"] = ts.BackgroundColor.hex()
+	}
+	if ts.Offset.X != 0 || ts.Offset.Y != 0 {
+		attrs["tileoffset"] = ts.Offset
+	}
+	if ts.Grid != nil {
+		attrs["grid"] = ts.Grid
+	}
+	if len(ts.Properties) > 0 {
+		attrs["properties"] = enc.json(&ts.Properties)
+	}
+	if ts.Image != nil {
+		attrs["image"] = enc.refPath(ts.Image.Source)
+		attrs["imagewidth"] = ts.Image.Width
+		attrs["imageheight"] = ts.Image.Height
+		if ts.Image.Transparency != 0 {
+			attrs["transparentcolor"] = ts.Image.Transparency.hex()
+		}
+	}
+	if ts.Transforms != nil {
+		attrs["transformations"] = ts.Transforms
+	}
+
+	var tiles []*Tile
+	for i := range ts.Tiles {
+		if tile := &ts.Tiles[i]; !tile.isDefault() {
+			tiles = append(tiles, tile)
+		}
+	}
+	if len(tiles) > 0 {
+		attrs["tiles"] = jsonValues(enc, tiles)
+	}
+	if len(ts.WangSets) > 0 {
+		wangsets := make([]jsonValue, len(ts.WangSets))
+		for i := range ts.WangSets {
+			wangsets[i] = enc.json(&ts.WangSets[i])
+		}
+		attrs["wangsets"] = wangsets
+	}
+	ts.Extra.jsonAttrs(attrs)
+	return attrs
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+//
+// Tilesets that were loaded from an external file are written as a reference to the file,
+// unless Embedded is set, otherwise the tileset definition is embedded.
+func (ts *MapTileset) MarshalJSON() ([]byte, error) {
+	return ts.encodeJSON(nil)
+}
+
+// encodeJSON implements the jsonEncoding interface.
+func (ts *MapTileset) encodeJSON(enc *encoder) ([]byte, error) {
+	if ts.Tileset == nil {
+		return nil, errFormat("tileset with first GID of %d has no definition", ts.FirstGID)
+	}
+
+	attrs := map[string]any{"source": enc.refPath(ts.Source)}
+	if ts.Source == "" || ts.Embedded {
+		// Paths within an external tileset are relative to its own file
+		attrs = ts.Tileset.jsonAttrs(enc.nested(ts.Source))
+	} else {
+		ts.Reference.jsonAttrs(attrs)
+	}
+	attrs["firstgid"] = ts.FirstGID
+	return json.Marshal(attrs)
+}
+
 // UnmarshalXML implements the xml.Unmarshaler interface.
 func (ts *Tileset) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	for _, attr := range start.Attr {
@@ -182,7 +496,7 @@ func (ts *Tileset) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 		case "firstgid":
 			// Skip
 		default:
-			logAttr(attr.Name.Local, start.Name.Local)
+			ts.Extra.attr(attr, start.Name.Local)
 		}
 	}
 
@@ -203,7 +517,7 @@ func (ts *Tileset) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 				ts.Tiles = append(ts.Tiles, tile)
 			case "properties":
 				ts.Properties = make(Properties)
-				if err := ts.Properties.UnmarshalXML(d, child); err != nil {
+				if err := ts.Properties.decodeXML(d, child, &ts.Extra); err != nil {
 					return err
 				}
 			case "image":
@@ -224,23 +538,37 @@ func (ts *Tileset) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 				ts.Grid = &grid
 			case "terraintypes":
 				logTerrain()
+				if err := ts.Extra.elem(d, child, start.Name.Local); err != nil {
+					return err
+				}
 			case "wangsets":
 				type wangsets struct {
-					Values []WangSet `xml:"wangset"`
+					Values   []WangSet    `xml:"wangset"`
+					Attrs    []xml.Attr   `xml:",any,attr"`
+					Elements []RawElement `xml:",any"`
 				}
 				var wang wangsets
 				if err := d.DecodeElement(&wang, &child); err != nil {
 					return err
 				}
 				ts.WangSets = wang.Values
+				extra := ts.Extra.child(child.Name.Local)
+				for _, attr := range wang.Attrs {
+					extra.attr(attr, child.Name.Local)
+				}
+				for _, elem := range wang.Elements {
+					extra.raw(elem, child.Name.Local)
+				}
 			case "transformations":
 				var trans Transformations
-				if d.DecodeElement(&trans, &child); err != nil {
+				if err := d.DecodeElement(&trans, &child); err != nil {
 					return err
 				}
 				ts.Transforms = &trans
 			default:
-				logElem(child.Name.Local, start.Name.Local)
+				if err := ts.Extra.elem(d, child, start.Name.Local); err != nil {
+					return err
+				}
 			}
 		}
 
@@ -254,6 +582,7 @@ func (ts *Tileset) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 // UnmarshalXML implements the xml.Unmarshaler interface.
 func (ts *MapTileset) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	var source string
+	var unknown []xml.Attr
 	for _, attr := range start.Attr {
 		switch attr.Name.Local {
 		case "firstgid":
@@ -264,26 +593,30 @@ func (ts *MapTileset) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error
 			}
 		case "source":
 			source = attr.Value
-		}
-
-		if ts.FirstGID > 0 && source != "" {
-			break
+		default:
+			unknown = append(unknown, attr)
 		}
 	}
 
 	if source == "" {
-		// Embedded tileset
+		// Embedded tileset, which consumes the remainder of the element
 		var impl Tileset
+		impl.cache = ts.cache
 		if err := impl.UnmarshalXML(d, start); err != nil {
 			return err
 		}
 		ts.Tileset = &impl
+		ts.Embedded = true
+		return nil
+	}
+
+	if impl, err := ReadTileset(source, FormatUnknown, ts.cache); err == nil {
+		ts.Tileset = impl
 	} else {
-		if impl, err := ReadTileset(source, FormatUnknown, ts.cache); err == nil {
-			ts.Tileset = impl
-		} else {
-			return err
-		}
+		return err
+	}
+	for _, attr := range unknown {
+		ts.Reference.attr(attr, start.Name.Local)
 	}
 
 	// Ensure the element is fully consumed
@@ -293,8 +626,11 @@ func (ts *MapTileset) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error
 			return err
 		}
 		if child, ok := token.(xml.StartElement); ok {
-			logElem(child.Name.Local, start.Name.Local)
+			if err = ts.Reference.elem(d, child, start.Name.Local); err != nil {
+				return err
+			}
 		}
+		token, err = d.Token()
 	}
 
 	return nil
@@ -312,20 +648,56 @@ func (ts *MapTileset) UnmarshalJSON(data []byte) error {
 		return err
 	}
 
+	ts.FirstGID = temp.FirstGID
 	if temp.Source == "" {
 		var tileset Tileset
+		tileset.cache = ts.cache
 		if err := json.Unmarshal(data, &tileset); err != nil {
 			return err
 		}
 		ts.Tileset = &tileset
+		ts.Embedded = true
 	} else {
 		if tileset, err := ReadTileset(temp.Source, FormatUnknown, ts.cache); err != nil {
 			return err
 		} else {
 			ts.Tileset = tileset
 		}
+		return ts.referenceJSON(data)
+	}
+
+	return nil
+}
+
+// referenceJSON retains the unrecognized properties of a reference to an external tileset.
+func (ts *MapTileset) referenceJSON(data []byte) error {
+	d := json.NewDecoder(bytes.NewReader(data))
+	token, err := d.Token()
+	if err != nil {
+		return err
+	} else if token != json.Delim('{') {
+		return ErrExpectedObject
 	}
 
+	for {
+		if token, err = d.Token(); err != nil {
+			return err
+		} else if token == json.Delim('}') {
+			break
+		}
+
+		name := token.(string)
+		switch name {
+		case "firstgid", "source":
+			if err := jsonSkip(d); err != nil {
+				return err
+			}
+		default:
+			if err := ts.Reference.prop(d, name, "tileset"); err != nil {
+				return err
+			}
+		}
+	}
 	return nil
 }
 
@@ -364,7 +736,7 @@ func (ts *Tileset) UnmarshalJSON(data []byte) error {
 			} else {
 				ts.Columns = int(value)
 			}
-		case "fill_mode":
+		case "fillmode", "fill_mode":
 			if str, err := jsonProp[string](d); err != nil {
 				return err
 			} else if ts.FillMode, err = parseFillMode(str); err != nil {
@@ -503,12 +875,22 @@ func (ts *Tileset) UnmarshalJSON(data []byte) error {
 			}
 		case "terrains":
 			logTerrain()
-			jsonSkip(d)
-		case "firstgid", "source", "type":
-			jsonSkip(d)
+			if err := ts.Extra.prop(d, name, "tileset"); err != nil {
+				return err
+			}
+		case "firstgid", "source":
+			// Read by the MapTileset that contains an embedded tileset
+			if err := jsonSkip(d); err != nil {
+				return err
+			}
+		case "type":
+			if err := ts.Extra.skip(d, name); err != nil {
+				return err
+			}
 		default:
-			logProp(name, "tileset")
-			jsonSkip(d)
+			if err := ts.Extra.prop(d, name, "tileset"); err != nil {
+				return err
+			}
 		}
 	}
 
@@ -517,6 +899,8 @@ func (ts *Tileset) UnmarshalJSON(data []byte) error {
 }
 
 func (ts *Tileset) postProcess() {
+	ts.fillTiles()
+
 	var cx, cy float32
 	if ts.Image != nil && ts.Image.Width > 0 && ts.Image.Height > 0 {
 		cx = float32(ts.TileSize.Width) / float32(ts.Image.Width)
@@ -557,6 +941,108 @@ func (ts *Tileset) postProcess() {
 	}
 }
 
+// setTileset sets the tileset implementation used by the map tileset, which is embedded within
+// the map when it has no source.
+func (ts *MapTileset) setTileset(tileset *Tileset) {
+	if ts.Map != nil {
+		ts.cache = ts.Map.cache
+		if tileset.cache == nil {
+			tileset.cache = ts.Map.cache
+		}
+	}
+	ts.Tileset = tileset
+	ts.Embedded = tileset.Source == ""
+}
+
+// cached tests whether the tileset was loaded from an external file that is held by the cache.
+func (ts *MapTileset) cached() bool {
+	if ts.cache == nil || ts.Source == "" {
+		return false
+	}
+	tileset, ok := ts.cache.Tileset(ts.Source)
+	return ok && tileset == ts.Tileset
+}
+
+// fillTiles sorts the tiles by ID, and for tilesets based on a single image, creates a tile
+// for each ID that has no definition in the document, so that every tile can be indexed.
+func (ts *Tileset) fillTiles() {
+	slices.SortFunc(ts.Tiles, func(a, b Tile) int {
+		return cmp.Compare(a.ID, b.ID)
+	})
+	if ts.Image == nil || len(ts.Tiles) >= ts.Count {
+		return
+	}
+
+	tiles := make([]Tile, 0, ts.Count)
+	for i, j := 0, 0; i < ts.Count; i++ {
+		if j < len(ts.Tiles) && ts.Tiles[j].ID == TileID(i) {
+			tiles = append(tiles, ts.Tiles[j])
+			j++
+		} else {
+			tiles = append(tiles, Tile{ID: TileID(i), Tileset: ts})
+		}
+	}
+	for _, tile := range ts.Tiles {
+		if int(tile.ID) >= ts.Count {
+			tiles = append(tiles, tile)
+		}
+	}
+	ts.Tiles = tiles
+}
+
+// Extract writes the tileset definition to a standalone file at the given path, using the
+// specified format, and updates the tileset to be written as a reference to it. When the format
+// is FormatUnknown, it will be detected based on the file extension.
+func (ts *MapTileset) Extract(path string, format Format) error {
+	if ts.Tileset == nil {
+		return errFormat("tileset with first GID of %d has no definition", ts.FirstGID)
+	}
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+
+	// Relative paths within an embedded tileset are relative to the map
+	src := ts.Source
+	if src == "" && ts.Map != nil {
+		src = ts.Map.Source
+	}
+	if err = writeFile(abs, format, ts.Tileset, src); err != nil {
+		return err
+	}
+
+	ts.Tileset.rebase(src, abs)
+	ts.Source = abs
+	ts.Embedded = false
+	if ts.cache != nil {
+		ts.cache.AddTileset(abs, ts.Tileset)
+	}
+	return nil
+}
+
+// rebase updates the relative paths within the tileset from being relative to the file at the
+// src path to being relative to the file at the dst path.
+func (ts *Tileset) rebase(src, dst string) {
+	if ts.Image != nil {
+		ts.Image.Source = rebasePath(ts.Image.Source, src, dst)
+	}
+	ts.Properties.rebase(src, dst)
+	for i := range ts.Tiles {
+		tile := &ts.Tiles[i]
+		if tile.Image != nil {
+			tile.Image.Source = rebasePath(tile.Image.Source, src, dst)
+		}
+		tile.Properties.rebase(src, dst)
+	}
+}
+
+// WriteTileset writes a tileset to a file, using the specified format. When the format is
+// FormatUnknown, it will be detected based on the file extension.
+func WriteTileset(path string, format Format, tileset *Tileset) error {
+	return writeFile(path, format, tileset, tileset.Source)
+}
+
 // ReadTileset reads a tilemap from a file, using the specified format. When the format is
 // FormatUnknown, it will attempt to be detected based on extension and file heuristics.
 //
@@ -603,4 +1089,33 @@ func ReadTileset(path string, format Format, cache *Cache) (*Tileset, error) {
 	return &tileset, nil
 }
 
+// Clone creates a deep copy of the Tileset, with the tiles of the copy referring to it as their
+// parent.
+func (ts *Tileset) Clone() *Tileset {
+	dup := *ts
+	if ts.Image != nil {
+		dup.Image = ts.Image.Clone()
+	}
+	dup.Tiles = make([]Tile, len(ts.Tiles))
+	for i := range ts.Tiles {
+		dup.Tiles[i] = *ts.Tiles[i].Clone()
+		dup.Tiles[i].Tileset = &dup
+	}
+	dup.WangSets = make([]WangSet, len(ts.WangSets))
+	for i := range ts.WangSets {
+		dup.WangSets[i] = *ts.WangSets[i].Clone()
+	}
+	dup.Properties = ts.Properties.clone()
+	if ts.Grid != nil {
+		grid := *ts.Grid
+		dup.Grid = &grid
+	}
+	if ts.Transforms != nil {
+		transforms := *ts.Transforms
+		dup.Transforms = &transforms
+	}
+	dup.Extra = ts.Extra.clone()
+	return &dup
+}
+
 // vim: ts=4
@@ -0,0 +1,156 @@
+package tmx
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// encodeString encodes an object with the given format, returning the document.
+func encodeString(t *testing.T, format Format, obj any) string {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, format, obj); err != nil {
+		t.Fatal(err)
+	}
+	return buf.String()
+}
+
+func TestEmbeddedTileset(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, externalFiles)
+	m, err := ReadMap(filepath.Join(dir, "level.tmx"), FormatUnknown, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ts := m.Tilesets[0]
+	if ts.Embedded || ts.Source == "" {
+		t.Fatalf("external tileset is embedded")
+	}
+
+	tests := []struct {
+		embedded bool
+		format   Format
+		external string
+		embed    string
+	}{
+		{false, FormatXML, `terrain.tsx"`, `name="terrain"`},
+		{true, FormatXML, `terrain.tsx"`, `name="terrain"`},
+		{false, FormatJSON, `terrain.tsx"`, `"name": "terrain"`},
+		{true, FormatJSON, `terrain.tsx"`, `"name": "terrain"`},
+	}
+	for _, test := range tests {
+		ts.Embedded = test.embedded
+		doc := encodeString(t, test.format, m)
+		if test.embedded {
+			if !strings.Contains(doc, test.embed) || strings.Contains(doc, test.external) {
+				t.Errorf("%v: tileset was not embedded:\n%s", test.format, doc)
+			}
+		} else if !strings.Contains(doc, test.external) || strings.Contains(doc, test.embed) {
+			t.Errorf("%v: tileset was embedded:\n%s", test.format, doc)
+		}
+	}
+}
+
+func TestExtractTileset(t *testing.T) {
+	m := decodeMap(t, testMapXML, FormatXML)
+	ts := m.Tilesets[0]
+	if !ts.Embedded || ts.Source != "" {
+		t.Fatalf("embedded tileset has source %q", ts.Source)
+	}
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "extracted.tsj")
+	if err := ts.Extract(path, FormatUnknown); err != nil {
+		t.Fatal(err)
+	}
+	if ts.Embedded || ts.Source != path {
+		t.Errorf("extracted tileset has source %q", ts.Source)
+	}
+	if doc := readFile(t, path); !strings.Contains(doc, `"name": "terrain"`) {
+		t.Errorf("tileset was not written:\n%s", doc)
+	}
+
+	// The map now refers to the extracted tileset, which is read with it
+	mapPath := filepath.Join(dir, "level.tmx")
+	if err := WriteMap(mapPath, FormatUnknown, m); err != nil {
+		t.Fatal(err)
+	}
+	if doc := readFile(t, mapPath); !strings.Contains(doc, `extracted.tsj"`) || strings.Contains(doc, `name="terrain"`) {
+		t.Errorf("map does not refer to the extracted tileset:\n%s", doc)
+	}
+	got, err := ReadMap(mapPath, FormatUnknown, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got.Tilesets) != 1 || got.Tilesets[0].Name != "terrain" || got.Tilesets[0].Count != ts.Count {
+		t.Errorf("tilesets are %v", got.Tilesets)
+	}
+}
+
+// sparseTileset is a tileset based on a single image that only defines some of its tiles, out of
+// order.
+const sparseTileset = `<?xml version="1.0" encoding="UTF-8"?>
+<tileset version="1.10" name="sparse" tilewidth="16" tileheight="16" tilecount="6" columns="3">
+ <image source="sparse.png" width="48" height="32"/>
+ <tile id="4" type="water"/>
+ <tile id="1">
+  <properties>
+   <property name="solid" type="bool" value="true"/>
+  </properties>
+ </tile>
+</tileset>
+`
+
+func TestFillTiles(t *testing.T) {
+	xmlTileset := &Tileset{}
+	if err := Decode(strings.NewReader(sparseTileset), FormatXML, xmlTileset); err != nil {
+		t.Fatal(err)
+	}
+	jsonTileset := &Tileset{}
+	if err := Decode(strings.NewReader(encodeString(t, FormatJSON, xmlTileset)), FormatJSON, jsonTileset); err != nil {
+		t.Fatal(err)
+	}
+
+	for format, ts := range map[Format]*Tileset{FormatXML: xmlTileset, FormatJSON: jsonTileset} {
+		// Every tile of the image is defined, in order of their IDs
+		if len(ts.Tiles) != ts.Count {
+			t.Fatalf("%v: %d tiles are defined, want %d", format, len(ts.Tiles), ts.Count)
+		}
+		for i := range ts.Tiles {
+			if tile := ts.Tile(TileID(i)); tile == nil || tile.ID != TileID(i) || tile.Tileset != ts {
+				t.Errorf("%v: tile %d is %v", format, i, tile)
+			}
+		}
+		if tile := ts.Tile(1); tile.Properties["solid"].Value != true {
+			t.Errorf("%v: tile properties are %v", format, tile.Properties)
+		}
+		if tile := ts.Tile(4); tile.Class != "water" {
+			t.Errorf("%v: tile class is %q", format, tile.Class)
+		}
+
+		// Only the tiles with data are written
+		doc := encodeString(t, format, ts)
+		if n := strings.Count(doc, `"id"`) + strings.Count(doc, `<tile id=`); n != 2 {
+			t.Errorf("%v: %d tiles were written:\n%s", format, n, doc)
+		}
+	}
+
+	// Tiles of new tilesets are defined, and can be found from the map
+	m := NewMap(Orthogonal, Size{Width: 2, Height: 2}, Size{Width: 16, Height: 16})
+	image := &Image{Source: "terrain.png", Size: Size{Width: 32, Height: 32}}
+	m.AddTileset(NewTileset("terrain", Size{Width: 16, Height: 16}, image, 0, 0))
+	layer := NewTileLayer("ground", m.Size)
+	m.AddLayer(layer)
+	layer.Tiles[3] = 4 | FlipH
+	if tile, gid := layer.TileAt(1, 1); tile == nil || tile.ID != 3 || gid != 4|FlipH {
+		t.Errorf("tile is %v with GID %d", tile, gid)
+	}
+	if tile, gid := layer.TileAt(0, 0); tile != nil || gid != 0 {
+		t.Errorf("empty tile is %v with GID %d", tile, gid)
+	}
+}
+
+// vim: ts=4
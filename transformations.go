@@ -1,5 +1,7 @@
 package tmx
 
+import "encoding/xml"
+
 // Transformations describe which transformations can be applied to the tiles in
 // tileset (e.g. to extend a Wang set by transforming existing tiles).
 type Transformations struct {
@@ -14,4 +16,14 @@ type Transformations struct {
 	PreferUntransformed bool `json:"preferuntransformed" xml:"preferuntransformed,attr"`
 }
 
+// MarshalXML implements the xml.Marshaler interface.
+func (t Transformations) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return xmlEmpty(e, "transformations",
+		xmlBool("hflip", t.HFlip),
+		xmlBool("vflip", t.VFlip),
+		xmlBool("rotate", t.Rotate),
+		xmlBool("preferuntransformed", t.PreferUntransformed),
+	)
+}
+
 // vim: ts=4
@@ -6,6 +6,7 @@ import (
 	"encoding/xml"
 	"errors"
 	"log"
+	"slices"
 	"strconv"
 	"strings"
 )
@@ -26,6 +27,77 @@ type WangSet struct {
 	Tiles []WangTile
 	// Properties contain arbitrary key-value pairs of data to associate with the object.
 	Properties
+	// Extra contains unrecognized data that was retained while reading, see PreserveUnknown.
+	Extra Extra
+}
+
+// MarshalXML implements the xml.Marshaler interface.
+func (w *WangSet) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return w.encodeXML(nil, e, start)
+}
+
+// encodeXML implements the xmlEncoding interface.
+func (w *WangSet) encodeXML(enc *encoder, e *xml.Encoder, start xml.StartElement) error {
+	start = xml.StartElement{Name: xml.Name{Local: "wangset"}}
+	start.Attr = append(start.Attr, xmlStr("name", w.Name))
+	if w.Class != "" {
+		start.Attr = append(start.Attr, xmlStr("class", w.Class))
+	}
+	start.Attr = append(start.Attr, xmlStr("type", w.Type.String()), xmlID("tile", w.Tile))
+	start.Attr = w.Extra.xmlAttrs(start.Attr)
+
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := w.Extra.marshalXML(e); err != nil {
+		return err
+	}
+	if err := e.EncodeElement(enc.xml(w.Properties.owned(&w.Extra)), start); err != nil {
+		return err
+	}
+	for i := range w.Colors {
+		if err := e.EncodeElement(enc.xml(&w.Colors[i]), start); err != nil {
+			return err
+		}
+	}
+	for _, tile := range w.Tiles {
+		if err := e.EncodeElement(tile, start); err != nil {
+			return err
+		}
+	}
+	return e.EncodeToken(start.End())
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (w *WangSet) MarshalJSON() ([]byte, error) {
+	return w.encodeJSON(nil)
+}
+
+// encodeJSON implements the jsonEncoding interface.
+func (w *WangSet) encodeJSON(enc *encoder) ([]byte, error) {
+	colors := make([]jsonValue, len(w.Colors))
+	for i := range w.Colors {
+		colors[i] = enc.json(&w.Colors[i])
+	}
+	tiles := w.Tiles
+	if tiles == nil {
+		tiles = []WangTile{}
+	}
+	attrs := map[string]any{
+		"name":      w.Name,
+		"type":      w.Type,
+		"tile":      w.Tile,
+		"colors":    colors,
+		"wangtiles": tiles,
+	}
+	if w.Class != "" {
+		attrs["class"] = w.Class
+	}
+	if len(w.Properties) > 0 {
+		attrs["properties"] = enc.json(&w.Properties)
+	}
+	w.Extra.jsonAttrs(attrs)
+	return json.Marshal(attrs)
 }
 
 // UnmarshalXML implements the xml.Unmarshaler interface.
@@ -50,7 +122,7 @@ func (w *WangSet) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 				w.Type = value
 			}
 		default:
-			logAttr(attr.Name.Local, start.Name.Local)
+			w.Extra.attr(attr, start.Name.Local)
 		}
 	}
 
@@ -78,13 +150,15 @@ func (w *WangSet) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 				}
 			case "properties":
 				props := make(Properties)
-				if err := d.DecodeElement(&props, &child); err != nil {
+				if err := props.decodeXML(d, child, &w.Extra); err != nil {
 					return err
 				} else {
 					w.Properties = props
 				}
 			default:
-				logElem(child.Name.Local, start.Name.Local)
+				if err := w.Extra.elem(d, child, start.Name.Local); err != nil {
+					return err
+				}
 			}
 		}
 
@@ -114,10 +188,15 @@ func (w *WangSet) UnmarshalJSON(data []byte) error {
 		name := token.(string)
 		switch name {
 		case "colors", "wangtiles", "properties":
-		default:
+		case "name", "class", "tile", "type":
 			if token, err = d.Token(); err != nil {
 				return err
 			}
+		default:
+			if err := w.Extra.prop(d, name, "wangset"); err != nil {
+				return err
+			}
+			continue
 		}
 
 		switch name {
@@ -151,8 +230,6 @@ func (w *WangSet) UnmarshalJSON(data []byte) error {
 				return err
 			}
 			w.Properties = props
-		default:
-			logProp(name, "wangset")
 		}
 	}
 	return nil
@@ -173,6 +250,62 @@ type WangColor struct {
 	Probability float64
 	// Properties contain arbitrary key-value pairs of data to associate with the object.
 	Properties
+	// Extra contains unrecognized data that was retained while reading, see PreserveUnknown.
+	Extra Extra
+}
+
+// MarshalXML implements the xml.Marshaler interface.
+func (w *WangColor) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	return w.encodeXML(nil, e, start)
+}
+
+// encodeXML implements the xmlEncoding interface.
+func (w *WangColor) encodeXML(enc *encoder, e *xml.Encoder, start xml.StartElement) error {
+	start = xml.StartElement{Name: xml.Name{Local: "wangcolor"}}
+	start.Attr = append(start.Attr, xmlStr("name", w.Name))
+	if w.Class != "" {
+		start.Attr = append(start.Attr, xmlStr("class", w.Class))
+	}
+	start.Attr = append(start.Attr,
+		xmlStr("color", w.Color.hex()),
+		xmlID("tile", w.Tile),
+		xmlFloat("probability", w.Probability),
+	)
+	start.Attr = w.Extra.xmlAttrs(start.Attr)
+
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := w.Extra.marshalXML(e); err != nil {
+		return err
+	}
+	if err := e.EncodeElement(enc.xml(w.Properties.owned(&w.Extra)), start); err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (w *WangColor) MarshalJSON() ([]byte, error) {
+	return w.encodeJSON(nil)
+}
+
+// encodeJSON implements the jsonEncoding interface.
+func (w *WangColor) encodeJSON(enc *encoder) ([]byte, error) {
+	attrs := map[string]any{
+		"name":        w.Name,
+		"color":       w.Color.hex(),
+		"tile":        w.Tile,
+		"probability": jsonFloat(w.Probability),
+	}
+	if w.Class != "" {
+		attrs["class"] = w.Class
+	}
+	if len(w.Properties) > 0 {
+		attrs["properties"] = enc.json(&w.Properties)
+	}
+	w.Extra.jsonAttrs(attrs)
+	return json.Marshal(attrs)
 }
 
 // UnmarshalXML implements the xml.Unmarshaler interface.
@@ -203,7 +336,7 @@ func (w *WangColor) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 				w.Probability = value
 			}
 		default:
-			logAttr(attr.Name.Local, start.Name.Local)
+			w.Extra.attr(attr, start.Name.Local)
 		}
 	}
 
@@ -217,13 +350,15 @@ func (w *WangColor) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 			switch child.Name.Local {
 			case "properties":
 				props := make(Properties)
-				if err := d.DecodeElement(&props, &child); err != nil {
+				if err := props.decodeXML(d, child, &w.Extra); err != nil {
 					return err
 				} else {
 					w.Properties = props
 				}
 			default:
-				logElem(child.Name.Local, start.Name.Local)
+				if err := w.Extra.elem(d, child, start.Name.Local); err != nil {
+					return err
+				}
 			}
 		}
 		token, err = d.Token()
@@ -249,17 +384,24 @@ func (w *WangColor) UnmarshalJSON(data []byte) error {
 		}
 
 		name := token.(string)
-		if name != "properties" {
+		switch name {
+		case "properties":
+		case "name", "class", "color", "tile", "probability":
 			if token, err = d.Token(); err != nil {
 				return err
 			}
+		default:
+			if err := w.Extra.prop(d, name, "wangcolor"); err != nil {
+				return err
+			}
+			continue
 		}
 
 		switch name {
 		case "name":
 			w.Name = token.(string)
 		case "class":
-			w.Name = token.(string)
+			w.Class = token.(string)
 		case "color":
 			if color, err := ParseColor(token.(string)); err != nil {
 				return err
@@ -277,8 +419,6 @@ func (w *WangColor) UnmarshalJSON(data []byte) error {
 				return err
 			}
 			w.Properties = props
-		default:
-			logProp(name, "wangcolor")
 		}
 	}
 
@@ -300,6 +440,59 @@ type WangTile struct {
 	VFlip bool `json:"vflip"`
 	// Deprecated: Defaults to false and is now defined in Transformations.
 	DFlip bool `json:"dflip"`
+	// Extra contains unrecognized data that was retained while reading, see PreserveUnknown.
+	Extra Extra `json:"-"`
+}
+
+// wangID returns the Wang ID formatted as a comma-separated list of color indices.
+func (w WangTile) wangID() string {
+	var sb strings.Builder
+	for i, index := range w.WangID {
+		if i > 0 {
+			sb.WriteRune(',')
+		}
+		sb.WriteString(strconv.Itoa(int(index)))
+	}
+	return sb.String()
+}
+
+// MarshalXML implements the xml.Marshaler interface.
+func (w WangTile) MarshalXML(e *xml.Encoder, start xml.StartElement) error {
+	attrs := []xml.Attr{xmlID("tileid", w.Tile), xmlStr("wangid", w.wangID())}
+	if w.HFlip {
+		attrs = append(attrs, xmlBool("hflip", true))
+	}
+	if w.VFlip {
+		attrs = append(attrs, xmlBool("vflip", true))
+	}
+	if w.DFlip {
+		attrs = append(attrs, xmlBool("dflip", true))
+	}
+	start = xml.StartElement{Name: xml.Name{Local: "wangtile"}, Attr: w.Extra.xmlAttrs(attrs)}
+
+	if err := e.EncodeToken(start); err != nil {
+		return err
+	}
+	if err := w.Extra.marshalXML(e); err != nil {
+		return err
+	}
+	return e.EncodeToken(start.End())
+}
+
+// MarshalJSON implements the json.Marshaler interface.
+func (w WangTile) MarshalJSON() ([]byte, error) {
+	attrs := map[string]any{"tileid": w.Tile, "wangid": w.WangID}
+	if w.HFlip {
+		attrs["hflip"] = true
+	}
+	if w.VFlip {
+		attrs["vflip"] = true
+	}
+	if w.DFlip {
+		attrs["dflip"] = true
+	}
+	w.Extra.jsonAttrs(attrs)
+	return json.Marshal(attrs)
 }
 
 // UnmarshalXML implements the xml.Unmarshaler interface.
@@ -339,7 +532,7 @@ func (w *WangTile) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 			} else {
 				w.VFlip = value
 			}
-		case "dlip":
+		case "dflip":
 			log.Println("WangTile: dflip is deprecated, use tilset.Transformations")
 			if value, err := strconv.ParseBool(attr.Value); err != nil {
 				return err
@@ -347,7 +540,7 @@ func (w *WangTile) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 				w.DFlip = value
 			}
 		default:
-			logAttr(attr.Name.Local, start.Name.Local)
+			w.Extra.attr(attr, start.Name.Local)
 		}
 	}
 
@@ -357,7 +550,9 @@ func (w *WangTile) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 			return err
 		}
 		if child, ok := token.(xml.StartElement); ok {
-			logElem(child.Name.Local, start.Name.Local)
+			if err := w.Extra.elem(d, child, start.Name.Local); err != nil {
+				return err
+			}
 		}
 		token, err = d.Token()
 	}
@@ -365,4 +560,68 @@ func (w *WangTile) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
 	return nil
 }
 
+// UnmarshalJSON implements the json.Unmarshaler interface.
+func (w *WangTile) UnmarshalJSON(data []byte) error {
+	d := json.NewDecoder(bytes.NewReader(data))
+	token, err := d.Token()
+	if err != nil {
+		return err
+	} else if token != json.Delim('{') {
+		return ErrExpectedObject
+	}
+
+	for {
+		if token, err = d.Token(); err != nil {
+			return err
+		} else if token == json.Delim('}') {
+			break
+		}
+
+		name := token.(string)
+		switch name {
+		case "tileid":
+			if err = d.Decode(&w.Tile); err != nil {
+				return err
+			}
+		case "wangid":
+			if err = d.Decode(&w.WangID); err != nil {
+				return err
+			}
+		case "hflip":
+			if w.HFlip, err = jsonProp[bool](d); err != nil {
+				return err
+			}
+		case "vflip":
+			if w.VFlip, err = jsonProp[bool](d); err != nil {
+				return err
+			}
+		case "dflip":
+			if w.DFlip, err = jsonProp[bool](d); err != nil {
+				return err
+			}
+		default:
+			if err := w.Extra.prop(d, name, "wangtile"); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// Clone creates a deep copy of the WangSet.
+func (w *WangSet) Clone() *WangSet {
+	dup := *w
+	dup.Colors = slices.Clone(w.Colors)
+	for i := range dup.Colors {
+		color := &dup.Colors[i]
+		color.Properties = color.Properties.clone()
+		color.Extra = color.Extra.clone()
+	}
+	dup.Tiles = slices.Clone(w.Tiles)
+	dup.Properties = w.Properties.clone()
+	dup.Extra = w.Extra.clone()
+	return &dup
+}
+
 // vim: ts=4
@@ -0,0 +1,417 @@
+package tmx
+
+import (
+	"bytes"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// testMapXML is a map containing each type of layer, an embedded tileset, and objects of each
+// shape, for testing that documents are written and read back unchanged.
+const testMapXML = `<?xml version="1.0" encoding="UTF-8"?>
+<map version="1.10" tiledversion="1.10.2" class="level" orientation="orthogonal" renderorder="right-down" width="4" height="3" tilewidth="16" tileheight="16" infinite="0" backgroundcolor="#0a141e" nextlayerid="5" nextobjectid="6">
+ <properties>
+  <property name="title" value="Start"/>
+  <property name="lives" type="int" value="3"/>
+  <property name="hard" type="bool" value="true"/>
+  <property name="speed" type="float" value="1.5"/>
+  <property name="tint" type="color" value="#ff102030"/>
+  <property name="target" type="object" value="2"/>
+ </properties>
+ <tileset firstgid="1" name="terrain" tilewidth="16" tileheight="16" tilecount="8" columns="4">
+  <properties>
+   <property name="solid" type="bool" value="true"/>
+  </properties>
+  <image source="terrain.png" width="64" height="32"/>
+  <tile id="3">
+   <animation>
+    <frame tileid="3" duration="100"/>
+    <frame tileid="4" duration="200"/>
+   </animation>
+  </tile>
+ </tileset>
+ <layer id="1" name="ground" width="4" height="3">
+  <data encoding="csv">
+1,0,0,0,
+0,2684354568,0,0,
+0,0,0,2147483653
+</data>
+ </layer>
+ <group id="2" name="group" opacity="0.5" offsetx="4" offsety="8">
+  <objectgroup id="3" name="objects" color="#ff0000">
+   <object id="1" name="spawn" class="start" x="8" y="16" width="16" height="16">
+    <properties>
+     <property name="facing" value="left"/>
+    </properties>
+   </object>
+   <object id="2" name="crate" gid="2147483650" x="32" y="32" width="16" height="16"/>
+   <object id="3" name="zone" x="0" y="0">
+    <polygon points="0,0 16,0 0,16"/>
+   </object>
+   <object id="4" name="marker" x="4" y="4" rotation="45" visible="0">
+    <point/>
+   </object>
+   <object id="5" name="sign" x="10" y="20" width="64" height="16">
+    <text fontfamily="serif" pixelsize="12" bold="1" underline="1" halign="center">Hello</text>
+   </object>
+  </objectgroup>
+ </group>
+ <imagelayer id="4" name="sky" parallaxx="0.5" parallaxy="0.25">
+  <image source="sky.png" width="128" height="64"/>
+ </imagelayer>
+</map>
+`
+
+// decodeMap decodes a map from a document in the given format.
+func decodeMap(t *testing.T, doc string, format Format) *Map {
+	t.Helper()
+
+	var m Map
+	if err := Decode(strings.NewReader(doc), format, &m); err != nil {
+		t.Fatalf("decoding map: %v", err)
+	}
+	return &m
+}
+
+// roundTrip encodes a map with the given format and decodes the result into a new map.
+func roundTrip(t *testing.T, m *Map, format Format) (*Map, []byte) {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, format, m); err != nil {
+		t.Fatalf("encoding map: %v", err)
+	}
+	data := bytes.Clone(buf.Bytes())
+
+	var result Map
+	if err := Decode(&buf, format, &result); err != nil {
+		t.Fatalf("decoding map: %v\n%s", err, data)
+	}
+	return &result, data
+}
+
+// layerName returns the name of a layer of any type.
+func layerName(layer Layer) string {
+	switch value := layer.(type) {
+	case *TileLayer:
+		return value.Name
+	case *ObjectLayer:
+		return value.Name
+	case *ImageLayer:
+		return value.Name
+	case *GroupLayer:
+		return value.Name
+	}
+	return ""
+}
+
+// layerNames returns the names of every layer of a container in order, including those within
+// groups.
+func layerNames(owner Container) []string {
+	var names []string
+	for layer := owner.Head(); layer != nil; layer = layer.Next() {
+		names = append(names, layerName(layer))
+		if group, ok := layer.(*GroupLayer); ok {
+			names = append(names, layerNames(group)...)
+		}
+	}
+	return names
+}
+
+// sameImage reports whether two images refer to the same source with the same dimensions.
+func sameImage(a, b *Image) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Source == b.Source && a.Size == b.Size && a.Transparency == b.Transparency
+}
+
+// compareMaps reports differences between a map and one that was written and read back.
+func compareMaps(t *testing.T, want, got *Map) {
+	t.Helper()
+
+	if got.Orientation != want.Orientation || got.Size != want.Size || got.TileSize != want.TileSize {
+		t.Errorf("map is %v %v %v, want %v %v %v", got.Orientation, got.Size, got.TileSize,
+			want.Orientation, want.Size, want.TileSize)
+	}
+	if got.Class != want.Class || got.BackgroundColor != want.BackgroundColor {
+		t.Errorf("map class and color are %q %v, want %q %v", got.Class, got.BackgroundColor,
+			want.Class, want.BackgroundColor)
+	}
+	if got.NextLayerId != want.NextLayerId || got.NextObjectId != want.NextObjectId {
+		t.Errorf("next IDs are %d %d, want %d %d", got.NextLayerId, got.NextObjectId,
+			want.NextLayerId, want.NextObjectId)
+	}
+	if !reflect.DeepEqual(got.Properties, want.Properties) {
+		t.Errorf("map properties are %v, want %v", got.Properties, want.Properties)
+	}
+
+	if len(got.Tilesets) != len(want.Tilesets) {
+		t.Fatalf("map has %d tilesets, want %d", len(got.Tilesets), len(want.Tilesets))
+	}
+	for i, ts := range want.Tilesets {
+		other := got.Tilesets[i]
+		if other.FirstGID != ts.FirstGID || other.Name != ts.Name || other.Count != ts.Count ||
+			other.Columns != ts.Columns || !sameImage(other.Image, ts.Image) {
+			t.Errorf("tileset %d is %q %d, want %q %d", i, other.Name, other.FirstGID, ts.Name, ts.FirstGID)
+		}
+		if !reflect.DeepEqual(other.Properties, ts.Properties) {
+			t.Errorf("tileset %d properties are %v, want %v", i, other.Properties, ts.Properties)
+		}
+		if len(other.Tiles) != len(ts.Tiles) {
+			t.Errorf("tileset %d has %d tiles, want %d", i, len(other.Tiles), len(ts.Tiles))
+			continue
+		}
+		for j := range ts.Tiles {
+			if tile := other.Tiles[j]; tile.ID != ts.Tiles[j].ID || !reflect.DeepEqual(tile.Animation, ts.Tiles[j].Animation) {
+				t.Errorf("tile %d of tileset %d is %+v, want %+v", j, i, tile, ts.Tiles[j])
+			}
+		}
+	}
+
+	if names, wantNames := layerNames(got), layerNames(want); !slices.Equal(names, wantNames) {
+		t.Fatalf("layers are %v, want %v", names, wantNames)
+	}
+	compareLayers(t, want, got)
+}
+
+// compareLayers reports differences between the layers of two containers, which are known to
+// have the same names.
+func compareLayers(t *testing.T, want, got Container) {
+	t.Helper()
+
+	for layer, other := want.Head(), got.Head(); layer != nil; layer, other = layer.Next(), other.Next() {
+		switch value := layer.(type) {
+		case *TileLayer:
+			result := other.(*TileLayer)
+			if result.ID != value.ID || !slices.Equal(result.Tiles, value.Tiles) {
+				t.Errorf("tiles of %q are %v, want %v", value.Name, result.Tiles, value.Tiles)
+			}
+		case *ImageLayer:
+			result := other.(*ImageLayer)
+			if result.Parallax != value.Parallax || !sameImage(result.Image, value.Image) {
+				t.Errorf("image layer is %v %v, want %v %v", result.Parallax, result.Image, value.Parallax, value.Image)
+			}
+		case *GroupLayer:
+			result := other.(*GroupLayer)
+			if result.Opacity != value.Opacity || result.Offset != value.Offset {
+				t.Errorf("group is drawn with %v %v, want %v %v", result.Opacity, result.Offset,
+					value.Opacity, value.Offset)
+			}
+			compareLayers(t, value, result)
+		case *ObjectLayer:
+			compareObjects(t, value, other.(*ObjectLayer))
+		}
+	}
+}
+
+// compareObjects reports differences between the objects of two object layers.
+func compareObjects(t *testing.T, want, got *ObjectLayer) {
+	t.Helper()
+
+	if got.Color != want.Color {
+		t.Errorf("object layer color is %v, want %v", got.Color, want.Color)
+	}
+	if len(got.Objects) != len(want.Objects) {
+		t.Fatalf("object layer has %d objects, want %d", len(got.Objects), len(want.Objects))
+	}
+	for i := range want.Objects {
+		obj, wantObj := &got.Objects[i], &want.Objects[i]
+		if obj.ID != wantObj.ID || obj.Name != wantObj.Name || obj.Class != wantObj.Class ||
+			obj.Type != wantObj.Type || obj.GID != wantObj.GID || obj.Location != wantObj.Location ||
+			obj.Size != wantObj.Size || obj.Rotation != wantObj.Rotation || obj.Visible != wantObj.Visible {
+			t.Errorf("object %d is %+v, want %+v", i, obj, wantObj)
+		}
+		if !reflect.DeepEqual(obj.Properties, wantObj.Properties) {
+			t.Errorf("properties of %q are %v, want %v", obj.Name, obj.Properties, wantObj.Properties)
+		}
+		if !slices.Equal(obj.Points, wantObj.Points) {
+			t.Errorf("points of %q are %v, want %v", obj.Name, obj.Points, wantObj.Points)
+		}
+		if wantObj.Text != nil {
+			text, wantText := obj.Text, wantObj.Text
+			if text == nil || text.FontFamily != wantText.FontFamily || text.Value != wantText.Value ||
+				text.PixelSize != wantText.PixelSize || text.Style != wantText.Style ||
+				text.Align != wantText.Align {
+				t.Errorf("text of %q is %+v, want %+v", obj.Name, text, wantText)
+			}
+		}
+	}
+}
+
+func TestDecodeTestMap(t *testing.T) {
+	// Check the document is read as expected, so that the round trips compare meaningful values
+	m := decodeMap(t, testMapXML, FormatXML)
+	if names := layerNames(m); !slices.Equal(names, []string{"ground", "group", "objects", "sky"}) {
+		t.Fatalf("layers are %v", names)
+	}
+	if want := []TileID{1, 0, 0, 0, 0, 8 | FlipH | FlipD, 0, 0, 0, 0, 0, 5 | FlipH}; !slices.Equal(m.TileLayers[0].Tiles, want) {
+		t.Errorf("tiles are %v, want %v", m.TileLayers[0].Tiles, want)
+	}
+	objects := m.GroupLayers[0].ObjectLayers[0].Objects
+	if len(objects) != 5 || objects[1].GID != 2|FlipH || objects[2].Type != ObjectPolygon ||
+		len(objects[2].Points) != 3 || objects[3].Type != ObjectPoint || objects[3].Visible ||
+		objects[4].Text == nil || objects[4].Text.Value != "Hello" {
+		t.Errorf("objects are %+v", objects)
+	}
+	if len(m.Properties) != 6 || len(m.Tilesets) != 1 || m.Tilesets[0].Count != 8 {
+		t.Errorf("map has %d properties and %d tilesets", len(m.Properties), len(m.Tilesets))
+	}
+}
+
+func TestXMLRoundTrip(t *testing.T) {
+	m := decodeMap(t, testMapXML, FormatXML)
+	got, data := roundTrip(t, m, FormatXML)
+	compareMaps(t, m, got)
+
+	// Writing the decoded map again produces the same document
+	_, again := roundTrip(t, got, FormatXML)
+	if !bytes.Equal(data, again) {
+		t.Errorf("document changed when written again:\n%s\n%s", data, again)
+	}
+}
+
+func TestXMLTileset(t *testing.T) {
+	doc := `<tileset version="1.10" name="chars" tilewidth="8" tileheight="8" spacing="2" margin="1" tilecount="8" columns="4">
+ <image source="chars.png" trans="ff00ff" width="40" height="20"/>
+ <tile id="3" type="door">
+  <animation>
+   <frame tileid="3" duration="100"/>
+   <frame tileid="4" duration="200"/>
+  </animation>
+ </tile>
+</tileset>`
+
+	var tileset Tileset
+	if err := Decode(strings.NewReader(doc), FormatXML, &tileset); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := Encode(&buf, FormatXML, &tileset); err != nil {
+		t.Fatal(err)
+	}
+	var got Tileset
+	if err := Decode(&buf, FormatXML, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Name != "chars" || got.TileSize != (Size{Width: 8, Height: 8}) || got.Spacing != 2 || got.Margin != 1 ||
+		got.Count != 8 || got.Columns != 4 {
+		t.Errorf("tileset is %+v", got)
+	}
+	if !sameImage(got.Image, tileset.Image) || got.Image.Transparency != NewRGB(255, 0, 255) {
+		t.Errorf("image is %+v, want %+v", got.Image, tileset.Image)
+	}
+	if len(got.Tiles) != len(tileset.Tiles) {
+		t.Fatalf("tileset has %d tiles, want %d", len(got.Tiles), len(tileset.Tiles))
+	}
+	for i, tile := range tileset.Tiles {
+		if other := got.Tiles[i]; other.ID != tile.ID || other.Class != tile.Class ||
+			!reflect.DeepEqual(other.Animation, tile.Animation) {
+			t.Errorf("tile %d is %+v, want %+v", i, other, tile)
+		}
+	}
+}
+
+func TestXMLTemplate(t *testing.T) {
+	doc := `<template>
+ <object name="chest" width="16" height="16">
+  <properties>
+   <property name="gold" type="int" value="10"/>
+  </properties>
+ </object>
+</template>`
+
+	var template Template
+	if err := Decode(strings.NewReader(doc), FormatXML, &template); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := Encode(&buf, FormatXML, &template); err != nil {
+		t.Fatal(err)
+	}
+	var got Template
+	if err := Decode(&buf, FormatXML, &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Object.Name != "chest" || got.Object.Size != (Vec2{X: 16, Y: 16}) ||
+		!reflect.DeepEqual(got.Object.Properties, template.Object.Properties) {
+		t.Errorf("template object is %+v, want %+v", got.Object, template.Object)
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	m := decodeMap(t, testMapXML, FormatXML)
+	got, data := roundTrip(t, m, FormatJSON)
+	compareMaps(t, m, got)
+
+	_, again := roundTrip(t, got, FormatJSON)
+	if !bytes.Equal(data, again) {
+		t.Errorf("document changed when written again:\n%s\n%s", data, again)
+	}
+}
+
+func TestConvertFormats(t *testing.T) {
+	// A map read from one format and written to the other describes the same map
+	m := decodeMap(t, testMapXML, FormatXML)
+	toJSON, _ := roundTrip(t, m, FormatJSON)
+	toXML, _ := roundTrip(t, toJSON, FormatXML)
+	compareMaps(t, m, toXML)
+}
+
+func TestPropertyTypes(t *testing.T) {
+	m := decodeMap(t, testMapXML, FormatXML)
+	want := Properties{
+		"title":  {Name: "title", Type: TypeString, Value: "Start"},
+		"lives":  {Name: "lives", Type: TypeInt, Value: 3},
+		"hard":   {Name: "hard", Type: TypeBool, Value: true},
+		"speed":  {Name: "speed", Type: TypeFloat, Value: 1.5},
+		"tint":   {Name: "tint", Type: TypeColor, Value: NewRGBA(0x10, 0x20, 0x30, 0xff)},
+		"target": {Name: "target", Type: TypeObject, Value: 2},
+	}
+
+	for _, format := range []Format{FormatXML, FormatJSON} {
+		got, _ := roundTrip(t, m, format)
+		for name, prop := range want {
+			if value := got.Properties[name]; !reflect.DeepEqual(value, prop) {
+				t.Errorf("%v: property is %#v, want %#v", format, value, prop)
+			}
+		}
+	}
+}
+
+func TestJSONTileset(t *testing.T) {
+	doc := `{"type": "tileset", "name": "chars", "tilewidth": 8, "tileheight": 8, "spacing": 2, "margin": 1,
+		"tilecount": 8, "columns": 4, "image": "chars.png", "imagewidth": 40, "imageheight": 20,
+		"tiles": [{"id": 3, "animation": [{"tileid": 3, "duration": 100}, {"tileid": 4, "duration": 200}]}]}`
+
+	var tileset Tileset
+	if err := Decode(strings.NewReader(doc), FormatJSON, &tileset); err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := Encode(&buf, FormatJSON, &tileset); err != nil {
+		t.Fatal(err)
+	}
+	var got Tileset
+	if err := Decode(&buf, FormatJSON, &got); err != nil {
+		t.Fatal(err)
+	}
+
+	if got.Name != "chars" || got.Spacing != 2 || got.Margin != 1 || got.Count != 8 || got.Columns != 4 ||
+		!sameImage(got.Image, tileset.Image) {
+		t.Errorf("tileset is %+v, want %+v", got, tileset)
+	}
+	if len(got.Tiles) != len(tileset.Tiles) {
+		t.Fatalf("tileset has %d tiles, want %d", len(got.Tiles), len(tileset.Tiles))
+	}
+	for i, tile := range tileset.Tiles {
+		if other := got.Tiles[i]; other.ID != tile.ID || !reflect.DeepEqual(other.Animation, tile.Animation) {
+			t.Errorf("tile %d is %+v, want %+v", i, other, tile)
+		}
+	}
+}
+
+// vim: ts=4